@@ -18,6 +18,8 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/WirePod/cross/voskmodels"
 	"github.com/kercre123/wire-pod/chipper/pkg/initwirepod"
 	"github.com/kercre123/wire-pod/chipper/pkg/logger"
 	"github.com/kercre123/wire-pod/chipper/pkg/mdnshandler"
@@ -111,7 +113,13 @@ func PodWindow(myApp fyne.App) {
 			linkLabel.Show()
 			startButton.Disable()
 			contextCheck.Disable()
-			initwirepod.StartFromProgramInit(wirepod_vosk.Init, wirepod_vosk.STT, wirepod_vosk.Name)
+			ensureVoskModel()
+			engine, err := sttengine.Select(sttengine.ReadConfig(sttengine.ConfigPath(filepath.Join(DataPath, vars.PodName))))
+			if err != nil {
+				logger.Println(err)
+				engine, _ = sttengine.Get(sttengine.DefaultEngine)
+			}
+			initwirepod.StartFromProgramInit(engine.Init, engine.STT, engine.Name)
 			startButton.Enable()
 			contextCheck.Enable()
 			hyprLink.Hide()
@@ -136,6 +144,23 @@ func PodWindow(myApp fyne.App) {
 	window.Show()
 }
 
+// installs the model for the configured language from static/vosk-archives or the catalog.
+// vars.Init is safe to call early, initwirepod won't init again.
+func ensureVoskModel() {
+	vars.Init()
+	if vars.APIConfig.STT.Service != "vosk" || !vars.APIConfig.PastInitialSetup {
+		return
+	}
+	models := &voskmodels.Manager{
+		ModelDir:   vars.VoskModelPath,
+		CatalogURL: voskmodels.DefaultCatalogURL,
+		OfflineDir: filepath.Join(DataPath, "/static/vosk-archives"),
+	}
+	if err := models.Ensure(vars.APIConfig.STT.Language); err != nil {
+		logger.Println("Unable to install a Vosk model: " + err.Error())
+	}
+}
+
 func DeleteStaticContent() {
 	os.RemoveAll(filepath.Join(DataPath, "/static"))
 }
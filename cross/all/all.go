@@ -14,19 +14,23 @@ func OnExit()
 Notes:
 
 Use zenity for message boxes.
-Use fyne for about window if possible ("Check for updates" planned for the future)
+Use fyne for about window if possible. Updates are handled by cross/updater.
 */
 
 type WPConfig struct {
-	WSPort       string `json:"wsport"`
-	RunAtStartup bool   `json:"runatstartup"`
-	InstallPath  string `json:"runtimepath"`
-	Version      string `json:"version"`
+	// see schema.go
+	SchemaVersion int    `json:"schemaversion"`
+	WSPort        string `json:"wsport"`
+	RunAtStartup  bool   `json:"runatstartup"`
+	InstallPath   string `json:"runtimepath"`
+	Version       string `json:"version"`
 	// if NeedsRestart && hostname != escapepod; then error
 	NeedsRestart   bool `json:"needsrestart"`
 	LastRunningPID int  `json:"lastrunningpid"`
 	FirstStartup   bool `json:"firststartup"`
 	NoPodWarn      bool `json:"nopodwarn"`
+	// install updates in the background, see cross/updater
+	AutoUpdate bool `json:"autoupdate"`
 }
 
 type OSFuncs interface {
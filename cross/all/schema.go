@@ -0,0 +1,194 @@
+package cross
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Config schema versions:
+
+0: no schema version. Windows stored no FirstStartup or NoPodWarn, it's taken as
+   false when an install path is stored.
+1: schemaversion is stored, every field is present.
+2: WSPort is always a valid port ("0" and junk become "8080").
+3: autoupdate is stored (default false).
+
+Both backends read their storage into a RawConfig, run Migrate, and write the
+result back if anything changed. Raw keys are the JSON tags of WPConfig.
+*/
+
+const CurrentSchemaVersion = 3
+
+const DefaultWSPort = "8080"
+
+// flat string view of a stored config, independent of registry or JSON
+type RawConfig map[string]string
+
+type Migration struct {
+	To          int
+	Description string
+	Migrate     func(raw RawConfig) []string
+}
+
+type MigrationReport struct {
+	FromVersion int      `json:"fromversion"`
+	ToVersion   int      `json:"toversion"`
+	Changes     []string `json:"changes"`
+}
+
+func (r MigrationReport) Changed() bool {
+	return r.FromVersion != r.ToVersion || len(r.Changes) > 0
+}
+
+var Migrations = []Migration{
+	{
+		To:          1,
+		Description: "add schema version and fill missing fields",
+		Migrate: func(raw RawConfig) []string {
+			var changes []string
+			defaults := RawConfig{
+				"wsport":         DefaultWSPort,
+				"runatstartup":   "false",
+				"needsrestart":   "false",
+				"lastrunningpid": "0",
+				"firststartup":   "true",
+				"nopodwarn":      "false",
+			}
+			// an install path means the installer (which asks about running at startup) or an earlier
+			// wire-pod already set the pod up, so this isn't its first startup
+			if raw["runtimepath"] != "" {
+				defaults["firststartup"] = "false"
+			}
+			keys := make([]string, 0, len(defaults))
+			for key := range defaults {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if _, ok := raw[key]; !ok {
+					raw[key] = defaults[key]
+					changes = append(changes, fmt.Sprintf("%s: missing, set to %q", key, defaults[key]))
+				}
+			}
+			return changes
+		},
+	},
+	{
+		To:          2,
+		Description: "normalize web port",
+		Migrate: func(raw RawConfig) []string {
+			port := strings.TrimSpace(raw["wsport"])
+			if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+				raw["wsport"] = DefaultWSPort
+				return []string{fmt.Sprintf("wsport: %q is not a valid port, set to %q", port, DefaultWSPort)}
+			}
+			if port != raw["wsport"] {
+				raw["wsport"] = port
+				return []string{"wsport: trimmed whitespace"}
+			}
+			return nil
+		},
+	},
+	{
+		To:          3,
+		Description: "add auto update setting",
+		Migrate: func(raw RawConfig) []string {
+			if _, ok := raw["autoupdate"]; ok {
+				return nil
+			}
+			raw["autoupdate"] = "false"
+			return []string{`autoupdate: missing, set to "false"`}
+		},
+	},
+}
+
+// Migrate upgrades raw in place to CurrentSchemaVersion and decodes it
+func Migrate(raw RawConfig) (WPConfig, MigrationReport, error) {
+	var report MigrationReport
+	version := 0
+	if v, ok := raw["schemaversion"]; ok {
+		var err error
+		version, err = strconv.Atoi(v)
+		if err != nil {
+			return WPConfig{}, report, fmt.Errorf("invalid schema version %q", v)
+		}
+	}
+	if version > CurrentSchemaVersion {
+		return WPConfig{}, report, fmt.Errorf("config schema version %d is newer than this wire-pod (%d)", version, CurrentSchemaVersion)
+	}
+	report.FromVersion = version
+	for _, m := range Migrations {
+		if m.To <= version {
+			continue
+		}
+		for _, change := range m.Migrate(raw) {
+			report.Changes = append(report.Changes, fmt.Sprintf("v%d (%s): %s", m.To, m.Description, change))
+		}
+		version = m.To
+		raw["schemaversion"] = strconv.Itoa(version)
+	}
+	report.ToVersion = version
+	return FromRaw(raw), report, nil
+}
+
+func DefaultConfig() WPConfig {
+	conf, _, _ := Migrate(RawConfig{})
+	return conf
+}
+
+func ToRaw(conf WPConfig) RawConfig {
+	return RawConfig{
+		"schemaversion":  strconv.Itoa(conf.SchemaVersion),
+		"wsport":         conf.WSPort,
+		"runatstartup":   strconv.FormatBool(conf.RunAtStartup),
+		"runtimepath":    conf.InstallPath,
+		"version":        conf.Version,
+		"needsrestart":   strconv.FormatBool(conf.NeedsRestart),
+		"lastrunningpid": strconv.Itoa(conf.LastRunningPID),
+		"firststartup":   strconv.FormatBool(conf.FirstStartup),
+		"nopodwarn":      strconv.FormatBool(conf.NoPodWarn),
+		"autoupdate":     strconv.FormatBool(conf.AutoUpdate),
+	}
+}
+
+func FromRaw(raw RawConfig) WPConfig {
+	var conf WPConfig
+	conf.SchemaVersion, _ = strconv.Atoi(raw["schemaversion"])
+	conf.WSPort = raw["wsport"]
+	conf.RunAtStartup = raw["runatstartup"] == "true"
+	conf.InstallPath = raw["runtimepath"]
+	conf.Version = raw["version"]
+	conf.NeedsRestart = raw["needsrestart"] == "true"
+	conf.LastRunningPID, _ = strconv.Atoi(raw["lastrunningpid"])
+	conf.FirstStartup = raw["firststartup"] == "true"
+	conf.NoPodWarn = raw["nopodwarn"] == "true"
+	conf.AutoUpdate = raw["autoupdate"] == "true"
+	return conf
+}
+
+// RawFromJSON flattens a stored JSON config. fields that aren't there stay absent so migrations can see them.
+func RawFromJSON(data []byte) (RawConfig, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	raw := RawConfig{}
+	for key, value := range fields {
+		switch v := value.(type) {
+		case string:
+			raw[key] = v
+		case bool:
+			raw[key] = strconv.FormatBool(v)
+		case float64:
+			raw[key] = strconv.FormatInt(int64(v), 10)
+		case nil:
+		default:
+			raw[key] = fmt.Sprint(v)
+		}
+	}
+	return raw, nil
+}
@@ -0,0 +1,164 @@
+package cross
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) RawConfig {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := RawFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		fixture string
+		from    int
+		want    WPConfig
+		// how many changes the report lists
+		changes int
+	}{
+		{
+			// the registry as the Windows installer left it before schema versions, strings only
+			fixture: "v0-windows-registry.json",
+			from:    0,
+			want: WPConfig{
+				SchemaVersion:  3,
+				WSPort:         "8080",
+				RunAtStartup:   true,
+				InstallPath:    `C:\Program Files\wire-pod`,
+				Version:        "v1.1.0",
+				LastRunningPID: 4312,
+			},
+			// firststartup, nopodwarn and autoupdate
+			changes: 3,
+		},
+		{
+			fixture: "v0-new-install.json",
+			from:    0,
+			want: WPConfig{
+				SchemaVersion: 3,
+				WSPort:        "8080",
+				FirstStartup:  true,
+			},
+			changes: 7,
+		},
+		{
+			fixture: "v1-mac.json",
+			from:    1,
+			want: WPConfig{
+				SchemaVersion: 3,
+				WSPort:        "8080",
+				InstallPath:   "/Applications/WirePod.app/Contents/MacOS/../Frameworks",
+				Version:       "v1.2.0",
+				FirstStartup:  true,
+				NoPodWarn:     true,
+			},
+			changes: 1,
+		},
+		{
+			fixture: "v1-wsport-zero.json",
+			from:    1,
+			want: WPConfig{
+				SchemaVersion:  3,
+				WSPort:         "8080",
+				RunAtStartup:   true,
+				InstallPath:    "/opt/wire-pod",
+				Version:        "v1.2.1",
+				LastRunningPID: 77,
+			},
+			changes: 2,
+		},
+		{
+			fixture: "v1-wsport-spaces.json",
+			from:    1,
+			want: WPConfig{
+				SchemaVersion: 3,
+				WSPort:        "8081",
+				InstallPath:   "/opt/wire-pod",
+				Version:       "v1.2.1",
+			},
+			changes: 2,
+		},
+		{
+			// a v2 config only gains the auto update setting, its port is left alone
+			fixture: "v2-windows.json",
+			from:    2,
+			want: WPConfig{
+				SchemaVersion:  3,
+				WSPort:         "8083",
+				RunAtStartup:   true,
+				InstallPath:    `C:\Program Files\wire-pod`,
+				Version:        "v1.2.2",
+				LastRunningPID: 5120,
+				NoPodWarn:      true,
+			},
+			changes: 1,
+		},
+		{
+			fixture: "v3-autoupdate.json",
+			from:    3,
+			want: WPConfig{
+				SchemaVersion: 3,
+				WSPort:        "8082",
+				InstallPath:   "/opt/wire-pod",
+				Version:       "v1.3.0",
+				NeedsRestart:  true,
+				AutoUpdate:    true,
+			},
+			changes: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			raw := readFixture(t, tt.fixture)
+			conf, report, err := Migrate(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(conf, tt.want) {
+				t.Errorf("got %+v, want %+v", conf, tt.want)
+			}
+			if report.FromVersion != tt.from || report.ToVersion != CurrentSchemaVersion {
+				t.Errorf("migrated v%d to v%d, want v%d to v%d", report.FromVersion, report.ToVersion, tt.from, CurrentSchemaVersion)
+			}
+			if len(report.Changes) != tt.changes {
+				t.Errorf("%d changes, want %d: %q", len(report.Changes), tt.changes, report.Changes)
+			}
+			if report.Changed() != (tt.from != CurrentSchemaVersion || tt.changes > 0) {
+				t.Errorf("Changed() is %v", report.Changed())
+			}
+			// what's written back migrates to the same thing, without changes
+			again, report, err := Migrate(ToRaw(conf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again, conf) || report.Changed() {
+				t.Errorf("migrating again gave %+v, %q", again, report.Changes)
+			}
+		})
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	if _, _, err := Migrate(readFixture(t, "v4-newer.json")); err == nil {
+		t.Error("a config from a newer wire-pod migrated")
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	conf := DefaultConfig()
+	if conf.SchemaVersion != CurrentSchemaVersion || conf.WSPort != DefaultWSPort || !conf.FirstStartup {
+		t.Errorf("got %+v", conf)
+	}
+}
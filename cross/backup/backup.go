@@ -0,0 +1,333 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// a bundle is a zip with manifest.json plus one folder per component.
+// files are stored relative to their component, so a bundle can be restored on a machine with a different layout.
+
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+type Component struct {
+	Name string
+	// files or directories
+	Paths []string
+}
+
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type ManifestComponent struct {
+	Name  string         `json:"name"`
+	Files []ManifestFile `json:"files"`
+}
+
+type Manifest struct {
+	FormatVersion int                 `json:"formatversion"`
+	CreatedAt     time.Time           `json:"createdat"`
+	PodVersion    string              `json:"podversion"`
+	Hostname      string              `json:"hostname"`
+	Encrypted     bool                `json:"encrypted"`
+	Components    []ManifestComponent `json:"components"`
+}
+
+// DefaultComponents is all pod state. vars.Init must have run so the paths point to the right place.
+func DefaultComponents() []Component {
+	return []Component{
+		{Name: "jdocs", Paths: []string{vars.JdocsPath}},
+		{Name: "bot-info", Paths: []string{vars.BotInfoPath}},
+		{Name: "custom-intents", Paths: []string{vars.CustomIntentsPath}},
+		{Name: "bot-configs", Paths: []string{vars.BotConfigsPath}},
+		{Name: "api-config", Paths: []string{vars.ApiConfigPath}},
+		{Name: "session-certs", Paths: []string{vars.SessionCertPath}},
+		{Name: "server-certs", Paths: []string{vars.Certs}},
+	}
+}
+
+// Filter keeps only the named components. no names means all of them.
+func Filter(components []Component, names []string) ([]Component, error) {
+	if len(names) == 0 {
+		return components, nil
+	}
+	var filtered []Component
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, c := range components {
+			if c.Name == name {
+				filtered = append(filtered, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown component: %s", name)
+		}
+	}
+	return filtered, nil
+}
+
+// Export writes a bundle of components to w. an empty passphrase means no encryption.
+func Export(w io.Writer, components []Component, passphrase string) (Manifest, error) {
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Encrypted:     passphrase != "",
+	}
+	manifest.Hostname, _ = os.Hostname()
+	if ver, err := os.ReadFile(vars.VersionFile); err == nil {
+		manifest.PodVersion = strings.TrimSpace(string(ver))
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, c := range components {
+		mc := ManifestComponent{Name: c.Name}
+		for _, p := range c.Paths {
+			files, err := collectFiles(p)
+			if err != nil {
+				return manifest, err
+			}
+			for _, f := range files {
+				data, err := os.ReadFile(f.abs)
+				if err != nil {
+					return manifest, err
+				}
+				name := c.Name + "/" + f.rel
+				fw, err := zw.Create(name)
+				if err != nil {
+					return manifest, err
+				}
+				fw.Write(data)
+				sum := sha256.Sum256(data)
+				mc.Files = append(mc.Files, ManifestFile{
+					Path:   f.rel,
+					Size:   int64(len(data)),
+					SHA256: hex.EncodeToString(sum[:]),
+				})
+			}
+		}
+		manifest.Components = append(manifest.Components, mc)
+	}
+	manifestBytes, _ := json.MarshalIndent(manifest, "", "  ")
+	fw, err := zw.Create(manifestName)
+	if err != nil {
+		return manifest, err
+	}
+	fw.Write(manifestBytes)
+	if err := zw.Close(); err != nil {
+		return manifest, err
+	}
+
+	out := buf.Bytes()
+	if passphrase != "" {
+		out, err = encrypt(out, passphrase)
+		if err != nil {
+			return manifest, err
+		}
+	}
+	_, err = w.Write(out)
+	return manifest, err
+}
+
+// ExportFile is Export to a file, written to a temp file first so a failed export never leaves half a bundle
+func ExportFile(path string, components []Component, passphrase string) (Manifest, error) {
+	os.MkdirAll(filepath.Dir(path), 0777)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest, err := Export(f, components, passphrase)
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return manifest, err
+	}
+	return manifest, os.Rename(tmp, path)
+}
+
+// ReadManifest returns the manifest of a bundle without restoring anything
+func ReadManifest(bundle []byte, passphrase string) (Manifest, error) {
+	zr, err := openBundle(bundle, passphrase)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return readManifest(zr)
+}
+
+// Import restores the components present in both the bundle and components.
+// every file is checked against the manifest before anything is written.
+func Import(bundle []byte, components []Component, passphrase string) (Manifest, error) {
+	zr, err := openBundle(bundle, passphrase)
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest, err := readManifest(zr)
+	if err != nil {
+		return manifest, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	type pending struct {
+		dest string
+		data []byte
+	}
+	var writes []pending
+	for _, c := range components {
+		var mc *ManifestComponent
+		for i := range manifest.Components {
+			if manifest.Components[i].Name == c.Name {
+				mc = &manifest.Components[i]
+			}
+		}
+		if mc == nil || len(c.Paths) == 0 {
+			continue
+		}
+		for _, mf := range mc.Files {
+			zf, ok := files[c.Name+"/"+mf.Path]
+			if !ok {
+				return manifest, fmt.Errorf("bundle is missing %s/%s", c.Name, mf.Path)
+			}
+			data, err := readZipFile(zf)
+			if err != nil {
+				return manifest, err
+			}
+			sum := sha256.Sum256(data)
+			if hex.EncodeToString(sum[:]) != mf.SHA256 {
+				return manifest, fmt.Errorf("checksum mismatch for %s/%s", c.Name, mf.Path)
+			}
+			dest, err := destination(c.Paths[0], mf.Path)
+			if err != nil {
+				return manifest, err
+			}
+			writes = append(writes, pending{dest: dest, data: data})
+		}
+	}
+	for _, p := range writes {
+		if err := os.MkdirAll(filepath.Dir(p.dest), 0777); err != nil {
+			return manifest, err
+		}
+		if err := os.WriteFile(p.dest, p.data, 0644); err != nil {
+			return manifest, err
+		}
+	}
+	return manifest, nil
+}
+
+func openBundle(bundle []byte, passphrase string) (*zip.Reader, error) {
+	if isEncrypted(bundle) {
+		if passphrase == "" {
+			return nil, errors.New("bundle is encrypted, a passphrase is required")
+		}
+		var err error
+		bundle, err = decrypt(bundle, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		return nil, fmt.Errorf("not a wire-pod backup: %s", err)
+	}
+	return zr, nil
+}
+
+func readManifest(zr *zip.Reader) (Manifest, error) {
+	var manifest Manifest
+	for _, f := range zr.File {
+		if f.Name != manifestName {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return manifest, err
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return manifest, fmt.Errorf("invalid manifest: %s", err)
+		}
+		if manifest.FormatVersion > FormatVersion {
+			return manifest, fmt.Errorf("backup format %d is newer than this wire-pod supports (%d)", manifest.FormatVersion, FormatVersion)
+		}
+		return manifest, nil
+	}
+	return manifest, errors.New("not a wire-pod backup: no manifest")
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+type collected struct {
+	abs string
+	rel string
+}
+
+// a file is stored by its base name, a directory by paths relative to it. missing paths are skipped.
+func collectFiles(p string) ([]collected, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []collected{{abs: p, rel: filepath.Base(p)}}, nil
+	}
+	var files []collected
+	err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(p, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, collected{abs: path, rel: filepath.ToSlash(rel)})
+		return nil
+	})
+	return files, err
+}
+
+// where a stored file goes on this machine. for a single-file component the stored name is ignored.
+func destination(target, rel string) (string, error) {
+	if stat, err := os.Stat(target); (err == nil && stat.IsDir()) || strings.HasSuffix(target, "/") || strings.HasSuffix(target, string(os.PathSeparator)) {
+		dest := filepath.Join(target, filepath.FromSlash(rel))
+		if !strings.HasPrefix(dest, filepath.Clean(target)+string(os.PathSeparator)) {
+			return "", fmt.Errorf("illegal file path: %s", rel)
+		}
+		return dest, nil
+	}
+	if strings.Contains(rel, "/") {
+		return "", fmt.Errorf("illegal file path: %s", rel)
+	}
+	return target, nil
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var cliUsage = `usage: %s backup <command> [flags]
+
+commands:
+  export [-o file] [-only a,b]    write a bundle (default: wirepod-export-<time>.wpbak)
+  import [-only a,b] <file>       restore a bundle
+  inspect <file>                  print a bundle's manifest
+  list                            list scheduled backups
+  run                             make a scheduled backup now and prune old ones
+
+the passphrase is read from -passphrase or WIREPOD_BACKUP_PASSPHRASE.
+components: %s
+`
+
+// RunCLI handles "backup ..." arguments and returns an exit code.
+// podDir holds the schedule config, components is what export and import work on.
+func RunCLI(podDir string, all []Component, args []string) int {
+	usage := func() int {
+		fmt.Fprintf(os.Stderr, cliUsage, filepath.Base(os.Args[0]), strings.Join(componentNames(all), ", "))
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	fs := flag.NewFlagSet("backup "+args[0], flag.ContinueOnError)
+	out := fs.String("o", "", "output file")
+	only := fs.String("only", "", "comma-separated components")
+	passphrase := fs.String("passphrase", os.Getenv("WIREPOD_BACKUP_PASSPHRASE"), "bundle passphrase")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	var names []string
+	if *only != "" {
+		names = strings.Split(*only, ",")
+	}
+	components, err := Filter(all, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		path := *out
+		if path == "" {
+			path = "wirepod-export-" + time.Now().Format(autoTimestamp) + BundleExt
+		}
+		manifest, err := ExportFile(path, components, *passphrase)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "export failed:", err)
+			return 1
+		}
+		fmt.Printf("Exported %d components to %s\n", len(manifest.Components), path)
+	case "import", "inspect":
+		if fs.NArg() != 1 {
+			return usage()
+		}
+		bundle, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if args[0] == "inspect" {
+			manifest, err := ReadManifest(bundle, *passphrase)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			marshalled, _ := json.MarshalIndent(manifest, "", "  ")
+			fmt.Println(string(marshalled))
+			return 0
+		}
+		manifest, err := Import(bundle, components, *passphrase)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "import failed:", err)
+			return 1
+		}
+		fmt.Printf("Restored backup from %s (%s). Restart wire-pod to apply it.\n", manifest.CreatedAt.Local().Format(time.RFC1123), manifest.Hostname)
+	case "list":
+		sched, err := ReadSchedule(podDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		files, err := List(sched.Dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, file := range files {
+			fmt.Printf("%s\t%d\t%s\n", file.CreatedAt.Format(time.RFC3339), file.Size, file.Path)
+		}
+	case "run":
+		sched, err := ReadSchedule(podDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if *passphrase != "" {
+			sched.Passphrase = *passphrase
+		}
+		file, err := RunOnce(sched, components)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "backup failed:", err)
+			return 1
+		}
+		fmt.Println("Backup written to " + file.Path)
+	default:
+		return usage()
+	}
+	return 0
+}
+
+func componentNames(components []Component) []string {
+	var names []string
+	for _, c := range components {
+		names = append(names, c.Name)
+	}
+	return names
+}
@@ -0,0 +1,15 @@
+package backup
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed backup.html
+var page []byte
+
+// ServePage exports, restores and schedules backups through the /api-chipper/backup_ endpoints
+func ServePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
@@ -0,0 +1,219 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+)
+
+var backupLog = logs.For("backup")
+
+var ScheduleConfigName = "backup.json"
+
+// automatic backups are named wirepod-<timestamp>.wpbak so they sort by age
+const (
+	autoPrefix    = "wirepod-"
+	BundleExt     = ".wpbak"
+	autoTimestamp = "20060102-150405"
+)
+
+type Schedule struct {
+	Enabled       bool   `json:"enabled"`
+	IntervalHours int    `json:"intervalhours"`
+	Keep          int    `json:"keep"`
+	Dir           string `json:"dir"`
+	// optional. stored in plain text next to the config, so only as safe as the pod directory.
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+type BackupFile struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdat"`
+}
+
+// DefaultSchedule is off, daily, keeping a week, in <podDir>/backups
+func DefaultSchedule(podDir string) Schedule {
+	return Schedule{
+		Enabled:       false,
+		IntervalHours: 24,
+		Keep:          7,
+		Dir:           filepath.Join(podDir, "backups"),
+	}
+}
+
+func ScheduleConfigPath(podDir string) string {
+	return filepath.Join(podDir, ScheduleConfigName)
+}
+
+// ReadSchedule returns the defaults if the file doesn't exist
+func ReadSchedule(podDir string) (Schedule, error) {
+	sched := DefaultSchedule(podDir)
+	data, err := os.ReadFile(ScheduleConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sched, nil
+		}
+		return sched, err
+	}
+	if err := json.Unmarshal(data, &sched); err != nil {
+		return DefaultSchedule(podDir), err
+	}
+	if sched.Dir == "" {
+		sched.Dir = DefaultSchedule(podDir).Dir
+	}
+	return sched, nil
+}
+
+func WriteSchedule(podDir string, sched Schedule) error {
+	if err := sched.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(sched, "", "  ")
+	return os.WriteFile(ScheduleConfigPath(podDir), data, 0600)
+}
+
+func (s Schedule) Validate() error {
+	if s.IntervalHours < 1 {
+		return errors.New("interval must be at least one hour")
+	}
+	if s.Keep < 1 {
+		return errors.New("must keep at least one backup")
+	}
+	if s.Dir == "" {
+		return errors.New("backup directory is empty")
+	}
+	return nil
+}
+
+// List returns the automatic backups in dir, newest first
+func List(dir string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []BackupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, autoPrefix) || !strings.HasSuffix(name, BundleExt) {
+			continue
+		}
+		created, err := time.ParseInLocation(autoTimestamp, strings.TrimSuffix(strings.TrimPrefix(name, autoPrefix), BundleExt), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, BackupFile{
+			Name:      name,
+			Path:      filepath.Join(dir, name),
+			Size:      info.Size(),
+			CreatedAt: created,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.After(files[j].CreatedAt)
+	})
+	return files, nil
+}
+
+// RunOnce makes one automatic backup of components and prunes old ones
+func RunOnce(sched Schedule, components []Component) (BackupFile, error) {
+	name := autoPrefix + time.Now().Format(autoTimestamp) + BundleExt
+	path := filepath.Join(sched.Dir, name)
+	if _, err := ExportFile(path, components, sched.Passphrase); err != nil {
+		return BackupFile{}, err
+	}
+	Prune(sched.Dir, sched.Keep)
+	info, _ := os.Stat(path)
+	file := BackupFile{Name: name, Path: path, CreatedAt: time.Now()}
+	if info != nil {
+		file.Size = info.Size()
+	}
+	return file, nil
+}
+
+// Prune deletes all but the newest keep automatic backups. manually exported bundles are never touched.
+func Prune(dir string, keep int) error {
+	files, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for i, file := range files {
+		if i < keep {
+			continue
+		}
+		backupLog.Info("Removing old backup " + file.Name)
+		os.Remove(file.Path)
+	}
+	return nil
+}
+
+// Scheduler runs RunOnce on an interval. Start again with a new schedule to apply changes.
+type Scheduler struct {
+	// called for every run so changed paths are picked up
+	Components func() []Component
+	mu         sync.Mutex
+	stop       chan struct{}
+}
+
+func (s *Scheduler) Start(sched Schedule) {
+	s.Stop()
+	if !sched.Enabled {
+		return
+	}
+	if err := sched.Validate(); err != nil {
+		backupLog.Warn("Not starting scheduled backups: " + err.Error())
+		return
+	}
+	s.mu.Lock()
+	stop := make(chan struct{})
+	s.stop = stop
+	s.mu.Unlock()
+	interval := time.Duration(sched.IntervalHours) * time.Hour
+	go func() {
+		// catch up if the last backup is older than the interval, e.g. after the machine was off
+		var wait time.Duration
+		if files, _ := List(sched.Dir); len(files) == 0 || time.Since(files[0].CreatedAt) >= interval {
+			wait = time.Minute
+		} else {
+			wait = interval - time.Since(files[0].CreatedAt)
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(wait):
+			}
+			file, err := RunOnce(sched, s.Components())
+			if err != nil {
+				backupLog.Error("Scheduled backup failed: " + err.Error())
+			} else {
+				backupLog.Info("Scheduled backup written to " + file.Path)
+			}
+			wait = interval
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
@@ -0,0 +1,192 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/wav"
+)
+
+// opt-in recordings of what robots sent, for telling STT mistakes from intent matching mistakes.
+// files are named <esn>-<unix millis>.<wav|ogg> in one directory, and the history entry names its file.
+
+var ConfigName = "audio-capture.json"
+
+// DirName is the recordings' directory in the pod directory
+var DirName = "audio-captures"
+
+const (
+	// decoded 16 kHz PCM in a WAV file, which browsers play
+	FormatWAV = "wav"
+	// the Ogg Opus stream as the robot sent it
+	FormatOgg = "ogg"
+)
+
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// ESNs to record. empty records every robot.
+	Robots []string `json:"robots,omitempty"`
+	Format string   `json:"format"`
+	// the oldest recordings are deleted once there is more than this
+	MaxSizeMB int `json:"maxsizemb"`
+	// recordings older than this are deleted. 0 keeps them until the size cap.
+	MaxAgeDays int `json:"maxagedays"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Format:     FormatWAV,
+		MaxSizeMB:  200,
+		MaxAgeDays: 7,
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig returns the defaults if the file doesn't exist
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := conf.Validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, nil
+}
+
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(podDir), data, 0644)
+}
+
+var esnPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}$`)
+
+func (c Config) Validate() error {
+	if c.Format != FormatWAV && c.Format != FormatOgg {
+		return fmt.Errorf("format must be %s or %s", FormatWAV, FormatOgg)
+	}
+	if c.MaxSizeMB < 1 {
+		return errors.New("the size cap must be at least 1 MB")
+	}
+	if c.MaxAgeDays < 0 {
+		return errors.New("maximum age can't be negative")
+	}
+	for _, esn := range c.Robots {
+		if !esnPattern.MatchString(esn) {
+			return fmt.Errorf("%q isn't an ESN", esn)
+		}
+	}
+	return nil
+}
+
+// Wants is whether requests from esn are recorded
+func (c Config) Wants(esn string) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Robots) == 0 {
+		return true
+	}
+	for _, robot := range c.Robots {
+		if strings.EqualFold(robot, esn) {
+			return true
+		}
+	}
+	return false
+}
+
+// FileName is where a request from esn at t is recorded
+func FileName(esn string, t time.Time, format string) string {
+	return fmt.Sprintf("%s-%d.%s", strings.ToLower(esn), t.UnixMilli(), format)
+}
+
+var namePattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9]+\.(wav|ogg)$`)
+
+// Path is the file's path in dir, or an error for names which aren't recordings
+func Path(dir, name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("%q isn't a recording", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Save writes a recording to dir and returns its file name. pcm is used for WAV, ogg for Ogg.
+// if the robot sent PCM there is no Ogg stream, and the recording is a WAV either way.
+func Save(dir, esn string, t time.Time, format string, pcm, ogg []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if format == FormatOgg && len(ogg) > 0 {
+		name := FileName(esn, t, FormatOgg)
+		return name, os.WriteFile(filepath.Join(dir, name), ogg, 0644)
+	}
+	name := FileName(esn, t, FormatWAV)
+	return name, os.WriteFile(filepath.Join(dir, name), wav.Encode(pcm), 0644)
+}
+
+// Prune deletes recordings older than the maximum age, then the oldest until dir is under the size cap.
+// it returns how many were deleted.
+func Prune(dir string, conf Config) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	type file struct {
+		path string
+		size int64
+		mod  time.Time
+	}
+	var files []file
+	var total int64
+	for _, entry := range entries {
+		if !namePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{filepath.Join(dir, entry.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	limit := int64(conf.MaxSizeMB) * 1024 * 1024
+	cutoff := time.Now().AddDate(0, 0, -conf.MaxAgeDays)
+	deleted := 0
+	for _, f := range files {
+		expired := conf.MaxAgeDays > 0 && f.mod.Before(cutoff)
+		if !expired && total <= limit {
+			break
+		}
+		// another prune may have got there first
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return deleted, err
+		}
+		total -= f.size
+		deleted++
+	}
+	return deleted, nil
+}
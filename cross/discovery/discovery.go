@@ -0,0 +1,181 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/zeroconf"
+)
+
+// finds robots on the LAN by the _ankivector._tcp service they advertise.
+// the hostname they advertise is their name (Vector-R2D2), which the pod knows the ESN of
+// once the robot has connected to it.
+
+var discoveryLog = logs.For("discovery")
+
+const service = "_ankivector._tcp"
+
+type Robot struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+	// empty if the pod hasn't seen this robot before
+	ESN string `json:"esn"`
+	// in the SDK info file, so the web interface can talk to it
+	Authenticated bool      `json:"authenticated"`
+	LastSeen      time.Time `json:"lastseen"`
+}
+
+type Browser struct {
+	// returns the ESN of the robot called name (empty if unknown), and whether it is authenticated
+	Match func(name string) (esn string, authenticated bool)
+	// called for every advertisement, after Match
+	OnFound func(Robot)
+
+	mu      sync.Mutex
+	robots  map[string]Robot
+	running bool
+	now     chan struct{}
+}
+
+func NewBrowser() *Browser {
+	return &Browser{robots: make(map[string]Robot), now: make(chan struct{}, 1)}
+}
+
+// Robots is every robot seen since the pod started, by name
+func (b *Browser) Robots() []Robot {
+	b.mu.Lock()
+	var robots []Robot
+	for _, robot := range b.robots {
+		robots = append(robots, robot)
+	}
+	b.mu.Unlock()
+	// Match again, a robot may have been set up since it was seen
+	for i := range robots {
+		robots[i].ESN, robots[i].Authenticated = b.Match(robots[i].Name)
+	}
+	sort.Slice(robots, func(i, j int) bool { return robots[i].Name < robots[j].Name })
+	return robots
+}
+
+// Get is the robot called name, if it has been seen
+func (b *Browser) Get(name string) (Robot, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	robot, ok := b.robots[name]
+	return robot, ok
+}
+
+// Now restarts the browse, so robots answer again right away
+func (b *Browser) Now() {
+	select {
+	case b.now <- struct{}{}:
+	default:
+	}
+}
+
+// Run browses until the process exits. calling it again while it runs does nothing.
+func (b *Browser) Run() {
+	b.mu.Lock()
+	if b.running {
+		b.mu.Unlock()
+		return
+	}
+	b.running = true
+	b.mu.Unlock()
+	for {
+		if err := b.browse(); err != nil {
+			discoveryLog.Warn("Browsing for robots failed: " + err.Error())
+			time.Sleep(30 * time.Second)
+		}
+	}
+}
+
+// one browse, which lasts a few minutes or until Now
+func (b *Browser) browse() error {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return err
+	}
+	entries := make(chan *zeroconf.ServiceEntry)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := resolver.Browse(ctx, service, "local.", entries); err != nil {
+		return err
+	}
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return nil
+			}
+			b.found(entry)
+		case <-b.now:
+			cancel()
+			// drain, zeroconf closes entries once it has stopped
+			for range entries {
+			}
+			return nil
+		}
+	}
+}
+
+func (b *Browser) found(entry *zeroconf.ServiceEntry) {
+	if len(entry.AddrIPv4) == 0 {
+		return
+	}
+	name := strings.Split(entry.HostName, ".")[0]
+	if name == "" {
+		name = entry.Instance
+	}
+	robot := Robot{Name: name, IP: entry.AddrIPv4[0].String(), LastSeen: time.Now()}
+	robot.ESN, robot.Authenticated = b.Match(name)
+	b.mu.Lock()
+	old, seen := b.robots[name]
+	b.robots[name] = robot
+	b.mu.Unlock()
+	if !seen || old.IP != robot.IP {
+		discoveryLog.Robot(robot.ESN).Info("Found " + name + " at " + robot.IP)
+	}
+	if b.OnFound != nil {
+		b.OnFound(robot)
+	}
+}
+
+var esnPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// ProbeESN reads the ESN from the certificate a robot serves on port 443, for robots the pod hasn't seen yet
+func ProbeESN(ip string) (string, error) {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, "443"), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	for _, cert := range conn.ConnectionState().PeerCertificates {
+		for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+			if esn := strings.ToLower(strings.TrimSpace(name)); esnPattern.MatchString(esn) {
+				return esn, nil
+			}
+		}
+	}
+	return "", errors.New("the robot's certificate doesn't contain its ESN")
+}
+
+//go:embed discovery.html
+var page []byte
+
+// ServePage lists the robots found, with a button to add the ones which aren't set up
+func ServePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
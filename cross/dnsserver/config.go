@@ -0,0 +1,88 @@
+package dnsserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// a unicast DNS server for networks which filter multicast, so mDNS never reaches the robots.
+// the router's DNS override (or the robot's network's DNS server) points at the pod, which answers
+// for escapepod.local and the configured mDNS name, and forwards or refuses everything else.
+
+var ConfigName = "dns.json"
+
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// port 53 is what routers and robots use. it needs admin rights on linux and macOS.
+	Port string `json:"port"`
+	// more names to answer for, besides escapepod.local and the mDNS hostname
+	Names []string `json:"names,omitempty"`
+	// host:port of the DNS server other questions are forwarded to. empty refuses them.
+	Upstream string `json:"upstream,omitempty"`
+	TTL      uint32 `json:"ttl"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Port: "53",
+		TTL:  60,
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig returns the defaults if the file doesn't exist
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := conf.Validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, nil
+}
+
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(podDir), data, 0644)
+}
+
+func (c Config) Validate() error {
+	port, err := strconv.Atoi(c.Port)
+	if err != nil || port < 0 || port > 65535 {
+		return fmt.Errorf("port %q is invalid", c.Port)
+	}
+	if c.TTL < 1 {
+		return errors.New("ttl must be at least one second")
+	}
+	for _, name := range c.Names {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " /") {
+			return fmt.Errorf("name %q is invalid", name)
+		}
+	}
+	if c.Upstream != "" {
+		if _, _, err := net.SplitHostPort(c.Upstream); err != nil {
+			return fmt.Errorf("upstream %q must be host:port, e.g. 1.1.1.1:53", c.Upstream)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package dnsserver
+
+import (
+	_ "embed"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/miekg/dns"
+)
+
+var dnsLog = logs.For("dns")
+
+type Status struct {
+	Running   bool     `json:"running"`
+	Addr      string   `json:"addr"`
+	Names     []string `json:"names"`
+	IP        string   `json:"ip"`
+	Answered  int      `json:"answered"`
+	Forwarded int      `json:"forwarded"`
+	Refused   int      `json:"refused"`
+	LastError string   `json:"lasterror,omitempty"`
+}
+
+type Server struct {
+	// the names answered for besides the config's, e.g. escapepod.local. called per query so changes apply.
+	Names func() []string
+	// the address answered with
+	IP func() string
+
+	mu     sync.Mutex
+	conf   Config
+	udp    *dns.Server
+	tcp    *dns.Server
+	addr   string
+	status Status
+}
+
+func NewServer(conf Config) *Server {
+	return &Server{conf: conf}
+}
+
+// SetConfig applies the names, upstream and TTL right away. the port only changes with the next Start.
+func (s *Server) SetConfig(conf Config) {
+	s.mu.Lock()
+	s.conf = conf
+	s.mu.Unlock()
+}
+
+func (s *Server) Config() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conf
+}
+
+func (s *Server) names() []string {
+	var names []string
+	if s.Names != nil {
+		names = s.Names()
+	}
+	return append(names, s.Config().Names...)
+}
+
+// Start listens on addr (":53", or "127.0.0.1:0" for a random port) over UDP and TCP
+func (s *Server) Start(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+	// TCP on the same port as UDP, which matters when addr has port 0
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		s.fail(err)
+		return err
+	}
+	// Shutdown does nothing to a dns.Server which hasn't started yet, so both are waited for
+	started := make(chan error, 4)
+	udp := &dns.Server{PacketConn: pc, Handler: s, NotifyStartedFunc: func() { started <- nil }}
+	tcp := &dns.Server{Listener: l, Handler: s, NotifyStartedFunc: func() { started <- nil }}
+	go s.serve(udp, started)
+	go s.serve(tcp, started)
+	for i := 0; i < 2; i++ {
+		if err := <-started; err != nil {
+			udp.Shutdown()
+			tcp.Shutdown()
+			pc.Close()
+			l.Close()
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.udp, s.tcp = udp, tcp
+	s.addr = pc.LocalAddr().String()
+	s.status.Running = true
+	s.status.LastError = ""
+	s.mu.Unlock()
+	dnsLog.Info("DNS server listening on " + s.addr)
+	return nil
+}
+
+func (s *Server) serve(srv *dns.Server, started chan<- error) {
+	if err := srv.ActivateAndServe(); err != nil {
+		s.fail(err)
+		started <- err
+	}
+}
+
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	udp, tcp := s.udp, s.tcp
+	s.udp, s.tcp = nil, nil
+	s.status.Running = false
+	s.mu.Unlock()
+	if udp != nil {
+		udp.Shutdown()
+	}
+	if tcp != nil {
+		tcp.Shutdown()
+	}
+}
+
+// Addr is where the server listens, e.g. to point a client at it after Start("127.0.0.1:0")
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+func (s *Server) Status() Status {
+	names := s.names()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status
+	st.Addr = s.addr
+	st.Names = names
+	if s.IP != nil {
+		st.IP = s.IP()
+	}
+	return st
+}
+
+func (s *Server) fail(err error) {
+	dnsLog.Error("DNS server error: " + err.Error())
+	s.mu.Lock()
+	s.status.LastError = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *Server) count(field *int) {
+	s.mu.Lock()
+	*field++
+	s.mu.Unlock()
+}
+
+func (s *Server) ours(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, n := range s.names() {
+		if strings.TrimSuffix(strings.ToLower(n), ".") == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) != 1 {
+		s.reply(w, req, dns.RcodeFormatError)
+		return
+	}
+	q := req.Question[0]
+	conf := s.Config()
+	if s.ours(q.Name) {
+		s.answer(w, req, q, conf.TTL)
+		return
+	}
+	if conf.Upstream == "" {
+		s.count(&s.status.Refused)
+		s.reply(w, req, dns.RcodeRefused)
+		return
+	}
+	client := &dns.Client{Net: "udp", Timeout: 3 * time.Second}
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		client.Net = "tcp"
+	}
+	resp, _, err := client.Exchange(req, conf.Upstream)
+	if err != nil {
+		dnsLog.Warn("Forwarding " + q.Name + " to " + conf.Upstream + " failed: " + err.Error())
+		s.reply(w, req, dns.RcodeServerFailure)
+		return
+	}
+	s.count(&s.status.Forwarded)
+	w.WriteMsg(resp)
+}
+
+// an A record for our names. other types get an empty answer, so the client falls back to A.
+func (s *Server) answer(w dns.ResponseWriter, req *dns.Msg, q dns.Question, ttl uint32) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	ip := net.ParseIP(s.IP()).To4()
+	if (q.Qtype == dns.TypeA || q.Qtype == dns.TypeANY) && ip != nil {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   ip,
+		})
+	}
+	s.count(&s.status.Answered)
+	w.WriteMsg(m)
+}
+
+func (s *Server) reply(w dns.ResponseWriter, req *dns.Msg, rcode int) {
+	m := new(dns.Msg)
+	m.SetRcode(req, rcode)
+	w.WriteMsg(m)
+}
+
+// Lookup asks the server at addr for name's A record, the way a robot would. for checking a running server.
+func Lookup(addr, name string) ([]net.IP, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	client := &dns.Client{Timeout: 3 * time.Second}
+	resp, _, err := client.Exchange(m, addr)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s: %s", name, dns.RcodeToString[resp.Rcode])
+	}
+	var ips []net.IP
+	for _, rr := range resp.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			ips = append(ips, a.A)
+		}
+	}
+	return ips, nil
+}
+
+//go:embed dns.html
+var instructionsPage []byte
+
+// ServeInstructions is the page with the server's status, settings and how to point a router at it
+func ServeInstructions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(instructionsPage)
+}
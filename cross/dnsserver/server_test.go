@@ -0,0 +1,196 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testIP = "192.168.1.50"
+
+func startServer(t *testing.T, conf Config) *Server {
+	t.Helper()
+	s := NewServer(conf)
+	s.Names = func() []string { return []string{"escapepod.local"} }
+	s.IP = func() string { return testIP }
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+// a stand-in for the router's DNS server, answering every A question with 93.184.216.34
+func startUpstream(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("93.184.216.34"),
+		})
+		w.WriteMsg(m)
+	})
+	for _, srv := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: l, Handler: handler}} {
+		srv := srv
+		go srv.ActivateAndServe()
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+	return pc.LocalAddr().String()
+}
+
+func exchange(t *testing.T, network, addr, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	client := &dns.Client{Net: network, Timeout: 5 * time.Second}
+	resp, _, err := client.Exchange(m, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func checkA(t *testing.T, resp *dns.Msg, ip string, ttl uint32) {
+	t.Helper()
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("got %s", resp)
+	}
+	a, ok := resp.Answer[0].(*dns.A)
+	if !ok || a.A.String() != ip || a.Hdr.Ttl != ttl {
+		t.Errorf("got %s", resp.Answer[0])
+	}
+}
+
+func TestAnswersOurNames(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Names = []string{"vector.lan"}
+	conf.TTL = 30
+	s := startServer(t, conf)
+	for _, network := range []string{"udp", "tcp"} {
+		for _, name := range []string{"escapepod.local", "EscapePod.Local.", "vector.lan"} {
+			t.Run(network+" "+name, func(t *testing.T) {
+				resp := exchange(t, network, s.Addr(), name, dns.TypeA)
+				checkA(t, resp, testIP, 30)
+				if !resp.Authoritative {
+					t.Error("not authoritative")
+				}
+			})
+		}
+	}
+	// no AAAA, but the name exists so robots fall back to A
+	resp := exchange(t, "udp", s.Addr(), "escapepod.local", dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Errorf("AAAA got %s", resp)
+	}
+	if st := s.Status(); st.Answered != 7 || st.Refused != 0 || !st.Running || st.IP != testIP {
+		t.Errorf("status %+v", st)
+	}
+}
+
+func TestRefusesWithoutUpstream(t *testing.T) {
+	s := startServer(t, DefaultConfig())
+	if resp := exchange(t, "udp", s.Addr(), "example.com", dns.TypeA); resp.Rcode != dns.RcodeRefused {
+		t.Errorf("got %s", dns.RcodeToString[resp.Rcode])
+	}
+	if st := s.Status(); st.Refused != 1 {
+		t.Errorf("status %+v", st)
+	}
+}
+
+func TestForwards(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Upstream = startUpstream(t)
+	s := startServer(t, conf)
+	for _, network := range []string{"udp", "tcp"} {
+		checkA(t, exchange(t, network, s.Addr(), "example.com", dns.TypeA), "93.184.216.34", 300)
+	}
+	// ours are still answered here
+	checkA(t, exchange(t, "udp", s.Addr(), "escapepod.local", dns.TypeA), testIP, conf.TTL)
+	if st := s.Status(); st.Forwarded != 2 || st.Answered != 1 {
+		t.Errorf("status %+v", st)
+	}
+}
+
+func TestUpstreamDown(t *testing.T) {
+	// a port nothing listens on
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := DefaultConfig()
+	conf.Upstream = pc.LocalAddr().String()
+	pc.Close()
+	s := startServer(t, conf)
+	if resp := exchange(t, "udp", s.Addr(), "example.com", dns.TypeA); resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("got %s", dns.RcodeToString[resp.Rcode])
+	}
+}
+
+// names are changed without a restart
+func TestSetConfig(t *testing.T) {
+	s := startServer(t, DefaultConfig())
+	conf := DefaultConfig()
+	conf.Names = []string{"pod.home"}
+	s.SetConfig(conf)
+	checkA(t, exchange(t, "udp", s.Addr(), "pod.home", dns.TypeA), testIP, conf.TTL)
+}
+
+func TestLookup(t *testing.T) {
+	s := startServer(t, DefaultConfig())
+	ips, err := Lookup(s.Addr(), "escapepod.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || ips[0].String() != testIP {
+		t.Errorf("got %v", ips)
+	}
+	if _, err := Lookup(s.Addr(), "example.com"); err == nil {
+		t.Error("a refused name was looked up")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s := startServer(t, DefaultConfig())
+	addr := s.Addr()
+	s.Shutdown()
+	if s.Status().Running {
+		t.Error("still running")
+	}
+	client := &dns.Client{Net: "tcp", Timeout: time.Second}
+	m := new(dns.Msg)
+	m.SetQuestion("escapepod.local.", dns.TypeA)
+	if _, _, err := client.Exchange(m, addr); err == nil {
+		t.Error("answered after Shutdown")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		conf Config
+		ok   bool
+	}{
+		{DefaultConfig(), true},
+		{Config{Port: "5353", TTL: 1, Names: []string{"pod.home"}, Upstream: "1.1.1.1:53"}, true},
+		{Config{Port: "dns", TTL: 60}, false},
+		{Config{Port: "70000", TTL: 60}, false},
+		{Config{Port: "53", TTL: 0}, false},
+		{Config{Port: "53", TTL: 60, Names: []string{"a b"}}, false},
+		{Config{Port: "53", TTL: 60, Upstream: "1.1.1.1"}, false},
+	} {
+		if err := tt.conf.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: %v", tt.conf, err)
+		}
+	}
+}
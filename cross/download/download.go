@@ -0,0 +1,225 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File downloads into <dest>.part and renames it to dest once complete and verified.
+// a .part left by an earlier attempt is resumed with an HTTP range request, and a dropped
+// connection is retried from where it stopped. servers which ignore the range get a full download.
+
+const PartSuffix = ".part"
+
+type Options struct {
+	// hex sha256 the file must match. empty skips the check.
+	SHA256 string
+	// total is -1 when the server doesn't say
+	Progress func(done, total int64)
+	// attempts after the first one, default 3
+	Retries int
+	// pause before retry n is n*RetryDelay, default 1s
+	RetryDelay time.Duration
+	Client     *http.Client
+}
+
+// ChecksumError is returned when a finished download doesn't match Options.SHA256
+type ChecksumError struct {
+	URL, Expected, Got string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.URL, e.Expected, e.Got)
+}
+
+// a failure retrying won't fix, e.g. a 404
+type permanentError struct{ error }
+
+func File(url, dest string, opts Options) error {
+	if opts.Retries == 0 {
+		opts.Retries = 3
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	part := dest + PartSuffix
+	restarted := false
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * opts.RetryDelay)
+		}
+		err = fetch(url, part, opts)
+		var perm permanentError
+		if errors.As(err, &perm) {
+			return perm.error
+		}
+		if err != nil {
+			continue
+		}
+		err = VerifyFile(part, opts.SHA256)
+		var sumErr *ChecksumError
+		if errors.As(err, &sumErr) {
+			os.Remove(part)
+			sumErr.URL = url
+			// a stale .part from another release can't be told apart until the end, so start over once
+			if !restarted {
+				restarted = true
+				attempt--
+				continue
+			}
+			return sumErr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Rename(part, dest)
+	}
+	return fmt.Errorf("error downloading %s: %s", url, err)
+}
+
+// fetch appends whatever is missing from part
+func fetch(url, part string, opts Options) error {
+	var have int64
+	if info, err := os.Stat(part); err == nil {
+		have = info.Size()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return permanentError{err}
+	}
+	if have > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(have, 10)+"-")
+	}
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	total := int64(-1)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && have > 0:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != have {
+			// not the range we asked for, don't trust it
+			os.Remove(part)
+			return fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		flags |= os.O_APPEND
+		total = size
+	case resp.StatusCode == http.StatusOK:
+		flags |= os.O_TRUNC
+		have = 0
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && have > 0:
+		// usually means part is already complete. the checksum decides.
+		return nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return permanentError{fmt.Errorf("error downloading %s: %s", url, resp.Status)}
+	}
+
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return permanentError{err}
+	}
+	defer out.Close()
+	done := have
+	if opts.Progress != nil {
+		opts.Progress(done, total)
+	}
+	buffer := make([]byte, 32*1024)
+	for {
+		n, rerr := resp.Body.Read(buffer)
+		if n > 0 {
+			if _, err := out.Write(buffer[:n]); err != nil {
+				return permanentError{err}
+			}
+			done += int64(n)
+			if opts.Progress != nil {
+				opts.Progress(done, total)
+			}
+		}
+		if rerr == io.EOF {
+			if total >= 0 && done < total {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+// "bytes 100-199/200" -> 100, 200. the size is -1 for "*".
+func parseContentRange(header string) (start, size int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, sizeStr, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	startStr, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size = -1
+	if sizeStr != "*" {
+		if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
+
+func SHA256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyFile checks path against a hex sha256. an empty expected sum accepts anything.
+func VerifyFile(path, expected string) error {
+	expected = strings.TrimSpace(expected)
+	if expected == "" {
+		return nil
+	}
+	sum, err := SHA256File(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, expected) {
+		return &ChecksumError{URL: path, Expected: expected, Got: sum}
+	}
+	return nil
+}
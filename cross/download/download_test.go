@@ -0,0 +1,290 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serves one file with range support, and can misbehave on purpose
+type testServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	data []byte
+	// the first dropAfter requests send only dropBytes of the body and then cut the connection
+	dropBytes int
+	dropAfter int
+	// ignore Range headers, like some mirrors do
+	noRanges bool
+	// answer with this status instead, 0 serves the file
+	status int
+	// every request's Range header, "" when there was none
+	ranges []string
+}
+
+func newTestServer(t *testing.T, data []byte) *testServer {
+	ts := &testServer{data: data}
+	ts.Server = httptest.NewServer(http.HandlerFunc(ts.serve))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	ts.ranges = append(ts.ranges, r.Header.Get("Range"))
+	drop := ts.dropAfter > 0 && ts.dropBytes > 0
+	if drop {
+		ts.dropAfter--
+	}
+	noRanges, status := ts.noRanges, ts.status
+	ts.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	if noRanges {
+		r.Header.Del("Range")
+	}
+	if !drop {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(ts.data))
+		return
+	}
+	// the headers of a full response, part of the body, then hang up
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, r, "file", time.Time{}, bytes.NewReader(ts.data))
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.Code)
+	body := rec.Body.Bytes()
+	if len(body) > ts.dropBytes {
+		body = body[:ts.dropBytes]
+	}
+	w.Write(body)
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func (ts *testServer) fileURL() string {
+	return ts.URL + "/wire-pod-win-amd64.zip"
+}
+
+func (ts *testServer) requests() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.ranges...)
+}
+
+// 200KB that isn't the same all the way through, so a wrong offset shows
+func testData() []byte {
+	data := make([]byte, 200*1024)
+	for i := range data {
+		data[i] = byte(i * 7 / 3)
+	}
+	return data
+}
+
+func sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func testOptions(data []byte) Options {
+	return Options{SHA256: sum(data), RetryDelay: time.Millisecond}
+}
+
+func checkFile(t *testing.T, dest string, data []byte) {
+	t.Helper()
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("downloaded %d bytes which don't match the %d served", len(got), len(data))
+	}
+	if _, err := os.Stat(dest + PartSuffix); !os.IsNotExist(err) {
+		t.Error("the .part file was left behind")
+	}
+}
+
+func TestFile(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	var last, total int64
+	opts := testOptions(data)
+	opts.Progress = func(done, t int64) { last, total = done, t }
+	if err := File(ts.fileURL(), dest, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dest, data)
+	if last != int64(len(data)) || total != int64(len(data)) {
+		t.Errorf("progress ended at %d of %d", last, total)
+	}
+}
+
+// a .part from an earlier run is picked up where it stopped
+func TestResume(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	if err := os.WriteFile(dest+PartSuffix, data[:50000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(ts.fileURL(), dest, testOptions(data)); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dest, data)
+	if got := ts.requests(); len(got) != 1 || got[0] != "bytes=50000-" {
+		t.Errorf("requests had ranges %q", got)
+	}
+}
+
+// a dropped connection is retried from where it dropped
+func TestResumeAfterDrop(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	ts.dropBytes, ts.dropAfter = 30000, 2
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	if err := File(ts.fileURL(), dest, testOptions(data)); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dest, data)
+	want := []string{"", "bytes=30000-", "bytes=60000-"}
+	if got := ts.requests(); len(got) != len(want) || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("requests had ranges %q, want %q", got, want)
+	}
+}
+
+// a server which ignores the range sends everything, which replaces the .part
+func TestResumeWithoutRanges(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	ts.noRanges = true
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	if err := os.WriteFile(dest+PartSuffix, data[:50000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(ts.fileURL(), dest, testOptions(data)); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dest, data)
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	opts := testOptions(data)
+	opts.SHA256 = sum([]byte("another release"))
+	err := File(ts.fileURL(), dest, opts)
+	var sumErr *ChecksumError
+	if !errors.As(err, &sumErr) {
+		t.Fatalf("got %v", err)
+	}
+	if sumErr.URL != ts.fileURL() || sumErr.Got != sum(data) {
+		t.Errorf("got %+v", sumErr)
+	}
+	for _, p := range []string{dest, dest + PartSuffix} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", p)
+		}
+	}
+	// the second try is a full download, in case the first resumed a stale .part
+	if got := ts.requests(); len(got) != 2 {
+		t.Errorf("%d requests, want 2", len(got))
+	}
+}
+
+// a .part of another release only shows at the end, and the download starts over
+func TestStalePart(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	if err := os.WriteFile(dest+PartSuffix, bytes.Repeat([]byte{1}, 50000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(ts.fileURL(), dest, testOptions(data)); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dest, data)
+}
+
+func TestRedirects(t *testing.T) {
+	data := testData()
+	ts := newTestServer(t, data)
+	// like GitHub's releases, which redirect to a CDN
+	redirects := 0
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirects++
+		http.Redirect(w, r, ts.fileURL(), http.StatusFound)
+	}))
+	defer redirect.Close()
+	dest := filepath.Join(t.TempDir(), "wire-pod.zip")
+	if err := os.WriteFile(dest+PartSuffix, data[:50000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(redirect.URL+"/latest/wire-pod.zip", dest, testOptions(data)); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dest, data)
+	// the range goes along to where it's redirected
+	if got := ts.requests(); redirects != 1 || len(got) != 1 || got[0] != "bytes=50000-" {
+		t.Errorf("%d redirects, ranges %q", redirects, got)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.status = http.StatusNotFound
+	if err := File(ts.fileURL(), filepath.Join(t.TempDir(), "wire-pod.zip"), testOptions(nil)); err == nil {
+		t.Fatal("a 404 downloaded")
+	}
+	if got := ts.requests(); len(got) != 1 {
+		t.Errorf("a 404 was tried %d times", len(got))
+	}
+}
+
+func TestServerErrorRetries(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.status = http.StatusServiceUnavailable
+	opts := testOptions(nil)
+	opts.Retries = 2
+	if err := File(ts.fileURL(), filepath.Join(t.TempDir(), "wire-pod.zip"), opts); err == nil {
+		t.Fatal("a 503 downloaded")
+	}
+	if got := ts.requests(); len(got) != 3 {
+		t.Errorf("a 503 was tried %d times, want 3", len(got))
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header      string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */200", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, size, ok := parseContentRange(tt.header)
+		if start != tt.start || size != tt.size || ok != tt.ok {
+			t.Errorf("%q: got %d, %d, %v", tt.header, start, size, ok)
+		}
+	}
+}
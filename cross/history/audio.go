@@ -0,0 +1,19 @@
+package history
+
+import (
+	"github.com/digital-dream-labs/opus-go/opus"
+)
+
+// IsOpus is whether audio is an Ogg Opus stream rather than PCM, the way chipper tells them apart
+func IsOpus(audio []byte) bool {
+	return len(audio) > 0 && audio[0] == 0x4f
+}
+
+// PCM decodes audio as a robot sent it to 16 kHz 16-bit PCM
+func PCM(audio []byte) ([]byte, error) {
+	if !IsOpus(audio) {
+		return audio, nil
+	}
+	var stream opus.OggStream
+	return stream.Decode(audio)
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// how long voice requests are kept. the history is pruned when the pod starts and every hour.
+
+var ConfigName = "history.json"
+
+// DBName is the database's file name in the pod directory
+var DBName = "history.db"
+
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// requests older than this are deleted. 0 keeps them forever.
+	MaxAgeDays int `json:"maxagedays"`
+	// the oldest requests are deleted once the database is bigger than this. 0 means no limit.
+	MaxSizeMB int `json:"maxsizemb"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:    true,
+		MaxAgeDays: 90,
+		MaxSizeMB:  50,
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig returns the defaults if the file doesn't exist
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := conf.Validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, nil
+}
+
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(podDir), data, 0644)
+}
+
+func (c Config) Validate() error {
+	if c.MaxAgeDays < 0 {
+		return errors.New("maximum age can't be negative")
+	}
+	if c.MaxSizeMB < 0 {
+		return errors.New("maximum size can't be negative")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package history
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteCSV writes records with a header row. params are written as key=value pairs separated by ;
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "time", "esn", "kind", "transcript", "intent", "params", "response", "engine", "latency_ms", "duration_ms", "error", "audio"})
+	for _, r := range records {
+		cw.Write([]string{
+			strconv.FormatInt(r.ID, 10),
+			r.Time.Format(time.RFC3339),
+			r.ESN,
+			r.Kind,
+			r.Transcript,
+			r.Intent,
+			paramsText(r.Params),
+			r.Response,
+			r.Engine,
+			strconv.FormatInt(r.LatencyMs, 10),
+			strconv.FormatInt(r.DurationMs, 10),
+			r.Error,
+			r.Audio,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteJSON(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func paramsText(params map[string]string) string {
+	var pairs []string
+	for k, v := range params {
+		if k == "" && v == "" {
+			continue
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+//go:embed history.html
+var page []byte
+
+// ServePage is the searchable history, with export and retention settings
+func ServePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
@@ -0,0 +1,324 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/wire-pod/chipper/pkg/vtt"
+	sr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/speechrequest"
+)
+
+var historyLog = logs.For("history")
+
+// the processor chipper is handed, the pod's voice processor with anything in front of it
+type VoiceProcessor interface {
+	ProcessIntent(*vtt.IntentRequest) (*vtt.IntentResponse, error)
+	ProcessIntentGraph(*vtt.IntentGraphRequest) (*vtt.IntentGraphResponse, error)
+	ProcessKnowledgeGraph(*vtt.KnowledgeGraphRequest) (*vtt.KnowledgeGraphResponse, error)
+}
+
+// Recorder puts every request it sees through in the store
+type Recorder struct {
+	Store *Store
+	// the speech-to-text engine's name, e.g. vosk. use SetEngine once requests are coming in.
+	Engine string
+	// whether to record, so it can be switched off without a restart
+	Enabled func() bool
+	// whether to keep the audio of requests from esn. nil keeps none.
+	CaptureAudio func(esn string) bool
+	// stores a request's audio as the robot sent it and returns the file's name
+	SaveAudio func(esn string, t time.Time, audio []byte) (string, error)
+	// called with every request once it is done, whether or not it is recorded
+	Notify func(Record)
+
+	mu sync.Mutex
+	// transcripts from the wrapped STT handler, by ESN, until the request they belong to is done
+	transcripts map[string]transcript
+}
+
+type transcript struct {
+	text string
+	err  string
+}
+
+func (r *Recorder) enabled() bool {
+	return r.Store != nil && (r.Enabled == nil || r.Enabled())
+}
+
+// whether requests need following, to record them or to notify
+func (r *Recorder) watching() bool {
+	return r.enabled() || r.Notify != nil
+}
+
+// WrapSTT records what the STT handler heard. handlers of the intent-only kind (Rhino) are returned as they are.
+func (r *Recorder) WrapSTT(handler interface{}) interface{} {
+	stt, ok := handler.(func(sr.SpeechRequest) (string, error))
+	if !ok {
+		return handler
+	}
+	return func(req sr.SpeechRequest) (string, error) {
+		text, err := stt(req)
+		t := transcript{text: text}
+		if err != nil {
+			t.err = err.Error()
+		}
+		r.mu.Lock()
+		if r.transcripts == nil {
+			r.transcripts = make(map[string]transcript)
+		}
+		r.transcripts[req.Device] = t
+		r.mu.Unlock()
+		return text, err
+	}
+}
+
+// SetEngine changes the engine name records get, after an STT engine switch
+func (r *Recorder) SetEngine(name string) {
+	r.mu.Lock()
+	r.Engine = name
+	r.mu.Unlock()
+}
+
+func (r *Recorder) engine() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Engine
+}
+
+func (r *Recorder) takeTranscript(esn string) transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.transcripts[esn]
+	delete(r.transcripts, esn)
+	return t
+}
+
+// a request in progress. the wrapped streams fill it in as responses are sent.
+type pending struct {
+	mu     sync.Mutex
+	rec    Record
+	start  time.Time
+	answer time.Time
+	// the audio as the robot sent it, only kept when it is captured
+	capture bool
+	audio   []byte
+}
+
+func (p *pending) received(audio []byte) {
+	if !p.capture {
+		return
+	}
+	p.mu.Lock()
+	p.audio = append(p.audio, audio...)
+	p.mu.Unlock()
+}
+
+func (p *pending) result(query, intent string, params map[string]string, spoken string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.answer.IsZero() {
+		p.answer = time.Now()
+	}
+	if query != "" {
+		p.rec.Transcript = query
+	}
+	if intent != "" {
+		p.rec.Intent = intent
+	}
+	if len(params) > 0 {
+		p.rec.Params = params
+	}
+	if spoken != "" {
+		p.rec.Response += spoken
+	}
+}
+
+func (r *Recorder) finish(p *pending, err error) {
+	t := r.takeTranscript(p.rec.ESN)
+	p.mu.Lock()
+	rec := p.rec
+	answer := p.answer
+	audio := p.audio
+	p.mu.Unlock()
+	if rec.Transcript == "" {
+		rec.Transcript = t.text
+	}
+	if t.err != "" {
+		rec.Error = t.err
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	now := time.Now()
+	if answer.IsZero() {
+		answer = now
+	}
+	rec.LatencyMs = answer.Sub(p.start).Milliseconds()
+	rec.DurationMs = now.Sub(p.start).Milliseconds()
+	rec.Engine = r.engine()
+	if r.Notify != nil {
+		r.Notify(rec)
+	}
+	if !r.enabled() {
+		return
+	}
+	if len(audio) > 0 && r.SaveAudio != nil {
+		name, err := r.SaveAudio(rec.ESN, rec.Time, audio)
+		if err != nil {
+			historyLog.Robot(rec.ESN).Warn("Error saving request audio: " + err.Error())
+		} else {
+			rec.Audio = name
+		}
+	}
+	if err := r.Store.Add(rec); err != nil {
+		historyLog.Robot(rec.ESN).Warn("Error recording request: " + err.Error())
+	}
+}
+
+func (r *Recorder) begin(kind, esn string, start time.Time, first []byte) *pending {
+	if start.IsZero() {
+		start = time.Now()
+	}
+	p := &pending{rec: Record{Time: start, ESN: esn, Kind: kind}, start: start}
+	p.capture = r.enabled() && r.CaptureAudio != nil && r.CaptureAudio(esn)
+	p.received(first)
+	return p
+}
+
+type intentStream struct {
+	pb.ChipperGrpc_StreamingIntentServer
+	p *pending
+}
+
+func (s intentStream) Recv() (*pb.StreamingIntentRequest, error) {
+	req, err := s.ChipperGrpc_StreamingIntentServer.Recv()
+	if err == nil {
+		s.p.received(req.InputAudio)
+	}
+	return req, err
+}
+
+func (s intentStream) Send(resp *pb.IntentResponse) error {
+	if res := resp.GetIntentResult(); res != nil {
+		s.p.result(res.QueryText, res.Action, res.Parameters, "")
+	}
+	return s.ChipperGrpc_StreamingIntentServer.Send(resp)
+}
+
+type intentGraphStream struct {
+	pb.ChipperGrpc_StreamingIntentGraphServer
+	p *pending
+}
+
+func (s intentGraphStream) Recv() (*pb.StreamingIntentGraphRequest, error) {
+	req, err := s.ChipperGrpc_StreamingIntentGraphServer.Recv()
+	if err == nil {
+		s.p.received(req.InputAudio)
+	}
+	return req, err
+}
+
+func (s intentGraphStream) Send(resp *pb.IntentGraphResponse) error {
+	if res := resp.GetIntentResult(); res != nil {
+		s.p.result(res.QueryText, res.Action, res.Parameters, resp.SpokenText)
+	} else {
+		s.p.result(resp.QueryText, "", nil, resp.SpokenText)
+	}
+	return s.ChipperGrpc_StreamingIntentGraphServer.Send(resp)
+}
+
+type knowledgeGraphStream struct {
+	pb.ChipperGrpc_StreamingKnowledgeGraphServer
+	p *pending
+}
+
+func (s knowledgeGraphStream) Recv() (*pb.StreamingKnowledgeGraphRequest, error) {
+	req, err := s.ChipperGrpc_StreamingKnowledgeGraphServer.Recv()
+	if err == nil {
+		s.p.received(req.InputAudio)
+	}
+	return req, err
+}
+
+func (s knowledgeGraphStream) Send(resp *pb.KnowledgeGraphResponse) error {
+	s.p.result(resp.QueryText, "", nil, resp.SpokenText)
+	return s.ChipperGrpc_StreamingKnowledgeGraphServer.Send(resp)
+}
+
+// Processor records requests on their way to Next
+type Processor struct {
+	Next     VoiceProcessor
+	Recorder *Recorder
+}
+
+func (p Processor) ProcessIntent(req *vtt.IntentRequest) (*vtt.IntentResponse, error) {
+	if !p.Recorder.watching() {
+		return p.Next.ProcessIntent(req)
+	}
+	pend := p.Recorder.begin(KindIntent, req.Device, req.Time, req.FirstReq.GetInputAudio())
+	req.Stream = intentStream{ChipperGrpc_StreamingIntentServer: req.Stream, p: pend}
+	resp, err := p.Next.ProcessIntent(req)
+	p.Recorder.finish(pend, err)
+	return resp, err
+}
+
+func (p Processor) ProcessIntentGraph(req *vtt.IntentGraphRequest) (*vtt.IntentGraphResponse, error) {
+	if !p.Recorder.watching() {
+		return p.Next.ProcessIntentGraph(req)
+	}
+	pend := p.Recorder.begin(KindIntentGraph, req.Device, req.Time, req.FirstReq.GetInputAudio())
+	req.Stream = intentGraphStream{ChipperGrpc_StreamingIntentGraphServer: req.Stream, p: pend}
+	resp, err := p.Next.ProcessIntentGraph(req)
+	p.Recorder.finish(pend, err)
+	return resp, err
+}
+
+func (p Processor) ProcessKnowledgeGraph(req *vtt.KnowledgeGraphRequest) (*vtt.KnowledgeGraphResponse, error) {
+	if !p.Recorder.watching() {
+		return p.Next.ProcessKnowledgeGraph(req)
+	}
+	pend := p.Recorder.begin(KindKnowledgeGraph, req.Device, req.Time, req.FirstReq.GetInputAudio())
+	pend.rec.Intent = "knowledge_graph"
+	req.Stream = knowledgeGraphStream{ChipperGrpc_StreamingKnowledgeGraphServer: req.Stream, p: pend}
+	resp, err := p.Next.ProcessKnowledgeGraph(req)
+	p.Recorder.finish(pend, err)
+	return resp, err
+}
+
+// ChipperServer records TextIntent calls, which chipper doesn't implement, before passing them on
+type ChipperServer struct {
+	pb.ChipperGrpcServer
+	Recorder *Recorder
+}
+
+func (s ChipperServer) TextIntent(ctx context.Context, req *pb.TextRequest) (*pb.IntentResponse, error) {
+	start := time.Now()
+	resp, err := s.ChipperGrpcServer.TextIntent(ctx, req)
+	if !s.Recorder.watching() {
+		return resp, err
+	}
+	rec := Record{Time: start, ESN: req.GetDeviceId(), Kind: KindText, Transcript: strings.TrimSpace(req.GetTextInput()), Engine: "text"}
+	if res := resp.GetIntentResult(); res != nil {
+		rec.Intent = res.Action
+		rec.Params = res.Parameters
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	rec.LatencyMs = time.Since(start).Milliseconds()
+	rec.DurationMs = rec.LatencyMs
+	if s.Recorder.Notify != nil {
+		s.Recorder.Notify(rec)
+	}
+	if !s.Recorder.enabled() {
+		return resp, err
+	}
+	if err := s.Recorder.Store.Add(rec); err != nil {
+		historyLog.Robot(rec.ESN).Warn("Error recording text request: " + err.Error())
+	}
+	return resp, err
+}
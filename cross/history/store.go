@@ -0,0 +1,241 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// the kinds of request recorded
+const (
+	KindIntent         = "intent"
+	KindIntentGraph    = "intent_graph"
+	KindKnowledgeGraph = "knowledge_graph"
+	KindText           = "text"
+)
+
+type Record struct {
+	ID         int64             `json:"id"`
+	Time       time.Time         `json:"time"`
+	ESN        string            `json:"esn"`
+	Kind       string            `json:"kind"`
+	Transcript string            `json:"transcript"`
+	Intent     string            `json:"intent"`
+	Params     map[string]string `json:"params,omitempty"`
+	// what the robot said, for knowledge graph and LLM responses
+	Response string `json:"response,omitempty"`
+	Engine   string `json:"engine"`
+	// from the start of the request until the robot got its answer
+	LatencyMs int64 `json:"latencyms"`
+	// from the start of the request until it was done, which includes streamed LLM responses
+	DurationMs int64  `json:"durationms"`
+	Error      string `json:"error,omitempty"`
+	// the file name of the request's audio, if it was captured
+	Audio string `json:"audio,omitempty"`
+}
+
+// Query filters records. empty fields match everything.
+type Query struct {
+	// matched against the transcript, intent and response
+	Text   string
+	ESN    string
+	Intent string
+	Since  time.Time
+	Until  time.Time
+	// newest first. 0 means 100.
+	Limit  int
+	Offset int
+}
+
+type Store struct {
+	db   *sql.DB
+	path string
+	mu   sync.Mutex
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS requests (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time INTEGER NOT NULL,
+	esn TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	transcript TEXT NOT NULL,
+	intent TEXT NOT NULL,
+	params TEXT NOT NULL,
+	response TEXT NOT NULL,
+	engine TEXT NOT NULL,
+	latency_ms INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	error TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS requests_time ON requests (time);
+CREATE INDEX IF NOT EXISTS requests_esn ON requests (esn);
+`
+
+// Open creates the database if it doesn't exist
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite only takes one writer, and the pod writes little
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// added with audio capture. fails with "duplicate column" on databases which have it.
+	db.Exec("ALTER TABLE requests ADD COLUMN audio TEXT NOT NULL DEFAULT ''")
+	return &Store{db: db, path: path}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Add(r Record) error {
+	params, _ := json.Marshal(r.Params)
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	_, err := s.db.Exec(`INSERT INTO requests (time, esn, kind, transcript, intent, params, response, engine, latency_ms, duration_ms, error, audio)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Time.UnixMilli(), r.ESN, r.Kind, r.Transcript, r.Intent, string(params), r.Response, r.Engine, r.LatencyMs, r.DurationMs, r.Error, r.Audio)
+	return err
+}
+
+func (q Query) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if q.Text != "" {
+		like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Text) + "%"
+		conds = append(conds, `(transcript LIKE ? ESCAPE '\' OR intent LIKE ? ESCAPE '\' OR response LIKE ? ESCAPE '\')`)
+		args = append(args, like, like, like)
+	}
+	if q.ESN != "" {
+		conds = append(conds, "esn = ?")
+		args = append(args, q.ESN)
+	}
+	if q.Intent != "" {
+		conds = append(conds, "intent = ?")
+		args = append(args, q.Intent)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, "time >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		conds = append(conds, "time < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func (s *Store) Search(q Query) ([]Record, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	where, args := q.where()
+	rows, err := s.db.Query(`SELECT id, time, esn, kind, transcript, intent, params, response, engine, latency_ms, duration_ms, error, audio
+		FROM requests`+where+` ORDER BY time DESC, id DESC LIMIT ? OFFSET ?`, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		var millis int64
+		var params string
+		if err := rows.Scan(&r.ID, &millis, &r.ESN, &r.Kind, &r.Transcript, &r.Intent, &params, &r.Response, &r.Engine, &r.LatencyMs, &r.DurationMs, &r.Error, &r.Audio); err != nil {
+			return nil, err
+		}
+		r.Time = time.UnixMilli(millis)
+		json.Unmarshal([]byte(params), &r.Params)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Count is how many records match, for paging
+func (s *Store) Count(q Query) (int, error) {
+	where, args := q.where()
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM requests"+where, args...).Scan(&n)
+	return n, err
+}
+
+// Size is the database's size in bytes
+func (s *Store) Size() (int64, error) {
+	var pages, pageSize int64
+	if err := s.db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
+}
+
+// Clear deletes every record
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.Exec("DELETE FROM requests"); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("VACUUM")
+	return err
+}
+
+// Prune deletes what conf doesn't keep and returns how many records were deleted
+func (s *Store) Prune(conf Config) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	if conf.MaxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -conf.MaxAgeDays)
+		res, err := s.db.Exec("DELETE FROM requests WHERE time < ?", cutoff.UnixMilli())
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	if conf.MaxSizeMB > 0 {
+		limit := int64(conf.MaxSizeMB) * 1024 * 1024
+		for {
+			size, err := s.Size()
+			if err != nil {
+				return deleted, err
+			}
+			if size <= limit {
+				break
+			}
+			var total int64
+			s.db.QueryRow("SELECT COUNT(*) FROM requests").Scan(&total)
+			if total == 0 {
+				break
+			}
+			// the oldest tenth, then VACUUM gives the space back
+			batch := total/10 + 1
+			res, err := s.db.Exec("DELETE FROM requests WHERE id IN (SELECT id FROM requests ORDER BY time ASC, id ASC LIMIT ?)", batch)
+			if err != nil {
+				return deleted, err
+			}
+			n, _ := res.RowsAffected()
+			deleted += n
+			if _, err := s.db.Exec("VACUUM"); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
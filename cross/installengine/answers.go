@@ -0,0 +1,140 @@
+package installengine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// an answer file holds the installer's choices for unattended installs, as JSON or INI:
+//
+//	{"where": "C:\\Program Files\\wire-pod", "webport": "8080", "runatstartup": true}
+//
+//	[install]
+//	where = C:\Program Files\wire-pod
+//	webport = 8080
+//	runatstartup = yes
+//
+// keys missing from the file keep the installer's defaults. unknown keys are an error, so typos don't go unnoticed.
+
+type Answers struct {
+	RunAtStartup    bool   `json:"runatstartup"`
+	AutoUpdate      bool   `json:"autoupdate"`
+	Where           string `json:"where"`
+	WebPort         string `json:"webport"`
+	SetHostnameEpod bool   `json:"sethostnameepod"`
+	// reboot when the hostname was changed. otherwise the installer exits with ExitRebootRequired.
+	Reboot bool `json:"reboot"`
+	// start wire-pod once installed, if no reboot is needed
+	StartAfterInstall bool `json:"startafterinstall"`
+	// defaults to wire-pod-install.log in the temp dir
+	LogFile string `json:"logfile"`
+	// base URL of a mirror holding manifest.json and the release zip
+	Mirror string `json:"mirror"`
+	// install from this zip instead of downloading
+	LocalZip string `json:"localzip"`
+}
+
+// DefaultAnswers match the defaults of the installer's window
+func DefaultAnswers(where string) Answers {
+	return Answers{
+		RunAtStartup: true,
+		Where:        where,
+		WebPort:      "8080",
+	}
+}
+
+// ParseAnswers reads JSON if the file is named .json or starts with '{', INI otherwise, on top of defaults
+func ParseAnswers(name string, data []byte, defaults Answers) (Answers, error) {
+	a := defaults
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if strings.EqualFold(filepath.Ext(name), ".json") || bytes.HasPrefix(trimmed, []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&a); err != nil {
+			return a, fmt.Errorf("error reading answer file: %s", err)
+		}
+		return a, nil
+	}
+	return a, parseINI(trimmed, &a)
+}
+
+func parseINI(data []byte, a *Answers) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.EqualFold(text, "[install]") {
+				return fmt.Errorf("answer file line %d: unknown section %s", line, text)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("answer file line %d: expected key = value", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if err := a.set(key, value); err != nil {
+			return fmt.Errorf("answer file line %d: %s", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (a *Answers) set(key, value string) error {
+	str := map[string]*string{
+		"where":    &a.Where,
+		"webport":  &a.WebPort,
+		"logfile":  &a.LogFile,
+		"mirror":   &a.Mirror,
+		"localzip": &a.LocalZip,
+	}
+	flags := map[string]*bool{
+		"runatstartup":      &a.RunAtStartup,
+		"autoupdate":        &a.AutoUpdate,
+		"sethostnameepod":   &a.SetHostnameEpod,
+		"reboot":            &a.Reboot,
+		"startafterinstall": &a.StartAfterInstall,
+	}
+	if s, ok := str[key]; ok {
+		*s = value
+		return nil
+	}
+	if b, ok := flags[key]; ok {
+		switch strings.ToLower(value) {
+		case "yes", "on":
+			*b = true
+		case "no", "off":
+			*b = false
+		default:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false, not %q", key, value)
+			}
+			*b = parsed
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown key %q", key)
+}
+
+// Validate applies the same rules as the installer's window
+func (a Answers) Validate() error {
+	if a.Where == "" || !ValidateInstallDirectory(a.Where) {
+		return fmt.Errorf("the install directory (%s) is invalid. its parent directory must exist", a.Where)
+	}
+	if !ValidateWebPort(a.WebPort) {
+		return fmt.Errorf("the web port (%s) is invalid. it must be an integer between 1000-65353", a.WebPort)
+	}
+	return nil
+}
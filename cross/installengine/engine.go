@@ -0,0 +1,293 @@
+package installengine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// the install engine turns Settings into a Plan of Steps. steps only touch the system through the
+// interfaces below, and every change they make is journaled so a failed install can be undone exactly.
+// the windows installer provides registry/netsh/ole implementations, the tests have in-memory ones.
+
+// Remove and RemoveAll must not fail if the path is already gone. Remove must fail on a directory which isn't empty.
+type FileSystem interface {
+	Exists(path string) bool
+	MkdirAll(path string) error
+	Rename(from, to string) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	WriteFile(path string, data []byte) error
+	TempFile(pattern string) (string, error)
+	// ExtractZip unpacks archive into dest, dropping stripPrefix from entry names. it returns the files it wrote.
+	ExtractZip(archive, dest, stripPrefix string, progress func(done, total int)) ([]string, error)
+}
+
+// KeyValueStore is the registry on windows. stores are named, e.g. "software" or "uninstall".
+type KeyValueStore interface {
+	Get(store, name string) (string, bool, error)
+	Set(store, name, value string) error
+	Delete(store, name string) error
+}
+
+type FirewallRule struct {
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	Program   string `json:"program"`
+}
+
+type Firewall interface {
+	AddRule(rule FirewallRule) error
+	RemoveRule(rule FirewallRule) error
+}
+
+type Shortcuts interface {
+	Create(path, target string) error
+	Remove(path string) error
+}
+
+// Download resumes a partial download of dest left by an earlier attempt. an empty sha256 skips the check.
+type Downloader interface {
+	Download(url, dest, sha256 string, progress func(done, total int64)) error
+	// Verify checks a local file, e.g. a zip shipped next to the installer
+	Verify(path, sha256 string) error
+}
+
+type Engine struct {
+	FS         FileSystem
+	Store      KeyValueStore
+	Firewall   Firewall
+	Shortcuts  Shortcuts
+	Downloader Downloader
+
+	// optional, see Journal
+	JournalPath string
+	Journal     *Journal
+
+	// 0-100
+	Progress func(percent float64)
+	Status   func(status string)
+
+	onCommit []func()
+}
+
+type Step struct {
+	Name string
+	// printed by dry-run
+	Detail string
+	// progress once the step is done
+	Progress float64
+	Run      func(e *Engine) error
+}
+
+type Plan struct {
+	Settings Settings
+	Steps    []Step
+}
+
+func (e *Engine) progress(percent float64) {
+	if e.Progress != nil {
+		e.Progress(percent)
+	}
+}
+
+func (e *Engine) status(status string) {
+	if e.Status != nil {
+		e.Status(status)
+	}
+}
+
+// byteProgress maps download progress onto from-to percent, with a status like "Downloading wire-pod (1.5 of 80.2 MB)".
+// the status only changes every 1MB so a log isn't flooded.
+func (e *Engine) byteProgress(what string, from, to float64) func(done, total int64) {
+	lastMB := int64(-1)
+	return func(done, total int64) {
+		if total > 0 {
+			e.progress(from + (to-from)*float64(done)/float64(total))
+		}
+		if mb := done >> 20; mb != lastMB || done == total {
+			lastMB = mb
+			if total > 0 {
+				e.status(fmt.Sprintf("%s (%.1f of %.1f MB)...", what, float64(done)/(1<<20), float64(total)/(1<<20)))
+			} else {
+				e.status(fmt.Sprintf("%s (%.1f MB)...", what, float64(done)/(1<<20)))
+			}
+		}
+	}
+}
+
+// WritePlan prints the plan without running anything, for --dry-run
+func WritePlan(w io.Writer, plan Plan) {
+	fmt.Fprintf(w, "Install plan for %s (%d steps):\n", plan.Settings.Where, len(plan.Steps))
+	for i, step := range plan.Steps {
+		fmt.Fprintf(w, "%2d. %s\n", i+1, step.Name)
+		for _, line := range strings.Split(step.Detail, "\n") {
+			if line != "" {
+				fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+	}
+}
+
+// Run executes the plan. if a step fails everything journaled so far is rolled back.
+func (e *Engine) Run(plan Plan) error {
+	if e.FS == nil || e.Store == nil || e.Firewall == nil || e.Shortcuts == nil || e.Downloader == nil {
+		return errors.New("install engine is missing an implementation")
+	}
+	e.Journal = &Journal{path: e.JournalPath}
+	e.onCommit = nil
+	for _, step := range plan.Steps {
+		e.status(step.Name + "...")
+		if err := step.Run(e); err != nil {
+			stepErr := fmt.Errorf("%s: %s", step.Name, err)
+			e.status("Rolling back...")
+			if rerr := e.Rollback(); rerr != nil {
+				return fmt.Errorf("%s (rollback was incomplete: %s)", stepErr, rerr)
+			}
+			return stepErr
+		}
+		e.progress(step.Progress)
+	}
+	for _, f := range e.onCommit {
+		f()
+	}
+	e.Journal.Committed = true
+	e.Journal.save()
+	e.status("Done!")
+	e.progress(100)
+	return nil
+}
+
+// Rollback undoes the journal in reverse order. it keeps going on errors and returns the first one.
+func (e *Engine) Rollback() error {
+	if e.Journal == nil {
+		return nil
+	}
+	var firstErr error
+	for i := len(e.Journal.Entries) - 1; i >= 0; i-- {
+		if err := e.undo(e.Journal.Entries[i]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	e.Journal.Entries = nil
+	e.Journal.RolledBack = true
+	e.Journal.save()
+	return firstErr
+}
+
+func (e *Engine) undo(entry Entry) error {
+	switch entry.Kind {
+	case KindDirCreated:
+		return e.FS.RemoveAll(entry.Path)
+	case KindFileCreated:
+		return e.FS.Remove(entry.Path)
+	case KindRenamed:
+		return e.FS.Rename(entry.Path, entry.From)
+	case KindValueSet:
+		if entry.HadPrevious {
+			return e.Store.Set(entry.Store, entry.Name, entry.Previous)
+		}
+		return e.Store.Delete(entry.Store, entry.Name)
+	case KindFirewallRule:
+		return e.Firewall.RemoveRule(*entry.Rule)
+	case KindShortcut:
+		return e.Shortcuts.Remove(entry.Path)
+	}
+	return fmt.Errorf("unknown journal entry: %s", entry.Kind)
+}
+
+func under(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// runs only if the whole plan succeeds, e.g. deleting the previous installation
+func (e *Engine) OnCommit(f func()) {
+	e.onCommit = append(e.onCommit, f)
+}
+
+// journaled actions for steps to use
+
+func (e *Engine) MkdirAll(path string) error {
+	if e.FS.Exists(path) {
+		return nil
+	}
+	if err := e.FS.MkdirAll(path); err != nil {
+		return err
+	}
+	e.Journal.add(Entry{Kind: KindDirCreated, Path: path})
+	return nil
+}
+
+// MoveAside renames path to path+suffix so it can be restored, and deletes it once the install is committed
+func (e *Engine) MoveAside(path, suffix string) error {
+	if !e.FS.Exists(path) {
+		return nil
+	}
+	aside := path + suffix
+	e.FS.RemoveAll(aside)
+	if err := e.FS.Rename(path, aside); err != nil {
+		return err
+	}
+	e.Journal.add(Entry{Kind: KindRenamed, From: path, Path: aside})
+	e.OnCommit(func() { e.FS.RemoveAll(aside) })
+	return nil
+}
+
+func (e *Engine) SetValue(store, name, value string) error {
+	previous, had, err := e.Store.Get(store, name)
+	if err != nil {
+		return err
+	}
+	if err := e.Store.Set(store, name, value); err != nil {
+		return err
+	}
+	e.Journal.add(Entry{Kind: KindValueSet, Store: store, Name: name, Value: value, Previous: previous, HadPrevious: had})
+	return nil
+}
+
+func (e *Engine) AddFirewallRule(rule FirewallRule) error {
+	if err := e.Firewall.AddRule(rule); err != nil {
+		return err
+	}
+	e.Journal.add(Entry{Kind: KindFirewallRule, Rule: &rule})
+	return nil
+}
+
+func (e *Engine) CreateShortcut(path, target string) error {
+	if err := e.Shortcuts.Create(path, target); err != nil {
+		return err
+	}
+	e.Journal.add(Entry{Kind: KindShortcut, Path: path, Value: target})
+	return nil
+}
+
+// Extract unpacks into dest, which must not exist yet. the directory and every file are journaled.
+func (e *Engine) Extract(archive, dest, stripPrefix string, progress func(done, total int)) error {
+	if err := e.MkdirAll(dest); err != nil {
+		return err
+	}
+	files, err := e.FS.ExtractZip(archive, dest, stripPrefix, progress)
+	var entries []Entry
+	for _, f := range files {
+		entries = append(entries, Entry{Kind: KindFileCreated, Path: f})
+	}
+	e.Journal.addAll(entries)
+	return err
+}
+
+// Download fetches url into dest, or a temp file if dest is empty. the file is removed once the install is done
+// either way. a partial download is kept on failure, so the next attempt at the same dest resumes it.
+func (e *Engine) Download(url, dest, sha256 string, progress func(done, total int64)) (string, error) {
+	if dest == "" {
+		tmp, err := e.FS.TempFile("wire-pod-*.zip")
+		if err != nil {
+			return "", err
+		}
+		dest = tmp
+	}
+	e.OnCommit(func() { e.FS.Remove(dest) })
+	e.Journal.add(Entry{Kind: KindFileCreated, Path: dest, Temp: true})
+	return dest, e.Downloader.Download(url, dest, sha256, progress)
+}
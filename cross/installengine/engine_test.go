@@ -0,0 +1,199 @@
+package installengine
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testWhere = filepath.Join(string(filepath.Separator)+"opt", "wire-pod")
+
+func testSettings() Settings {
+	return Settings{
+		Where:        testWhere,
+		WebPort:      "8080",
+		Version:      "v1.2.0",
+		DownloadURL:  "https://example.com/wire-pod.zip",
+		RunAtStartup: true,
+		StartMenuDir: filepath.Join(string(filepath.Separator)+"menu", "Programs"),
+	}
+}
+
+func testFakes() *Fakes {
+	f := NewFakes()
+	f.Files[filepath.Dir(testWhere)] = nil
+	f.Archive["wire-pod/chipper/chipper.exe"] = []byte("exe")
+	f.Archive["wire-pod/chipper/webroot/index.html"] = []byte("<html>")
+	return f
+}
+
+func install(t *testing.T, f *Fakes, s Settings) error {
+	t.Helper()
+	plan, err := BuildPlan(s, Hooks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Engine().Run(plan)
+}
+
+func TestInstall(t *testing.T) {
+	f := testFakes()
+	s := testSettings()
+	if err := install(t, f, s); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(testWhere, "chipper", "chipper.exe")
+	if string(f.Files[exe]) != "exe" {
+		t.Errorf("%s wasn't extracted: %q", exe, f.Paths())
+	}
+	for name, want := range map[string]string{
+		StoreSoftware + `\InstallPath`: testWhere,
+		StoreSoftware + `\PodVersion`:  "v1.2.0",
+		StoreSoftware + `\WebPort`:     "8080",
+		StoreRun + `\wire-pod`:         `cmd.exe /C start "" "` + exe + `" -d`,
+	} {
+		if got := f.Values[name]; got != want {
+			t.Errorf("%s is %q, want %q", name, got, want)
+		}
+	}
+	if f.Links[filepath.Join(s.StartMenuDir, "WirePod.lnk")] != exe {
+		t.Errorf("no shortcut: %v", f.Links)
+	}
+	if len(f.Rules) != 2 {
+		t.Errorf("firewall rules: %v", f.Rules)
+	}
+	// the download is gone once the install is committed
+	for _, p := range f.Paths() {
+		if strings.HasSuffix(p, ".zip") {
+			t.Errorf("%s was left behind", p)
+		}
+	}
+	m, err := ParseManifest(f.Files[ManifestPath(testWhere)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 3 || len(m.Values) != 12 || len(m.Rules) != 2 || len(m.Shortcuts) != 1 {
+		t.Errorf("manifest has %d files, %d values, %d rules, %d shortcuts", len(m.Files), len(m.Values), len(m.Rules), len(m.Shortcuts))
+	}
+}
+
+// a failed step puts back everything the steps before it changed
+func TestRollback(t *testing.T) {
+	for _, op := range []string{"Download", "ExtractZip", "Set", "CreateShortcut", "AddRule", "WriteFile"} {
+		t.Run(op, func(t *testing.T) {
+			f := testFakes()
+			old := filepath.Join(testWhere, "chipper", "old.exe")
+			f.Files[testWhere] = nil
+			f.Files[old] = []byte("old")
+			f.Values[StoreSoftware+`\WebPort`] = "9000"
+			f.Fail[op] = errors.New("denied")
+			before := f.Paths()
+
+			err := install(t, f, testSettings())
+			if err == nil || !strings.Contains(err.Error(), "denied") {
+				t.Fatalf("install didn't fail: %v", err)
+			}
+			if got := f.Paths(); !reflect.DeepEqual(got, before) {
+				t.Errorf("files are %q, want %q", got, before)
+			}
+			if string(f.Files[old]) != "old" {
+				t.Error("the previous install wasn't put back")
+			}
+			if !reflect.DeepEqual(f.Values, map[string]string{StoreSoftware + `\WebPort`: "9000"}) {
+				t.Errorf("values are %v", f.Values)
+			}
+			if len(f.Links) != 0 || len(f.Rules) != 0 {
+				t.Errorf("shortcuts %v and rules %v are left", f.Links, f.Rules)
+			}
+		})
+	}
+}
+
+func TestLocalZipChecksum(t *testing.T) {
+	f := testFakes()
+	s := testSettings()
+	s.LocalZip = filepath.Join(string(filepath.Separator)+"tmp", "wire-pod.zip")
+	s.SHA256 = strings.Repeat("0", 64)
+	f.Files[s.LocalZip] = []byte("zip")
+	err := install(t, f, s)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("got %v", err)
+	}
+	if f.Files[ManifestPath(testWhere)] != nil {
+		t.Error("a manifest was written")
+	}
+}
+
+// the uninstaller removes what the manifest lists, and leaves what was added since
+func TestUninstall(t *testing.T) {
+	f := testFakes()
+	if err := install(t, f, testSettings()); err != nil {
+		t.Fatal(err)
+	}
+	userFile := filepath.Join(testWhere, "chipper", "jdocs", "botSdkInfo.json")
+	f.Files[userFile] = []byte("{}")
+	m, err := ParseManifest(f.Files[ManifestPath(testWhere)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := f.Engine().Uninstall(m)
+	if len(r.Errors) != 0 || len(r.Refused) != 0 {
+		t.Fatalf("errors %v, refused %v", r.Errors, r.Refused)
+	}
+	want := []string{filepath.Dir(testWhere), testWhere, userFile}
+	if got := f.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("files are %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(r.Kept, []string{testWhere}) {
+		t.Errorf("kept %q", r.Kept)
+	}
+	if len(f.Values) != 0 || len(f.Links) != 0 || len(f.Rules) != 0 {
+		t.Errorf("values %v, shortcuts %v and rules %v are left", f.Values, f.Links, f.Rules)
+	}
+}
+
+func TestUninstallRefusesOutsideFiles(t *testing.T) {
+	f := testFakes()
+	outside := filepath.Join(string(filepath.Separator)+"etc", "passwd")
+	f.Files[outside] = []byte("root")
+	m := Manifest{Where: testWhere, Files: []string{outside}, Shortcuts: []string{outside}}
+	r := f.Engine().Uninstall(m)
+	if len(r.Refused) != 2 || f.Files[outside] == nil {
+		t.Errorf("refused %q, files %q", r.Refused, f.Paths())
+	}
+	for _, where := range []string{"", "relative", string(filepath.Separator)} {
+		if err := CheckRoot(where); err == nil {
+			t.Errorf("%q is allowed", where)
+		}
+	}
+}
+
+func TestParseAnswers(t *testing.T) {
+	defaults := DefaultAnswers(testWhere)
+	ini := "; unattended\n[install]\nwebport = 8081\nrunatstartup = no\nautoupdate = yes\n"
+	a, err := ParseAnswers("answers.ini", []byte(ini), defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.WebPort != "8081" || a.RunAtStartup || !a.AutoUpdate || a.Where != testWhere {
+		t.Errorf("got %+v", a)
+	}
+	a, err = ParseAnswers("answers.json", []byte(`{"webport": "8082", "sethostnameepod": true}`), defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.WebPort != "8082" || !a.SetHostnameEpod || !a.RunAtStartup {
+		t.Errorf("got %+v", a)
+	}
+	for name, data := range map[string]string{
+		"typo.ini":  "[install]\nwebprot = 8080\n",
+		"typo.json": `{"webprot": "8080"}`,
+		"bool.ini":  "[install]\nreboot = maybe\n",
+	} {
+		if _, err := ParseAnswers(name, []byte(data), defaults); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package installengine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// in-memory implementations, so plans can be run and rolled back on any OS.
+// set Fail to make a call fail, e.g. Fail["AddRule"] = errors.New("denied").
+
+type Fakes struct {
+	mu sync.Mutex
+	// files and dirs by path. dirs have a nil value.
+	Files map[string][]byte
+	// "store\name" -> value
+	Values    map[string]string
+	Rules     []FirewallRule
+	Links     map[string]string
+	Archive   map[string][]byte
+	Fail      map[string]error
+	tempCount int
+}
+
+func NewFakes() *Fakes {
+	return &Fakes{
+		Files:   map[string][]byte{},
+		Values:  map[string]string{},
+		Links:   map[string]string{},
+		Archive: map[string][]byte{},
+		Fail:    map[string]error{},
+	}
+}
+
+// Engine returns an engine wired to the fakes
+func (f *Fakes) Engine() *Engine {
+	return &Engine{
+		FS:         fakeFS{f},
+		Store:      fakeStore{f},
+		Firewall:   fakeFirewall{f},
+		Shortcuts:  fakeShortcuts{f},
+		Downloader: fakeDownloader{f},
+	}
+}
+
+func (f *Fakes) fail(op string) error {
+	return f.Fail[op]
+}
+
+// Paths lists every file and dir, sorted
+func (f *Fakes) Paths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var paths []string
+	for p := range f.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+type fakeFS struct{ f *Fakes }
+
+func (fs fakeFS) Exists(path string) bool {
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	_, ok := fs.f.Files[path]
+	return ok
+}
+
+func (fs fakeFS) MkdirAll(path string) error {
+	if err := fs.f.fail("MkdirAll"); err != nil {
+		return err
+	}
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	for p := path; filepath.Dir(p) != p; p = filepath.Dir(p) {
+		if _, ok := fs.f.Files[p]; !ok {
+			fs.f.Files[p] = nil
+		}
+	}
+	return nil
+}
+
+func (fs fakeFS) Rename(from, to string) error {
+	if err := fs.f.fail("Rename"); err != nil {
+		return err
+	}
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	if _, ok := fs.f.Files[from]; !ok {
+		return fmt.Errorf("rename %s: no such file", from)
+	}
+	for p, data := range fs.f.Files {
+		if under(p, from) {
+			delete(fs.f.Files, p)
+			fs.f.Files[to+strings.TrimPrefix(p, from)] = data
+		}
+	}
+	return nil
+}
+
+func (fs fakeFS) Remove(path string) error {
+	if err := fs.f.fail("Remove"); err != nil {
+		return err
+	}
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	for p := range fs.f.Files {
+		if p != path && under(p, path) {
+			return fmt.Errorf("remove %s: directory not empty", path)
+		}
+	}
+	delete(fs.f.Files, path)
+	return nil
+}
+
+func (fs fakeFS) RemoveAll(path string) error {
+	if err := fs.f.fail("RemoveAll"); err != nil {
+		return err
+	}
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	for p := range fs.f.Files {
+		if under(p, path) {
+			delete(fs.f.Files, p)
+		}
+	}
+	return nil
+}
+
+func (fs fakeFS) WriteFile(path string, data []byte) error {
+	if err := fs.f.fail("WriteFile"); err != nil {
+		return err
+	}
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	fs.f.Files[path] = data
+	return nil
+}
+
+func (fs fakeFS) TempFile(pattern string) (string, error) {
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	fs.f.tempCount++
+	name := filepath.Join(string(filepath.Separator)+"tmp", strings.Replace(pattern, "*", fmt.Sprint(fs.f.tempCount), 1))
+	fs.f.Files[name] = []byte{}
+	return name, nil
+}
+
+// the fake archive is Fakes.Archive, whatever was "downloaded"
+func (fs fakeFS) ExtractZip(archive, dest, stripPrefix string, progress func(done, total int)) ([]string, error) {
+	if err := fs.f.fail("ExtractZip"); err != nil {
+		return nil, err
+	}
+	fs.f.mu.Lock()
+	defer fs.f.mu.Unlock()
+	if _, ok := fs.f.Files[archive]; !ok {
+		return nil, errors.New("error reading zip file: no such file")
+	}
+	var names []string
+	for name := range fs.f.Archive {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var written []string
+	for i, name := range names {
+		path := filepath.Join(dest, filepath.FromSlash(strings.TrimPrefix(name, stripPrefix)))
+		fs.f.Files[path] = fs.f.Archive[name]
+		written = append(written, path)
+		if progress != nil {
+			progress(i+1, len(names))
+		}
+	}
+	return written, nil
+}
+
+type fakeStore struct{ f *Fakes }
+
+func (s fakeStore) Get(store, name string) (string, bool, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	v, ok := s.f.Values[store+`\`+name]
+	return v, ok, nil
+}
+
+func (s fakeStore) Set(store, name, value string) error {
+	if err := s.f.fail("Set"); err != nil {
+		return err
+	}
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.Values[store+`\`+name] = value
+	return nil
+}
+
+func (s fakeStore) Delete(store, name string) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	delete(s.f.Values, store+`\`+name)
+	return nil
+}
+
+type fakeFirewall struct{ f *Fakes }
+
+func (fw fakeFirewall) AddRule(rule FirewallRule) error {
+	if err := fw.f.fail("AddRule"); err != nil {
+		return err
+	}
+	fw.f.mu.Lock()
+	defer fw.f.mu.Unlock()
+	fw.f.Rules = append(fw.f.Rules, rule)
+	return nil
+}
+
+func (fw fakeFirewall) RemoveRule(rule FirewallRule) error {
+	fw.f.mu.Lock()
+	defer fw.f.mu.Unlock()
+	for i, r := range fw.f.Rules {
+		if r == rule {
+			fw.f.Rules = append(fw.f.Rules[:i], fw.f.Rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type fakeShortcuts struct{ f *Fakes }
+
+func (s fakeShortcuts) Create(path, target string) error {
+	if err := s.f.fail("CreateShortcut"); err != nil {
+		return err
+	}
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.Links[path] = target
+	return nil
+}
+
+func (s fakeShortcuts) Remove(path string) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	delete(s.f.Links, path)
+	return nil
+}
+
+type fakeDownloader struct{ f *Fakes }
+
+// the "download" is a placeholder file, ExtractZip unpacks Fakes.Archive from it
+func (d fakeDownloader) Download(url, dest, sum string, progress func(done, total int64)) error {
+	if err := d.f.fail("Download"); err != nil {
+		return err
+	}
+	d.f.mu.Lock()
+	d.f.Files[dest] = []byte("zip")
+	d.f.mu.Unlock()
+	if progress != nil {
+		progress(1, 1)
+	}
+	return nil
+}
+
+func (d fakeDownloader) Verify(path, sum string) error {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	data, ok := d.f.Files[path]
+	if !ok {
+		return fmt.Errorf("%s: no such file", path)
+	}
+	if sum == "" {
+		return nil
+	}
+	h := sha256.Sum256(data)
+	if got := hex.EncodeToString(h[:]); !strings.EqualFold(got, sum) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, sum, got)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package installengine
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const (
+	KindDirCreated   = "dir-created"
+	KindFileCreated  = "file-created"
+	KindRenamed      = "renamed"
+	KindValueSet     = "value-set"
+	KindFirewallRule = "firewall-rule"
+	KindShortcut     = "shortcut"
+)
+
+type Entry struct {
+	Kind string `json:"kind"`
+	Path string `json:"path,omitempty"`
+	// for renamed, Path is the new name
+	From        string        `json:"from,omitempty"`
+	Store       string        `json:"store,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	Value       string        `json:"value,omitempty"`
+	Previous    string        `json:"previous,omitempty"`
+	HadPrevious bool          `json:"hadprevious,omitempty"`
+	Rule        *FirewallRule `json:"rule,omitempty"`
+	// removed at the end of the install, e.g. the download
+	Temp bool      `json:"temp,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Journal is every change made so far, in order. with a path it is saved after every entry,
+// so an install that was killed halfway can still be rolled back with LoadJournal.
+type Journal struct {
+	Entries    []Entry `json:"entries"`
+	Committed  bool    `json:"committed"`
+	RolledBack bool    `json:"rolledback"`
+	path       string
+}
+
+func (j *Journal) add(entry Entry) {
+	entry.At = time.Now()
+	j.Entries = append(j.Entries, entry)
+	j.save()
+}
+
+func (j *Journal) addAll(entries []Entry) {
+	for _, entry := range entries {
+		entry.At = time.Now()
+		j.Entries = append(j.Entries, entry)
+	}
+	j.save()
+}
+
+func (j *Journal) save() {
+	if j.path == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(j, "", "  ")
+	os.WriteFile(j.path, data, 0644)
+}
+
+// LoadJournal reads a saved journal, e.g. to roll back an interrupted install
+func LoadJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{path: path}
+	return j, json.Unmarshal(data, j)
+}
@@ -0,0 +1,271 @@
+package installengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the install manifest is everything a committed install created, built from its journal.
+// it is written into the install directory and the uninstaller removes exactly what it lists.
+
+const ManifestName = "install-manifest.json"
+
+type ManifestValue struct {
+	Store string `json:"store"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Manifest struct {
+	Version   string    `json:"version"`
+	Where     string    `json:"where"`
+	CreatedAt time.Time `json:"createdat"`
+	// legacy manifests are made up by the uninstaller for installs from before manifests existed. they list no files.
+	Legacy    bool            `json:"legacy,omitempty"`
+	Files     []string        `json:"files"`
+	Dirs      []string        `json:"dirs"`
+	Values    []ManifestValue `json:"values"`
+	Rules     []FirewallRule  `json:"rules"`
+	Shortcuts []string        `json:"shortcuts"`
+}
+
+func ManifestPath(where string) string {
+	return filepath.Join(where, ManifestName)
+}
+
+// ManifestFromJournal lists what the journal created. temp files and moved-aside paths are left out,
+// they are gone once the install is committed.
+func ManifestFromJournal(j *Journal, s Settings) Manifest {
+	m := Manifest{Version: s.Version, Where: s.Where, CreatedAt: time.Now().UTC()}
+	for _, entry := range j.Entries {
+		if entry.Temp {
+			continue
+		}
+		switch entry.Kind {
+		case KindDirCreated:
+			m.Dirs = append(m.Dirs, entry.Path)
+		case KindFileCreated:
+			m.Files = append(m.Files, entry.Path)
+		case KindValueSet:
+			m.Values = append(m.Values, ManifestValue{Store: entry.Store, Name: entry.Name, Value: entry.Value})
+		case KindFirewallRule:
+			m.Rules = append(m.Rules, *entry.Rule)
+		case KindShortcut:
+			m.Shortcuts = append(m.Shortcuts, entry.Path)
+		}
+	}
+	return m
+}
+
+// WriteManifest is the last step of a plan. the manifest lists itself, and is journaled so a rollback removes it.
+func (e *Engine) WriteManifest(s Settings) error {
+	path := ManifestPath(s.Where)
+	m := ManifestFromJournal(e.Journal, s)
+	m.Files = append(m.Files, path)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := e.FS.WriteFile(path, data); err != nil {
+		return err
+	}
+	e.Journal.add(Entry{Kind: KindFileCreated, Path: path})
+	return nil
+}
+
+func ParseManifest(data []byte) (Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("error reading install manifest: %s", err)
+	}
+	return m, nil
+}
+
+func ReadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return ParseManifest(data)
+}
+
+// AddFiles records files created after the install, e.g. new files from an update. a missing manifest is not an error.
+func AddFiles(manifestPath string, files []string) error {
+	m, err := ReadManifest(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, f := range m.Files {
+		known[f] = true
+	}
+	for _, f := range files {
+		if !known[f] {
+			m.Files = append(m.Files, f)
+			known[f] = true
+		}
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath, data, 0644)
+}
+
+// LegacyManifest covers installs from before manifests existed: the registry values, shortcuts and
+// firewall rules the old installers made. files are not listed, so they are left where they are.
+func LegacyManifest(where, startMenuDir string) Manifest {
+	if startMenuDir == "" {
+		startMenuDir = DefaultStartMenuDir
+	}
+	exe := Settings{Where: where}.exe()
+	m := Manifest{Where: where, Legacy: true}
+	for _, name := range []string{"DisplayName", "DisplayIcon", "DisplayVersion", "Publisher", "UninstallString", "InstallLocation", "InstallPath"} {
+		m.Values = append(m.Values, ManifestValue{Store: StoreUninstall, Name: name})
+	}
+	for _, name := range []string{"InstallPath", "PodVersion", "WebPort", "RunAtStartup"} {
+		m.Values = append(m.Values, ManifestValue{Store: StoreSoftware, Name: name})
+	}
+	m.Values = append(m.Values, ManifestValue{Store: StoreRun, Name: "wire-pod"})
+	m.Shortcuts = []string{filepath.Join(startMenuDir, "wire-pod.lnk"), filepath.Join(startMenuDir, "WirePod.lnk")}
+	m.Rules = []FirewallRule{
+		{Name: "wire-pod", Direction: "in", Program: exe},
+		{Name: "wire-pod", Direction: "out", Program: exe},
+	}
+	return m
+}
+
+// CheckRoot refuses install directories an uninstall must never touch, like a drive root
+func CheckRoot(where string) error {
+	if where == "" || !filepath.IsAbs(where) {
+		return fmt.Errorf("install directory %q is not an absolute path", where)
+	}
+	clean := filepath.Clean(where)
+	if filepath.Dir(clean) == clean {
+		return fmt.Errorf("install directory %q is a filesystem root", where)
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.EqualFold(clean, filepath.Clean(home)) {
+		return fmt.Errorf("install directory %q is the home directory", where)
+	}
+	return nil
+}
+
+type UninstallReport struct {
+	Removed []string
+	// listed in the manifest but outside the install directory, or not a shortcut
+	Refused []string
+	// dirs which weren't empty, and anything passed as keep
+	Kept   []string
+	Errors []error
+}
+
+func (r *UninstallReport) err(what string, err error) {
+	r.Errors = append(r.Errors, fmt.Errorf("%s: %s", what, err))
+}
+
+// Uninstall removes what the manifest lists and nothing else. files must be inside m.Where, and
+// directories are only removed once they are empty. paths in keep are left alone, e.g. the running uninstaller.
+func (e *Engine) Uninstall(m Manifest, keep ...string) UninstallReport {
+	var r UninstallReport
+	if e.FS == nil || e.Store == nil || e.Firewall == nil || e.Shortcuts == nil {
+		r.Errors = append(r.Errors, errors.New("install engine is missing an implementation"))
+		return r
+	}
+	if err := CheckRoot(m.Where); err != nil {
+		r.Errors = append(r.Errors, err)
+		return r
+	}
+	root := filepath.Clean(m.Where)
+	kept := map[string]bool{}
+	for _, k := range keep {
+		kept[filepath.Clean(k)] = true
+	}
+
+	for _, rule := range m.Rules {
+		e.status("Removing firewall rule " + rule.Name + " (" + rule.Direction + ")...")
+		if err := e.Firewall.RemoveRule(rule); err != nil {
+			r.err("firewall rule "+rule.Name, err)
+		}
+	}
+	for _, s := range m.Shortcuts {
+		if !strings.EqualFold(filepath.Ext(s), ".lnk") {
+			r.Refused = append(r.Refused, s)
+			continue
+		}
+		if err := e.Shortcuts.Remove(s); err != nil {
+			r.err(s, err)
+			continue
+		}
+		r.Removed = append(r.Removed, s)
+	}
+	e.status("Removing registry values...")
+	for _, v := range m.Values {
+		if _, had, err := e.Store.Get(v.Store, v.Name); err != nil || !had {
+			continue
+		}
+		if err := e.Store.Delete(v.Store, v.Name); err != nil {
+			r.err(v.Store+`\`+v.Name, err)
+		}
+	}
+
+	e.status("Removing files...")
+	// parents of removed files are candidates too, the extractor creates them without journaling
+	dirs := map[string]bool{}
+	for _, d := range m.Dirs {
+		dirs[filepath.Clean(d)] = true
+	}
+	for i, f := range m.Files {
+		f = filepath.Clean(f)
+		if !under(f, root) || f == root {
+			r.Refused = append(r.Refused, f)
+			continue
+		}
+		for d := filepath.Dir(f); under(d, root); d = filepath.Dir(d) {
+			dirs[d] = true
+		}
+		if kept[f] {
+			r.Kept = append(r.Kept, f)
+			continue
+		}
+		if err := e.FS.Remove(f); err != nil {
+			r.err(f, err)
+			continue
+		}
+		r.Removed = append(r.Removed, f)
+		if len(m.Files) > 0 {
+			e.progress(90 * float64(i+1) / float64(len(m.Files)))
+		}
+	}
+
+	var sorted []string
+	for d := range dirs {
+		if !under(d, root) {
+			r.Refused = append(r.Refused, d)
+			continue
+		}
+		sorted = append(sorted, d)
+	}
+	// deepest first, so children go before their parents
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, d := range sorted {
+		if !e.FS.Exists(d) {
+			continue
+		}
+		// removing a directory fails unless it's empty, which is what keeps user files safe
+		if err := e.FS.Remove(d); err != nil {
+			r.Kept = append(r.Kept, d)
+			continue
+		}
+		r.Removed = append(r.Removed, d)
+	}
+	e.progress(100)
+	return r
+}
@@ -0,0 +1,109 @@
+package installengine
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kercre123/WirePod/cross/download"
+)
+
+// OSFileSystem is the real filesystem
+type OSFileSystem struct{}
+
+func (OSFileSystem) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (OSFileSystem) MkdirAll(path string) error {
+	return os.MkdirAll(path, os.ModePerm)
+}
+
+func (OSFileSystem) Rename(from, to string) error {
+	return os.Rename(from, to)
+}
+
+func (OSFileSystem) Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (OSFileSystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (OSFileSystem) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0644)
+}
+
+func (OSFileSystem) TempFile(pattern string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("error creating a temp file: %s", err)
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+func (OSFileSystem) ExtractZip(archive, dest, stripPrefix string, progress func(done, total int)) ([]string, error) {
+	var written []string
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("error reading zip file: %s", err)
+	}
+	defer zipReader.Close()
+	for i, f := range zipReader.File {
+		name := strings.TrimPrefix(f.Name, stripPrefix)
+		if name == "" || f.FileInfo().IsDir() {
+			continue
+		}
+		fpath := filepath.Join(dest, name)
+		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return written, fmt.Errorf("illegal file path: %s", fpath)
+		}
+		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			return written, fmt.Errorf("error creating directories: %s", err)
+		}
+		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		if err != nil {
+			return written, fmt.Errorf("error opening file for writing: %s", err)
+		}
+		written = append(written, fpath)
+		rc, err := f.Open()
+		if err != nil {
+			outFile.Close()
+			return written, fmt.Errorf("error opening zip contents: %s", err)
+		}
+		_, err = io.Copy(outFile, rc)
+		outFile.Close()
+		rc.Close()
+		if err != nil {
+			return written, fmt.Errorf("error writing file: %s", err)
+		}
+		if progress != nil {
+			progress(i+1, len(zipReader.File))
+		}
+	}
+	return written, nil
+}
+
+// HTTPDownloader downloads with cross/download, which resumes and verifies
+type HTTPDownloader struct{}
+
+func (HTTPDownloader) Download(url, dest, sha256 string, progress func(done, total int64)) error {
+	err := download.File(url, dest, download.Options{SHA256: sha256, Progress: progress})
+	if err != nil {
+		return fmt.Errorf("error getting wire-pod: %s", err)
+	}
+	return nil
+}
+
+func (HTTPDownloader) Verify(path, sha256 string) error {
+	return download.VerifyFile(path, sha256)
+}
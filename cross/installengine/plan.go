@@ -0,0 +1,234 @@
+package installengine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// store names used by the wire-pod plan. the windows front-end maps them to registry keys.
+const (
+	StoreSoftware  = "software"
+	StoreUninstall = "uninstall"
+	StoreRun       = "run"
+)
+
+var DefaultStartMenuDir = `C:\ProgramData\Microsoft\Windows\Start Menu\Programs`
+
+type Settings struct {
+	RunAtStartup    bool   `json:"runatstartup"`
+	AutoUpdate      bool   `json:"autoupdate"`
+	Where           string `json:"where"`
+	WebPort         string `json:"webport"`
+	SetHostnameEpod bool   `json:"sethostnameepod"`
+	// release tag, stored as PodVersion
+	Version     string `json:"version"`
+	DownloadURL string `json:"downloadurl"`
+	// hex sha256 of the release zip from the release manifest. empty installs unverified.
+	SHA256 string `json:"sha256"`
+	// a stable path makes an interrupted download resume on the next run. defaults to a new temp file.
+	DownloadPath string `json:"downloadpath"`
+	// install from this zip instead of downloading
+	LocalZip string `json:"localzip"`
+	// defaults to DefaultStartMenuDir
+	StartMenuDir string `json:"startmenudir"`
+}
+
+// system actions which can't be journaled. any of them may be nil.
+type Hooks struct {
+	StopRunningPod func() error
+	// runs the old uninstaller, if there is one. user data is kept.
+	RemovePreviousInstall func() error
+	SetHostname           func(hostname string) error
+}
+
+// ValidateWebPort allows 1000-65353, same as the installer always has
+func ValidateWebPort(port string) bool {
+	i, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	return i >= 1000 && i <= 65353
+}
+
+// ValidateInstallDirectory checks that the parent of dir exists. dir itself doesn't have to.
+func ValidateInstallDirectory(dir string) bool {
+	splitDir := strings.Split(dir, "\\")
+	dirWithoutLast := splitDir[0]
+	for in, str := range splitDir {
+		if in == len(splitDir)-1 || in == 0 {
+			continue
+		}
+		dirWithoutLast = dirWithoutLast + "\\" + str
+	}
+	if statted, err := os.Stat(dirWithoutLast); err == nil {
+		return statted.IsDir()
+	}
+	return false
+}
+
+func (s Settings) Validate() error {
+	if s.Where == "" {
+		return errors.New("no installation directory")
+	}
+	if !ValidateWebPort(s.WebPort) {
+		return fmt.Errorf("the web port (%s) is invalid. it must be an integer between 1000-65353", s.WebPort)
+	}
+	if s.DownloadURL == "" && s.LocalZip == "" {
+		return errors.New("no download URL or local zip")
+	}
+	return nil
+}
+
+func (s Settings) exe() string {
+	return filepath.Join(s.Where, "chipper", "chipper.exe")
+}
+
+// BuildPlan turns settings into the steps of a wire-pod install
+func BuildPlan(s Settings, hooks Hooks) (Plan, error) {
+	if err := s.Validate(); err != nil {
+		return Plan{}, err
+	}
+	if s.StartMenuDir == "" {
+		s.StartMenuDir = DefaultStartMenuDir
+	}
+	plan := Plan{Settings: s}
+	add := func(step Step) {
+		plan.Steps = append(plan.Steps, step)
+	}
+	var archive string
+
+	if s.SetHostnameEpod && hooks.SetHostname != nil {
+		add(Step{
+			Name:   "Setting hostname to escapepod",
+			Detail: "not undone on failure",
+			Run:    func(e *Engine) error { return hooks.SetHostname("escapepod") },
+		})
+	}
+	if hooks.StopRunningPod != nil {
+		add(Step{
+			Name: "Stopping any wire-pod instances",
+			Run: func(e *Engine) error {
+				hooks.StopRunningPod()
+				return nil
+			},
+		})
+	}
+	if hooks.RemovePreviousInstall != nil {
+		add(Step{
+			Name:   "Uninstalling any previous wire-pod instances (user data will be kept)",
+			Detail: "not undone on failure",
+			Run:    func(e *Engine) error { return hooks.RemovePreviousInstall() },
+		})
+	}
+	add(Step{
+		Name:   "Moving aside any wire-pod files",
+		Detail: s.Where + " -> " + s.Where + ".old (deleted once the install succeeds)",
+		Run:    func(e *Engine) error { return e.MoveAside(s.Where, ".old") },
+	})
+	checksum := "sha256 " + s.SHA256
+	if s.SHA256 == "" {
+		checksum = "no checksum known, not verified"
+	}
+	if s.LocalZip != "" {
+		add(Step{
+			Name:     "Checking local wire-pod zip",
+			Detail:   s.LocalZip + " (" + checksum + ")",
+			Progress: 40,
+			Run: func(e *Engine) error {
+				archive = s.LocalZip
+				return e.Downloader.Verify(s.LocalZip, s.SHA256)
+			},
+		})
+	} else {
+		add(Step{
+			Name:     "Downloading wire-pod",
+			Detail:   s.DownloadURL + " (" + checksum + ")",
+			Progress: 40,
+			Run: func(e *Engine) (err error) {
+				archive, err = e.Download(s.DownloadURL, s.DownloadPath, s.SHA256, e.byteProgress("Downloading wire-pod", 0, 40))
+				return err
+			},
+		})
+	}
+	add(Step{
+		Name:     "Extracting wire-pod",
+		Detail:   "into " + s.Where,
+		Progress: 80,
+		Run: func(e *Engine) error {
+			return e.Extract(archive, s.Where, "wire-pod/", func(done, total int) {
+				e.progress(40 + 40*float64(done)/float64(total))
+			})
+		},
+	})
+
+	registry := []struct{ store, name, value string }{
+		{StoreUninstall, "DisplayName", "wire-pod"},
+		{StoreUninstall, "DisplayIcon", filepath.Join(s.Where, `chipper\icons\ico\pod256x256.ico`)},
+		{StoreUninstall, "DisplayVersion", s.Version},
+		{StoreUninstall, "Publisher", "github.com/kercre123"},
+		{StoreUninstall, "UninstallString", filepath.Join(s.Where, "uninstall.exe")},
+		{StoreUninstall, "InstallLocation", s.exe()},
+		{StoreSoftware, "InstallPath", s.Where},
+		{StoreSoftware, "PodVersion", s.Version},
+		{StoreSoftware, "WebPort", s.WebPort},
+		{StoreSoftware, "RunAtStartup", fmt.Sprint(s.RunAtStartup)},
+		{StoreSoftware, "AutoUpdate", fmt.Sprint(s.AutoUpdate)},
+	}
+	if s.RunAtStartup {
+		registry = append(registry, struct{ store, name, value string }{StoreRun, "wire-pod", `cmd.exe /C start "" "` + s.exe() + `" -d`})
+	}
+	var detail []string
+	for _, r := range registry {
+		detail = append(detail, r.store+`\`+r.name+" = "+r.value)
+	}
+	add(Step{
+		Name:     "Updating registry",
+		Detail:   strings.Join(detail, "\n"),
+		Progress: 90,
+		Run: func(e *Engine) error {
+			for _, r := range registry {
+				if err := e.SetValue(r.store, r.name, r.value); err != nil {
+					return fmt.Errorf("error setting %s: %s", r.name, err)
+				}
+			}
+			return nil
+		},
+	})
+
+	shortcut := filepath.Join(s.StartMenuDir, "WirePod.lnk")
+	add(Step{
+		Name:     "Creating shortcut",
+		Detail:   shortcut + " -> " + s.exe(),
+		Progress: 93,
+		Run:      func(e *Engine) error { return e.CreateShortcut(shortcut, s.exe()) },
+	})
+
+	rules := []FirewallRule{
+		{Name: "wire-pod", Direction: "in", Program: s.exe()},
+		{Name: "wire-pod", Direction: "out", Program: s.exe()},
+	}
+	add(Step{
+		Name:     "Creating firewall rules",
+		Detail:   "allow " + s.exe() + " in and out",
+		Progress: 97,
+		Run: func(e *Engine) error {
+			for _, rule := range rules {
+				if err := e.AddFirewallRule(rule); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	add(Step{
+		Name:     "Writing install manifest",
+		Detail:   ManifestPath(s.Where) + " (used by the uninstaller)",
+		Progress: 99,
+		Run:      func(e *Engine) error { return e.WriteManifest(s) },
+	})
+	return plan, nil
+}
@@ -0,0 +1,102 @@
+package intenttest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const usage = `usage: wire-pod intent-test [options] [suite file or directory]...
+
+checks utterances still match the intents they should. suites are YAML or JSON files with
+text or audio cases and the expected intent and parameters. with no suites given, the ones in
+%s are run.
+
+options:
+`
+
+// RunCLI runs the suites in args, or those in defaultDir. setup readies chipper (working
+// directory, config, custom intents) and returns the runner to use.
+func RunCLI(defaultDir string, setup func() (*Runner, error), args []string) int {
+	fs := flag.NewFlagSet("intent-test", flag.ContinueOnError)
+	junit := fs.String("junit", "", "also write a JUnit XML report to this file")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	builtin := fs.Bool("builtin", false, "also run the built-in en-US cases")
+	custom := fs.String("custom", "", "customIntents.json to test instead of the installed custom intents")
+	exec := fs.Bool("exec", false, "run custom intents' commands and Lua scripts")
+	verbose := fs.Bool("v", false, "list passing cases too")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, defaultDir)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		if _, err := os.Stat(defaultDir); err == nil {
+			paths = []string{defaultDir}
+		} else if !*builtin {
+			fs.Usage()
+			return 2
+		}
+	}
+	suites, err := LoadAll(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *builtin {
+		suites = append(suites, Builtin())
+	}
+	// setup may change the working directory
+	customPath := ""
+	if *custom != "" {
+		customPath, _ = filepath.Abs(*custom)
+	}
+	runner, err := setup()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error setting up: "+err.Error())
+		return 1
+	}
+	runner.Exec = *exec
+	runner.CustomIntents = customPath
+	report := runner.Run(suites)
+	passed, failed, skipped := report.Counts()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, res := range report.Results {
+			switch {
+			case res.Skipped:
+				fmt.Printf("SKIP %s/%s: %s\n", res.Suite, res.Case, res.Message)
+			case !res.Passed:
+				fmt.Printf("FAIL %s/%s: %s (heard %q)\n", res.Suite, res.Case, res.Message, res.Transcript)
+			case *verbose:
+				fmt.Printf("PASS %s/%s\n", res.Suite, res.Case)
+			}
+		}
+		fmt.Printf("%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	}
+	if *junit != "" {
+		f, err := os.Create(*junit)
+		if err == nil {
+			err = WriteJUnit(f, report)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error writing JUnit report: "+err.Error())
+			return 1
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package intenttest
+
+import (
+	"testing"
+)
+
+// Test runs suites as subtests, for keeping intent cases next to Go tests:
+//
+//	func TestIntents(t *testing.T) {
+//		r := &intenttest.Runner{IntentData: "../chipper/intent-data", CustomIntents: "customIntents.json"}
+//		intenttest.Test(t, r, "testdata/intents.yaml")
+//	}
+func Test(t *testing.T, r *Runner, paths ...string) {
+	t.Helper()
+	suites, err := LoadAll(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := r.Run(suites)
+	for _, res := range report.Results {
+		res := res
+		t.Run(res.Suite+"/"+res.Case, func(t *testing.T) {
+			switch {
+			case res.Skipped:
+				t.Skip(res.Message)
+			case !res.Passed:
+				t.Errorf("%s (heard %q)", res.Message, res.Transcript)
+			}
+		})
+	}
+}
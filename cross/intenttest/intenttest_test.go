@@ -0,0 +1,63 @@
+package intenttest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the intent-data the android build ships, which is chipper's
+var intentData = filepath.Join("..", "..", "android", "resources", "intent-data")
+
+func TestBuiltin(t *testing.T) {
+	r := &Runner{IntentData: intentData}
+	report := r.Run([]Suite{Builtin()})
+	if len(report.Results) != len(Builtin().Cases) {
+		t.Fatalf("%d results for %d cases", len(report.Results), len(Builtin().Cases))
+	}
+	for _, res := range report.Results {
+		if !res.Passed {
+			t.Errorf("%s: %s (heard %q)", res.Case, res.Message, res.Transcript)
+		}
+	}
+}
+
+// custom intents, templated and not, next to the built-in ones
+func TestCustomIntents(t *testing.T) {
+	r := &Runner{IntentData: intentData, CustomIntents: filepath.Join("testdata", "customIntents.json")}
+	Test(t, r, filepath.Join("testdata", "intents.yaml"))
+}
+
+// a case which doesn't match fails with what it got instead
+func TestFailingCase(t *testing.T) {
+	r := &Runner{IntentData: intentData}
+	suite, err := Parse("wrong.yaml", []byte("name: wrong\ncases:\n  - text: what time is it\n    intent: intent_greeting_hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := r.Run([]Suite{suite}).Results[0]
+	if res.Passed || !strings.Contains(res.Message, "got intent_clock_time") {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for name, data := range map[string]string{
+		"no-intent.yaml": "name: x\ncases:\n  - text: hello\n",
+		"no-input.yaml":  "name: x\ncases:\n  - intent: intent_greeting_hello\n",
+		"both.yaml":      "name: x\ncases:\n  - text: hello\n    audio: a.wav\n    intent: intent_greeting_hello\n",
+		"bad.json":       `{"name": "x", "cases": [`,
+		"cases.txt":      "name: x\ncases:\n  - text: hello\n    intent: intent_greeting_hello\n",
+	} {
+		if _, err := Parse(name, []byte(data)); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+	s, err := Parse("ok.json", []byte(`{"name": "ok", "cases": [{"text": "hello", "intent": "intent_greeting_hello"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Language != "en-US" || s.Cases[0].name() != "hello" {
+		t.Errorf("got %+v", s)
+	}
+}
@@ -0,0 +1,97 @@
+package intenttest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func seconds(r Result) float64 {
+	return r.Time.Seconds()
+}
+
+// WriteJUnit writes the report as JUnit XML, a testsuite per suite, for CI systems
+func WriteJUnit(w io.Writer, report Report) error {
+	var out junitSuites
+	index := map[string]int{}
+	times := map[string]float64{}
+	for _, res := range report.Results {
+		i, ok := index[res.Suite]
+		if !ok {
+			i = len(out.Suites)
+			index[res.Suite] = i
+			out.Suites = append(out.Suites, junitSuite{Name: res.Suite})
+		}
+		s := &out.Suites[i]
+		c := junitCase{
+			Name:      res.Case,
+			ClassName: "intenttest." + res.Suite,
+			Time:      fmt.Sprintf("%.3f", seconds(res)),
+		}
+		if res.Intent != "" {
+			c.SystemOut = fmt.Sprintf("heard %q, matched %s", res.Transcript, res.Intent)
+			if len(res.Params) > 0 {
+				c.SystemOut += fmt.Sprintf(" with %v", res.Params)
+			}
+		}
+		s.Tests++
+		out.Tests++
+		switch {
+		case res.Skipped:
+			c.Skipped = &junitMessage{res.Message}
+			s.Skipped++
+			out.Skipped++
+		case !res.Passed:
+			c.Failure = &junitMessage{res.Message}
+			s.Failures++
+			out.Failures++
+		}
+		times[res.Suite] += seconds(res)
+		s.Cases = append(s.Cases, c)
+	}
+	for i := range out.Suites {
+		out.Suites[i].Time = fmt.Sprintf("%.3f", times[out.Suites[i].Name])
+	}
+	out.Time = fmt.Sprintf("%.3f", report.Time.Seconds())
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
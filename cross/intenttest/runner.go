@@ -0,0 +1,275 @@
+package intenttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/replay"
+	"github.com/kercre123/WirePod/cross/slots"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	"github.com/kercre123/wire-pod/chipper/pkg/vtt"
+	ttr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/ttr"
+)
+
+// chipper's matcher works on globals (the language, custom intents), so only one run happens at a time
+var runMu sync.Mutex
+
+// Runner runs suites against chipper's intent matcher. it changes chipper's language and custom
+// intents while running and puts them back after, so it isn't for use next to a running server.
+type Runner struct {
+	// the intent-data directory. empty uses chipper's own, relative to the working directory.
+	IntentData string
+	// a customIntents.json to test instead of the loaded custom intents
+	CustomIntents string
+	// run custom intents' exec commands and Lua scripts. off, only system intents run theirs,
+	// as their output decides the intent.
+	Exec bool
+	// sets up the voice processor for audio cases. nil skips them.
+	Processor func() (history.VoiceProcessor, error)
+	// the device requests come from, for jdoc settings like the weather location
+	ESN string
+
+	proc    history.VoiceProcessor
+	procErr error
+	intents map[string][]vars.JsonIntent
+	slots   slots.Matcher
+}
+
+type Result struct {
+	Suite      string            `json:"suite"`
+	Case       string            `json:"case"`
+	Passed     bool              `json:"passed"`
+	Skipped    bool              `json:"skipped,omitempty"`
+	Transcript string            `json:"transcript"`
+	Intent     string            `json:"intent"`
+	Params     map[string]string `json:"params,omitempty"`
+	Expected   Case              `json:"expected"`
+	// why it failed or was skipped
+	Message string        `json:"message,omitempty"`
+	Time    time.Duration `json:"time"`
+}
+
+type Report struct {
+	Results []Result      `json:"results"`
+	Time    time.Duration `json:"time"`
+}
+
+func (r Report) Counts() (passed, failed, skipped int) {
+	for _, res := range r.Results {
+		switch {
+		case res.Skipped:
+			skipped++
+		case res.Passed:
+			passed++
+		default:
+			failed++
+		}
+	}
+	return
+}
+
+func (r *Runner) Run(suites []Suite) Report {
+	runMu.Lock()
+	defer runMu.Unlock()
+	start := time.Now()
+	lang := vars.APIConfig.STT.Language
+	customs, customsExist := vars.CustomIntents, vars.CustomIntentsExist
+	defer func() {
+		vars.APIConfig.STT.Language = lang
+		vars.CustomIntents, vars.CustomIntentsExist = customs, customsExist
+	}()
+	var report Report
+	if err := r.setCustomIntents(); err != nil {
+		for _, s := range suites {
+			for _, c := range s.Cases {
+				report.Results = append(report.Results, Result{Suite: s.Name, Case: c.name(), Expected: c, Message: err.Error()})
+			}
+		}
+		return report
+	}
+	for _, s := range suites {
+		for _, c := range s.Cases {
+			if c.Language == "" {
+				c.Language = s.Language
+			}
+			var res Result
+			caseStart := time.Now()
+			if c.Audio != "" {
+				res = r.runAudio(s, c, lang)
+			} else {
+				res = r.runText(c)
+			}
+			res.Suite, res.Case, res.Expected = s.Name, c.name(), c
+			res.Time = time.Since(caseStart)
+			report.Results = append(report.Results, res)
+		}
+	}
+	report.Time = time.Since(start)
+	return report
+}
+
+// the custom intents to match against, without side effects unless Exec is set
+func (r *Runner) setCustomIntents() error {
+	customs := vars.CustomIntents
+	if r.CustomIntents != "" {
+		data, err := os.ReadFile(r.CustomIntents)
+		if err != nil {
+			return err
+		}
+		customs = nil
+		if err := json.Unmarshal(data, &customs); err != nil {
+			return fmt.Errorf("%s: %s", filepath.Base(r.CustomIntents), err)
+		}
+	}
+	if !r.Exec {
+		var quiet []vars.CustomIntent
+		for _, c := range customs {
+			if !c.IsSystemIntent {
+				c.Exec, c.ExecArgs = "", nil
+			}
+			c.LuaScript = ""
+			quiet = append(quiet, c)
+		}
+		customs = quiet
+	}
+	vars.CustomIntents = customs
+	vars.CustomIntentsExist = len(customs) > 0
+	return nil
+}
+
+func (r *Runner) loadIntents(lang string) ([]vars.JsonIntent, error) {
+	if intents, ok := r.intents[lang]; ok {
+		return intents, nil
+	}
+	var intents []vars.JsonIntent
+	var err error
+	if r.IntentData == "" {
+		intents, err = vars.LoadIntents()
+	} else {
+		var data []byte
+		data, err = os.ReadFile(filepath.Join(r.IntentData, lang+".json"))
+		if err == nil {
+			err = json.Unmarshal(data, &intents)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no intents for %s: %s", lang, err)
+	}
+	if r.intents == nil {
+		r.intents = make(map[string][]vars.JsonIntent)
+	}
+	r.intents[lang] = intents
+	return intents, nil
+}
+
+func (r *Runner) esn() string {
+	if r.ESN != "" {
+		return r.ESN
+	}
+	return replay.DefaultESN
+}
+
+// the way the voice processor does it once speech-to-text is done, without the LLM fallback
+func (r *Runner) runText(c Case) Result {
+	vars.APIConfig.STT.Language = c.Language
+	intents, err := r.loadIntents(c.Language)
+	if err != nil {
+		return Result{Transcript: c.Text, Message: err.Error()}
+	}
+	esn := r.esn()
+	session := fmt.Sprintf("intenttest-%d", time.Now().UnixNano())
+	stream := &replay.IntentStream{Audio: replay.NewAudio(esn, session, nil), Language: pb.LanguageCode_ENGLISH_US}
+	req := &vtt.IntentRequest{
+		Time:       time.Now(),
+		Stream:     stream,
+		Device:     esn,
+		Session:    session,
+		LangString: c.Language,
+		FirstReq:   &pb.StreamingIntentRequest{DeviceId: esn, Session: session},
+		AudioCodec: pb.AudioEncoding_OGG_OPUS,
+	}
+	res := Result{Transcript: c.Text}
+	// templated custom intents go first, as they do in the server
+	m, templated := r.slots.Match(vars.CustomIntents, c.Text)
+	switch {
+	case templated && r.slots.Answer(req, m, c.Text, esn):
+	// robots since 1.8 send Opus, which gets the full parameter checks
+	case !ttr.ProcessTextAll(req, c.Text, intents, true):
+		res.Intent = "intent_system_unmatched"
+	}
+	for _, resp := range stream.Responses {
+		if ir := resp.GetIntentResult(); ir != nil {
+			res.Intent, res.Params = ir.Action, ir.Parameters
+		}
+	}
+	return check(res, c)
+}
+
+func (r *Runner) runAudio(s Suite, c Case, engineLang string) Result {
+	if r.Processor == nil {
+		return Result{Skipped: true, Message: "audio cases need a voice processor"}
+	}
+	if c.Language != engineLang {
+		return Result{Skipped: true, Message: fmt.Sprintf("the speech-to-text engine is set to %s, not %s", engineLang, c.Language)}
+	}
+	if r.proc == nil && r.procErr == nil {
+		r.proc, r.procErr = r.Processor()
+	}
+	if r.procErr != nil {
+		return Result{Message: "error starting the voice processor: " + r.procErr.Error()}
+	}
+	path := c.Audio
+	if !filepath.IsAbs(path) && s.File != "" {
+		path = filepath.Join(filepath.Dir(s.File), path)
+	}
+	pcm, err := replay.ReadPCM(path)
+	if err != nil {
+		return Result{Message: err.Error()}
+	}
+	vars.APIConfig.STT.Language = c.Language
+	got := replay.Replay(r.proc, replay.ModeIntent, r.esn(), pcm, false)
+	res := Result{Transcript: got.Transcript, Intent: got.Intent, Params: got.Params}
+	if got.Intent == "" && got.Error != "" {
+		res.Message = got.Error
+		return res
+	}
+	return check(res, c)
+}
+
+func check(res Result, c Case) Result {
+	var problems []string
+	if res.Intent != c.Intent {
+		problems = append(problems, fmt.Sprintf("expected intent %s, got %s", c.Intent, orNone(res.Intent)))
+	}
+	var keys []string
+	for k := range c.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		got, ok := res.Params[k]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("expected %s=%q, got no %s", k, c.Params[k], k))
+		} else if got != c.Params[k] {
+			problems = append(problems, fmt.Sprintf("expected %s=%q, got %q", k, c.Params[k], got))
+		}
+	}
+	res.Passed = len(problems) == 0
+	res.Message = strings.Join(problems, "; ")
+	return res
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
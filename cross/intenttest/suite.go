@@ -0,0 +1,158 @@
+package intenttest
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// regression cases for intent matching. a suite is a YAML or JSON file like
+//
+//	name: custom intents
+//	language: en-US
+//	cases:
+//	  - text: turn on the lights
+//	    intent: intent_imperative_lights
+//	  - audio: recordings/lights-off.wav
+//	    intent: intent_imperative_lights
+//	    params: {state: "off"}
+
+// DirName is where suites are looked for in the pod directory when none are given
+var DirName = "intent-tests"
+
+type Suite struct {
+	Name string `json:"name" yaml:"name"`
+	// the default for cases which don't set one. en-US if neither do.
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	Cases    []Case `json:"cases" yaml:"cases"`
+	// the file it was loaded from, which audio paths are relative to
+	File string `json:"-" yaml:"-"`
+}
+
+type Case struct {
+	// defaults to the text or audio file
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// what the robot heard. one of text and audio is required.
+	Text string `json:"text,omitempty" yaml:"text,omitempty"`
+	// a recording (.wav, .ogg, .opus or .pcm) which goes through speech-to-text first
+	Audio    string `json:"audio,omitempty" yaml:"audio,omitempty"`
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	Intent   string `json:"intent" yaml:"intent"`
+	// only the parameters listed are compared
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+func (c Case) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Text != "" {
+		return c.Text
+	}
+	return filepath.Base(c.Audio)
+}
+
+func (s Suite) Validate() error {
+	if len(s.Cases) == 0 {
+		return errors.New("no cases")
+	}
+	for i, c := range s.Cases {
+		if (c.Text == "") == (c.Audio == "") {
+			return fmt.Errorf("case %d: needs either text or audio", i+1)
+		}
+		if c.Intent == "" {
+			return fmt.Errorf("case %d (%s): no expected intent", i+1, c.name())
+		}
+	}
+	return nil
+}
+
+func Parse(name string, data []byte) (Suite, error) {
+	var s Suite
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		err = json.Unmarshal(data, &s)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &s)
+	default:
+		return s, fmt.Errorf("%s isn't a .yaml, .yml or .json file", filepath.Base(name))
+	}
+	if err != nil {
+		return s, fmt.Errorf("%s: %s", filepath.Base(name), err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	if s.Language == "" {
+		s.Language = "en-US"
+	}
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("%s: %s", filepath.Base(name), err)
+	}
+	return s, nil
+}
+
+func Load(path string) (Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Suite{}, err
+	}
+	s, err := Parse(path, data)
+	s.File, _ = filepath.Abs(path)
+	return s, err
+}
+
+// LoadAll loads suites from files and directories, in name order within a directory
+func LoadAll(paths []string) ([]Suite, error) {
+	var suites []Suite
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			files = nil
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				switch strings.ToLower(filepath.Ext(entry.Name())) {
+				case ".json", ".yaml", ".yml":
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			s, err := Load(file)
+			if err != nil {
+				return nil, err
+			}
+			suites = append(suites, s)
+		}
+	}
+	return suites, nil
+}
+
+//go:embed builtin.yaml
+var builtinFS embed.FS
+
+// Builtin covers the stock en-US intents, for checking custom intents don't take over their phrases
+func Builtin() Suite {
+	data, _ := builtinFS.ReadFile("builtin.yaml")
+	s, err := Parse("builtin.yaml", data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
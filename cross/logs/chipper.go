@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// chipper logs through its own logger.Println, which has no levels. its lines are picked up from
+// logger.LogTrayChan and get a level and ESN guessed from their text.
+
+var (
+	ansiPattern  = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	trayPrefix   = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2} \d{2}:\d{2}:\d{2}: `)
+	botESNLabels = regexp.MustCompile(`(?i)\b(?:bot|esn|robot|device)[ :=]+([0-9a-f]{8})\b`)
+)
+
+// ParseChipperLine turns a line from logger.LogTrayChan into an entry. known ESNs are matched anywhere in the line.
+func ParseChipperLine(line string, known []string) Entry {
+	line = strings.TrimSpace(ansiPattern.ReplaceAllString(line, ""))
+	e := Entry{Time: time.Now(), Subsystem: "chipper", Level: LevelInfo}
+	if prefix := trayPrefix.FindString(line); prefix != "" {
+		if t, err := time.ParseInLocation("2006.01.02 15:04:05: ", prefix, time.Local); err == nil {
+			e.Time = t
+		}
+		line = line[len(prefix):]
+	}
+	e.Message = line
+	lower := strings.ToLower(line)
+	switch {
+	case strings.Contains(lower, "transcribed text:"):
+		// what the robot heard stays at info, the tray's status shows the last one
+	case strings.Contains(lower, "fatal"), strings.Contains(lower, "error"), strings.Contains(lower, "failed"):
+		e.Level = LevelError
+	case strings.Contains(lower, "warn"):
+		e.Level = LevelWarn
+	case strings.HasPrefix(lower, "debug"), strings.Contains(lower, "stream"):
+		e.Level = LevelDebug
+	}
+	for _, esn := range known {
+		if esn != "" && strings.Contains(lower, strings.ToLower(esn)) {
+			e.ESN = esn
+			return e
+		}
+	}
+	if m := botESNLabels.FindStringSubmatch(line); m != nil {
+		e.ESN = strings.ToLower(m[1])
+	}
+	return e
+}
+
+// CaptureChipper logs everything chipper sends on ch until it is closed. logger.Init must have made the channel.
+// chipper prints its lines itself, so they aren't echoed to stdout again. knownESNs may be nil.
+func CaptureChipper(hub *Hub, ch <-chan string, knownESNs func() []string) {
+	for line := range ch {
+		var known []string
+		if knownESNs != nil {
+			known = knownESNs()
+		}
+		hub.log(ParseChipperLine(line, known), false)
+	}
+}
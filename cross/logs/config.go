@@ -0,0 +1,85 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// <pod dir>/logging.json. levels are per subsystem, e.g. {"level": "info", "subsystems": {"stt": "debug"}}.
+
+const ConfigName = "logging.json"
+
+type Config struct {
+	// for subsystems without their own level
+	Level      Level            `json:"level"`
+	Subsystems map[string]Level `json:"subsystems"`
+	// a log file is rotated once it reaches MaxSizeMB, and MaxFiles of them are kept
+	MaxSizeMB int `json:"maxsizemb"`
+	MaxFiles  int `json:"maxfiles"`
+	// also print to stdout, like logger.Println does with DEBUG_LOGGING
+	Stdout bool `json:"stdout"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Level:      LevelInfo,
+		Subsystems: map[string]Level{},
+		MaxSizeMB:  5,
+		MaxFiles:   5,
+		Stdout:     true,
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+func LogDir(podDir string) string {
+	return filepath.Join(podDir, "logs")
+}
+
+// ReadConfig never fails hard, a missing or broken file gives the defaults
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return conf, nil
+	} else if err != nil {
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, conf.Validate()
+}
+
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(podDir, 0777)
+	return os.WriteFile(ConfigPath(podDir), data, 0644)
+}
+
+func (c Config) Validate() error {
+	if c.MaxSizeMB < 1 {
+		return errors.New("maxsizemb must be at least 1")
+	}
+	if c.MaxFiles < 1 {
+		return errors.New("maxfiles must be at least 1")
+	}
+	return nil
+}
+
+func (c Config) LevelFor(subsystem string) Level {
+	if l, ok := c.Subsystems[subsystem]; ok {
+		return l
+	}
+	return c.Level
+}
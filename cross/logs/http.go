@@ -0,0 +1,116 @@
+package logs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed viewer.html
+var viewerPage []byte
+
+// FilterFromQuery reads ?esn=&subsystem=&level=
+func FilterFromQuery(r *http.Request) (Filter, error) {
+	f := Filter{
+		ESN:       r.FormValue("esn"),
+		Subsystem: r.FormValue("subsystem"),
+		MinLevel:  LevelDebug,
+	}
+	if level := r.FormValue("level"); level != "" {
+		l, err := ParseLevel(level)
+		if err != nil {
+			return f, err
+		}
+		f.MinLevel = l
+	}
+	return f, nil
+}
+
+// ServeRecent writes the newest matching entries as JSON. ?n= limits how many.
+func (h *Hub) ServeRecent(w http.ResponseWriter, r *http.Request) {
+	f, err := FilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, _ := strconv.Atoi(r.FormValue("n"))
+	w.Header().Set("Content-Type", "application/json")
+	entries := h.Recent(f, n)
+	if entries == nil {
+		entries = []Entry{}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ServeStream is a Server-Sent Events tail. it sends ?history= recent entries (default 200), then new ones
+// as "log" events with a JSON entry, and a comment every 15 seconds to keep proxies from closing it.
+func (h *Hub) ServeStream(w http.ResponseWriter, r *http.Request) {
+	f, err := FilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	history := 200
+	if s := r.FormValue("history"); s != "" {
+		history, _ = strconv.Atoi(s)
+	}
+	// subscribe before reading history so nothing falls in between. Seq drops the overlap.
+	ch, cancel := h.Subscribe(f)
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var last uint64
+	send := func(e Entry) bool {
+		if e.Seq <= last {
+			return true
+		}
+		last = e.Seq
+		data, _ := json.Marshal(e)
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", e.Seq, data); err != nil {
+			return false
+		}
+		return true
+	}
+	if history > 0 {
+		for _, e := range h.Recent(f, history) {
+			send(e)
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			if !send(e) {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// ServeViewer is a small page with the live tail. it expects ServeStream at /api-chipper/logs_stream.
+func ServeViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(viewerPage)
+}
@@ -0,0 +1,257 @@
+package logs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the hub takes every log entry, drops what's below its subsystem's level, writes the rest to a rotating
+// file and hands them to live subscribers (the web UI's tail). the last entries are kept in memory so a new
+// subscriber starts with some history.
+
+const recentSize = 1000
+
+type Entry struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Level     Level     `json:"level"`
+	Subsystem string    `json:"subsystem"`
+	ESN       string    `json:"esn,omitempty"`
+	Message   string    `json:"message"`
+}
+
+func (e Entry) String() string {
+	line := e.Time.Format("2006.01.02 15:04:05") + " " + strings.ToUpper(e.Level.String()) + " [" + e.Subsystem + "]"
+	if e.ESN != "" {
+		line += " (" + e.ESN + ")"
+	}
+	return line + " " + e.Message
+}
+
+// Filter selects entries. empty fields match everything.
+type Filter struct {
+	ESN       string
+	Subsystem string
+	MinLevel  Level
+}
+
+func (f Filter) Match(e Entry) bool {
+	if e.Level < f.MinLevel {
+		return false
+	}
+	if f.ESN != "" && !strings.EqualFold(f.ESN, e.ESN) {
+		return false
+	}
+	if f.Subsystem != "" && f.Subsystem != e.Subsystem {
+		return false
+	}
+	return true
+}
+
+type subscriber struct {
+	filter Filter
+	ch     chan Entry
+}
+
+type Hub struct {
+	mu     sync.Mutex
+	conf   Config
+	file   *RotatingFile
+	stdout io.Writer
+	recent []Entry
+	next   int
+	seq    uint64
+	subs   map[*subscriber]struct{}
+}
+
+// NewHub logs into dir. an empty dir means no file, only memory and subscribers.
+func NewHub(dir string, conf Config) *Hub {
+	h := &Hub{
+		conf:   conf,
+		stdout: os.Stdout,
+		subs:   map[*subscriber]struct{}{},
+	}
+	if dir != "" {
+		h.file = &RotatingFile{Dir: dir, Name: "wire-pod"}
+	}
+	h.SetConfig(conf)
+	return h
+}
+
+func (h *Hub) SetConfig(conf Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if conf.Subsystems == nil {
+		conf.Subsystems = map[string]Level{}
+	}
+	h.conf = conf
+	if h.file != nil {
+		h.file.mu.Lock()
+		h.file.MaxSize = int64(conf.MaxSizeMB) << 20
+		h.file.MaxFiles = conf.MaxFiles
+		h.file.mu.Unlock()
+	}
+}
+
+func (h *Hub) Config() Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conf := h.conf
+	conf.Subsystems = map[string]Level{}
+	for k, v := range h.conf.Subsystems {
+		conf.Subsystems[k] = v
+	}
+	return conf
+}
+
+// Enabled reports whether a subsystem logs at level, so callers can skip building expensive messages
+func (h *Hub) Enabled(subsystem string, level Level) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return level >= h.conf.LevelFor(subsystem)
+}
+
+// File is the rotating file, nil without a log dir
+func (h *Hub) File() *RotatingFile {
+	return h.file
+}
+
+func (h *Hub) Log(e Entry) {
+	h.log(e, true)
+}
+
+// echo=false skips stdout, for lines which were printed already
+func (h *Hub) log(e Entry, echo bool) {
+	h.mu.Lock()
+	if e.Level < h.conf.LevelFor(e.Subsystem) {
+		h.mu.Unlock()
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.seq++
+	e.Seq = h.seq
+	if len(h.recent) < recentSize {
+		h.recent = append(h.recent, e)
+	} else {
+		h.recent[h.next] = e
+		h.next = (h.next + 1) % recentSize
+	}
+	var subs []*subscriber
+	for s := range h.subs {
+		if s.filter.Match(e) {
+			subs = append(subs, s)
+		}
+	}
+	stdout := echo && h.conf.Stdout
+	h.mu.Unlock()
+
+	line := e.String() + "\n"
+	if h.file != nil {
+		h.file.Write([]byte(line))
+	}
+	if stdout && h.stdout != nil {
+		io.WriteString(h.stdout, line)
+	}
+	for _, s := range subs {
+		// a slow subscriber misses entries rather than blocking logging
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+}
+
+// Recent returns up to n of the newest matching entries, oldest first. n <= 0 means all that are kept.
+func (h *Hub) Recent(f Filter, n int) []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ordered := append(append([]Entry{}, h.recent[h.next:]...), h.recent[:h.next]...)
+	var matched []Entry
+	for _, e := range ordered {
+		if f.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if n > 0 && len(matched) > n {
+		matched = matched[len(matched)-n:]
+	}
+	return matched
+}
+
+// Subscribe delivers new matching entries until cancel is called
+func (h *Hub) Subscribe(f Filter) (<-chan Entry, func()) {
+	s := &subscriber{filter: f, ch: make(chan Entry, 256)}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, s)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// the hub used by For. until Init is called entries only go to stdout.
+var std struct {
+	sync.RWMutex
+	hub *Hub
+}
+
+// Init makes hub the one For logs to
+func Init(hub *Hub) {
+	std.Lock()
+	std.hub = hub
+	std.Unlock()
+}
+
+// Default is the hub set by Init, or nil
+func Default() *Hub {
+	std.RLock()
+	defer std.RUnlock()
+	return std.hub
+}
+
+// Logger logs for one subsystem, and optionally one robot
+type Logger struct {
+	Subsystem string
+	ESN       string
+}
+
+func For(subsystem string) Logger {
+	return Logger{Subsystem: subsystem}
+}
+
+// Robot tags entries with a robot's ESN, so they show up when the tail is filtered by it
+func (l Logger) Robot(esn string) Logger {
+	l.ESN = esn
+	return l
+}
+
+func (l Logger) log(level Level, a []any) {
+	e := Entry{Level: level, Subsystem: l.Subsystem, ESN: l.ESN, Message: strings.TrimSuffix(fmt.Sprintln(a...), "\n")}
+	if hub := Default(); hub != nil {
+		hub.Log(e)
+		return
+	}
+	e.Time = time.Now()
+	if level >= LevelInfo {
+		fmt.Println(e.String())
+	}
+}
+
+func (l Logger) Debug(a ...any) { l.log(LevelDebug, a) }
+func (l Logger) Info(a ...any)  { l.log(LevelInfo, a) }
+func (l Logger) Warn(a ...any)  { l.log(LevelWarn, a) }
+func (l Logger) Error(a ...any) { l.log(LevelError, a) }
+
+// Println is Info, so a subsystem logger can stand in for logger.Println
+func (l Logger) Println(a ...any) { l.log(LevelInfo, a) }
@@ -0,0 +1,109 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// RotatingFile writes <Dir>/<Name>.log. once it would grow past MaxSize it is renamed to <Name>.1.log,
+// the older ones shift up, and anything past MaxFiles is deleted.
+type RotatingFile struct {
+	Dir      string
+	Name     string
+	MaxSize  int64
+	MaxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func (r *RotatingFile) path(n int) string {
+	if n == 0 {
+		return filepath.Join(r.Dir, r.Name+".log")
+	}
+	return filepath.Join(r.Dir, fmt.Sprintf("%s.%d.log", r.Name, n))
+}
+
+// Path of the file being written
+func (r *RotatingFile) Path() string {
+	return r.path(0)
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(r.Dir, 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	keep := r.MaxFiles
+	if keep < 1 {
+		keep = 1
+	}
+	os.Remove(r.path(keep - 1))
+	for n := keep - 2; n >= 0; n-- {
+		os.Rename(r.path(n), r.path(n+1))
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// Files lists the current file and the rotated ones, newest first
+func (r *RotatingFile) Files() []string {
+	matches, _ := filepath.Glob(filepath.Join(r.Dir, r.Name+"*.log"))
+	sort.Slice(matches, func(i, j int) bool {
+		ii, _ := os.Stat(matches[i])
+		jj, _ := os.Stat(matches[j])
+		if ii == nil || jj == nil {
+			return matches[i] < matches[j]
+		}
+		return ii.ModTime().After(jj.ModTime())
+	})
+	return matches
+}
@@ -16,6 +16,9 @@ type MacOS struct {
 	all.OSFuncs
 }
 
+// set when ReadConfig had to upgrade the stored config
+var LastMigration all.MigrationReport
+
 func NewMacOS() *MacOS {
 	var obj *MacOS
 	return obj
@@ -60,7 +63,7 @@ func (w *MacOS) Init() error {
 }
 
 func MakeDefaultConfig() all.WPConfig {
-	var conf all.WPConfig
+	conf := all.DefaultConfig()
 	execu, _ := os.Executable()
 	conf.InstallPath = filepath.Dir(execu) + "/../Frameworks"
 	conf.RunAtStartup = false
@@ -85,12 +88,31 @@ func (w *MacOS) ReadConfig() (all.WPConfig, error) {
 		w.WriteConfig(conf)
 		return conf, nil
 	}
-	var conf all.WPConfig
-	json.Unmarshal(file, &conf)
+	raw, err := all.RawFromJSON(file)
+	if err != nil {
+		// unreadable config, start over rather than run with zero values
+		fmt.Println("Config is not valid JSON, recreating: " + err.Error())
+		conf := MakeDefaultConfig()
+		w.WriteConfig(conf)
+		return conf, nil
+	}
+	conf, report, err := all.Migrate(raw)
+	if err != nil {
+		return conf, err
+	}
+	if report.Changed() {
+		fmt.Printf("Migrated config from schema v%d to v%d\n", report.FromVersion, report.ToVersion)
+		for _, change := range report.Changes {
+			fmt.Println("  " + change)
+		}
+		LastMigration = report
+		w.WriteConfig(conf)
+	}
 	return conf, nil
 }
 
 func (w *MacOS) WriteConfig(conf all.WPConfig) error {
+	conf.SchemaVersion = all.CurrentSchemaVersion
 	coDir, _ := os.UserConfigDir()
 	os.MkdirAll(filepath.Join(coDir, "wire-pod"), 0777)
 	confFile := filepath.Join(coDir, "wire-pod") + "/wire-pod-conf.json"
@@ -0,0 +1,210 @@
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/zeroconf"
+)
+
+var mdnsLog = logs.For("mdns")
+
+// the record robots look for in escape pod mode
+var escapePodRecord = Record{Service: "_app-proto._tcp", Port: 8084, Text: []string{"txtv=0", "lo=1", "la=2"}}
+
+type Status struct {
+	Running       bool      `json:"running"`
+	Hostname      string    `json:"hostname"`
+	IP            string    `json:"ip"`
+	Interfaces    []string  `json:"interfaces"`
+	Services      []string  `json:"services"`
+	LastAnnounced time.Time `json:"lastannounced"`
+	Announcements int       `json:"announcements"`
+	LastError     string    `json:"lasterror,omitempty"`
+	LastErrorTime time.Time `json:"lasterrortime"`
+}
+
+// Announcer registers the pod's records, and registers them again every interval or when Now is called.
+// it replaces chipper's mdnshandler.PostmDNS, which can only announce escapepod.local.
+type Announcer struct {
+	// the address to announce
+	IP func() string
+	// the web interface's port, for AdvertiseWebUI
+	WebPort func() string
+
+	mu      sync.Mutex
+	conf    Config
+	status  Status
+	running bool
+	now     chan struct{}
+}
+
+func NewAnnouncer(conf Config) *Announcer {
+	return &Announcer{conf: conf, now: make(chan struct{}, 1)}
+}
+
+// SetConfig takes effect with the next registration, which happens right away
+func (a *Announcer) SetConfig(conf Config) {
+	a.mu.Lock()
+	a.conf = conf
+	a.mu.Unlock()
+	a.Now()
+}
+
+func (a *Announcer) Config() Config {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.conf
+}
+
+func (a *Announcer) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := a.status
+	st.Running = a.running
+	return st
+}
+
+// Now registers again without waiting for the interval, e.g. when a robot shows up
+func (a *Announcer) Now() {
+	select {
+	case a.now <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Announcer) fail(err error) {
+	mdnsLog.Warn("mDNS announcement failed: " + err.Error())
+	a.mu.Lock()
+	a.status.LastError = err.Error()
+	a.status.LastErrorTime = time.Now()
+	a.mu.Unlock()
+}
+
+// Run announces until the process exits. calling it again while it runs does nothing.
+func (a *Announcer) Run() {
+	a.mu.Lock()
+	if a.running {
+		a.mu.Unlock()
+		return
+	}
+	a.running = true
+	a.mu.Unlock()
+	conf := a.Config()
+	mdnsLog.Info("Registering " + conf.Hostname + ".local on network (loop)")
+	for {
+		conf = a.Config()
+		servers, err := a.register(conf)
+		if err != nil {
+			a.fail(err)
+		}
+		select {
+		case <-a.now:
+		case <-time.After(time.Duration(conf.IntervalSeconds) * time.Second):
+		}
+		for _, server := range servers {
+			server.Shutdown()
+		}
+		time.Sleep(time.Second / 3)
+	}
+}
+
+func (a *Announcer) records(conf Config) []Record {
+	pod := escapePodRecord
+	pod.Instance = conf.Hostname
+	records := []Record{pod}
+	if conf.AdvertiseWebUI && a.WebPort != nil {
+		if port, err := strconv.Atoi(a.WebPort()); err == nil {
+			records = append(records, Record{Instance: "WirePod", Service: "_http._tcp", Port: port, Text: []string{"path=/"}})
+		}
+	}
+	return append(records, conf.Records...)
+}
+
+func interfaces(names []string) ([]net.Interface, error) {
+	var ifaces []net.Interface
+	for _, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %s", name, err)
+		}
+		ifaces = append(ifaces, *iface)
+	}
+	return ifaces, nil
+}
+
+// register sets up one zeroconf server per record. the ones which worked are returned even if others failed.
+func (a *Announcer) register(conf Config) ([]*zeroconf.Server, error) {
+	ifaces, err := interfaces(conf.Interfaces)
+	if err != nil {
+		return nil, err
+	}
+	ip := a.IP()
+	var servers []*zeroconf.Server
+	var services, failed []string
+	for _, r := range a.records(conf) {
+		server, err := zeroconf.RegisterProxy(r.Instance, r.Service, "local.", r.Port, conf.Hostname, []string{ip}, r.Text, ifaces)
+		if err != nil {
+			failed = append(failed, r.Service+": "+err.Error())
+			continue
+		}
+		server.TTL(conf.TTL)
+		servers = append(servers, server)
+		services = append(services, r.Instance+"."+r.Service+" port "+strconv.Itoa(r.Port))
+	}
+	a.mu.Lock()
+	a.status.Hostname = conf.Hostname + ".local"
+	a.status.IP = ip
+	a.status.Interfaces = conf.Interfaces
+	a.status.Services = services
+	if len(servers) > 0 {
+		a.status.LastAnnounced = time.Now()
+		a.status.Announcements++
+	}
+	a.mu.Unlock()
+	mdnsLog.Debug("mDNS broadcasted")
+	if len(failed) > 0 {
+		return servers, fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return servers, nil
+}
+
+// WatchForVectors calls Now when a robot advertises itself, so a robot which just
+// booted finds the pod quickly. like chipper's PostmDNSWhenNewVector, but it keeps watching.
+func (a *Announcer) WatchForVectors() {
+	time.Sleep(5 * time.Second)
+	for {
+		resolver, err := zeroconf.NewResolver(nil)
+		if err != nil {
+			a.fail(err)
+			return
+		}
+		entries := make(chan *zeroconf.ServiceEntry)
+		ctx, cancel := context.WithTimeout(context.Background(), 80*time.Second)
+		if err := resolver.Browse(ctx, "_ankivector._tcp", "local.", entries); err != nil {
+			cancel()
+			a.fail(err)
+			return
+		}
+		found := false
+		for entry := range entries {
+			if strings.Contains(entry.Service, "ankivector") {
+				mdnsLog.Info("Vector discovered on network, broadcasting mDNS")
+				a.Now()
+				found = true
+				break
+			}
+		}
+		cancel()
+		if found {
+			// robots keep advertising, don't re-register for each of their announcements
+			time.Sleep(time.Minute)
+		}
+	}
+}
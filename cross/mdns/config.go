@@ -0,0 +1,107 @@
+package mdns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// what the pod announces over mDNS. the defaults are what chipper's own announcer did:
+// escapepod.local with an _app-proto._tcp record on 8084, re-registered every 30 seconds.
+
+var ConfigName = "mdns.json"
+
+const DefaultHostname = "escapepod"
+
+// Record is an extra service, e.g. {"instance": "WirePod", "service": "_http._tcp", "port": 8080, "text": ["path=/"]}
+type Record struct {
+	Instance string   `json:"instance"`
+	Service  string   `json:"service"`
+	Port     int      `json:"port"`
+	Text     []string `json:"text,omitempty"`
+}
+
+type Config struct {
+	Hostname string `json:"hostname"`
+	// seconds. address records are always announced with 120, see zeroconf.
+	TTL uint32 `json:"ttl"`
+	// names of the interfaces to announce on. empty means every multicast interface.
+	Interfaces []string `json:"interfaces,omitempty"`
+	// advertise the web interface as _http._tcp
+	AdvertiseWebUI bool     `json:"advertisewebui"`
+	Records        []Record `json:"records,omitempty"`
+	// how often the records are registered again
+	IntervalSeconds int `json:"intervalseconds"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Hostname:        DefaultHostname,
+		TTL:             3200,
+		IntervalSeconds: 30,
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig returns the defaults if the file doesn't exist
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := conf.Validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, nil
+}
+
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(podDir), data, 0644)
+}
+
+var (
+	hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+	servicePattern  = regexp.MustCompile(`^_[a-zA-Z0-9-]{1,15}\._(tcp|udp)$`)
+)
+
+func (c Config) Validate() error {
+	if !hostnamePattern.MatchString(c.Hostname) {
+		return fmt.Errorf("hostname %q must be a single label like escapepod, without .local", c.Hostname)
+	}
+	if c.TTL < 1 {
+		return errors.New("ttl must be at least one second")
+	}
+	if c.IntervalSeconds < 5 {
+		return errors.New("interval must be at least 5 seconds")
+	}
+	for i, r := range c.Records {
+		if strings.TrimSpace(r.Instance) == "" {
+			return fmt.Errorf("record %d: instance is empty", i+1)
+		}
+		if !servicePattern.MatchString(r.Service) {
+			return fmt.Errorf("record %d: service %q must look like _http._tcp", i+1, r.Service)
+		}
+		if r.Port < 1 || r.Port > 65535 {
+			return fmt.Errorf("record %d: port %d is invalid", i+1, r.Port)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/robotactions"
+)
+
+var mqttLog = logs.For("mqtt")
+
+// waits between connection attempts, doubling from the first to the last
+const (
+	minBackoff = time.Second
+	maxBackoff = 2 * time.Minute
+)
+
+// a connection which stayed up this long starts the backoff over when it drops
+const stableAfter = time.Minute
+
+// how long connecting, subscribing or publishing can take
+const opTimeout = 10 * time.Second
+
+// a robot which logged nothing for this long comes online again the next time it does
+const quietAfter = 10 * time.Minute
+
+// Bridge publishes what robots do to a broker and does the commands it gets from it
+type Bridge struct {
+	// nil is robotactions.Robot
+	Actions robotactions.Actions
+
+	mu     sync.Mutex
+	conf   Config
+	client mqtt.Client
+	stop   chan struct{}
+	done   chan struct{}
+	status Status
+	seen   map[string]time.Time
+}
+
+// Status is how the connection is going, for the web interface
+type Status struct {
+	Enabled   bool   `json:"enabled"`
+	Connected bool   `json:"connected"`
+	Broker    string `json:"broker"`
+	// when it connected, while connected
+	Since time.Time `json:"since"`
+	Error string    `json:"error,omitempty"`
+	// when the next attempt is, while disconnected
+	Retry time.Time `json:"retry"`
+}
+
+// Event is what's published to the event topic
+type Event struct {
+	Time time.Time `json:"time"`
+	ESN  string    `json:"esn"`
+	// online, error or command
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	// for commands: which one, and whether it worked
+	Command string `json:"command,omitempty"`
+	OK      *bool  `json:"ok,omitempty"`
+}
+
+// the intent topic's payload
+type intentMessage struct {
+	Time       time.Time         `json:"time"`
+	ESN        string            `json:"esn"`
+	Kind       string            `json:"kind"`
+	Transcript string            `json:"transcript"`
+	Intent     string            `json:"intent"`
+	Params     map[string]string `json:"params"`
+	Response   string            `json:"response,omitempty"`
+	LatencyMs  int64             `json:"latencyms"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Start connects with conf, in the background, and keeps reconnecting until Stop. a bridge which was
+// running is stopped first, so this is also how the config is changed.
+func (b *Bridge) Start(conf Config) {
+	b.Stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.conf = conf
+	b.status = Status{Enabled: conf.Enabled, Broker: conf.Broker}
+	if !conf.Enabled {
+		return
+	}
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	go b.run(conf, b.stop, b.done)
+}
+
+// Stop disconnects, marking the pod offline
+func (b *Bridge) Stop() {
+	b.mu.Lock()
+	stop, done := b.stop, b.done
+	b.stop, b.done = nil, nil
+	b.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (b *Bridge) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+func (b *Bridge) setStatus(f func(*Status)) {
+	b.mu.Lock()
+	f(&b.status)
+	b.mu.Unlock()
+}
+
+func (b *Bridge) run(conf Config, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	backoff := minBackoff
+	for {
+		lost := make(chan error, 1)
+		client, err := b.connect(conf, lost)
+		if err == nil {
+			up := time.Now()
+			b.setStatus(func(s *Status) { *s = Status{Enabled: true, Connected: true, Broker: conf.Broker, Since: up} })
+			mqttLog.Info("Connected to " + conf.Broker)
+			b.mu.Lock()
+			b.client = client
+			b.mu.Unlock()
+			select {
+			case <-stop:
+				b.mu.Lock()
+				b.client = nil
+				b.status.Connected = false
+				b.mu.Unlock()
+				if conf.Topics.Status != "" {
+					client.Publish(conf.Topics.Status, conf.QoS, true, "offline").WaitTimeout(opTimeout)
+				}
+				client.Disconnect(250)
+				return
+			case err = <-lost:
+			}
+			b.mu.Lock()
+			b.client = nil
+			b.mu.Unlock()
+			if time.Since(up) >= stableAfter {
+				backoff = minBackoff
+			}
+			err = errors.New("connection lost: " + err.Error())
+		}
+		retry := time.Now().Add(backoff)
+		b.setStatus(func(s *Status) { *s = Status{Enabled: true, Broker: conf.Broker, Error: err.Error(), Retry: retry} })
+		mqttLog.Warn(fmt.Sprintf("MQTT broker %s: %s, trying again in %s", conf.Broker, err, backoff))
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// paho's own reconnecting is off, so run does it with its backoff and the status shows it
+func (b *Bridge) connect(conf Config, lost chan<- error) (mqtt.Client, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(conf.Broker).
+		SetClientID(clientID(conf)).
+		SetUsername(conf.Username).
+		SetPassword(conf.Password).
+		SetProtocolVersion(4).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetConnectTimeout(opTimeout).
+		SetKeepAlive(30 * time.Second).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			select {
+			case lost <- err:
+			default:
+			}
+		})
+	switch {
+	case strings.HasPrefix(conf.Broker, "ssl://"), strings.HasPrefix(conf.Broker, "tls://"),
+		strings.HasPrefix(conf.Broker, "mqtts://"), strings.HasPrefix(conf.Broker, "wss://"):
+		tlsConf, err := conf.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConf)
+	}
+	if conf.Topics.Status != "" {
+		opts.SetWill(conf.Topics.Status, "offline", conf.QoS, true)
+	}
+	client := mqtt.NewClient(opts)
+	if err := wait(client.Connect()); err != nil {
+		return nil, err
+	}
+	if err := b.subscribe(client, conf); err != nil {
+		client.Disconnect(0)
+		return nil, err
+	}
+	if conf.Topics.Status != "" {
+		if err := wait(client.Publish(conf.Topics.Status, conf.QoS, true, "online")); err != nil {
+			client.Disconnect(0)
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func clientID(conf Config) string {
+	if conf.ClientID != "" {
+		return conf.ClientID
+	}
+	host, _ := os.Hostname()
+	return "wire-pod-" + host
+}
+
+func wait(t mqtt.Token) error {
+	if !t.WaitTimeout(opTimeout) {
+		return errors.New("timed out")
+	}
+	return t.Error()
+}
+
+// sessions are clean, so this is done on every connect
+func (b *Bridge) subscribe(client mqtt.Client, conf Config) error {
+	commands := []struct {
+		name, topic string
+		do          func(a robotactions.Actions, esn, payload string) error
+	}{
+		{"say", conf.Topics.Say, func(a robotactions.Actions, esn, text string) error { return a.Say(esn, text) }},
+		{"animation", conf.Topics.Animation, func(a robotactions.Actions, esn, name string) error { return a.PlayAnimation(esn, name) }},
+		{"lua", conf.Topics.Lua, func(a robotactions.Actions, esn, script string) error { return a.RunLua(esn, script) }},
+	}
+	for _, c := range commands {
+		if c.topic == "" {
+			continue
+		}
+		c := c
+		filter, esnOf := commandFilter(c.topic)
+		err := wait(client.Subscribe(filter, conf.QoS, func(_ mqtt.Client, msg mqtt.Message) {
+			esn := esnOf(msg.Topic())
+			payload := strings.TrimSpace(string(msg.Payload()))
+			// commands can take a while, and paho waits for handlers before reading more
+			go b.command(esn, c.name, payload, c.do)
+		}))
+		if err != nil {
+			return fmt.Errorf("subscribing to %s: %s", filter, err)
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) command(esn, name, payload string, do func(robotactions.Actions, string, string) error) {
+	log := mqttLog.Robot(esn)
+	if payload == "" {
+		log.Warn("Empty " + name + " command, ignoring it")
+		return
+	}
+	log.Info("MQTT command: " + name)
+	actions := b.Actions
+	if actions == nil {
+		actions = robotactions.Robot{}
+	}
+	err := do(actions, esn, payload)
+	ok := err == nil
+	ev := Event{Time: time.Now(), ESN: esn, Type: "command", Command: name, OK: &ok}
+	if err != nil {
+		log.Warn("Error doing MQTT " + name + " command: " + err.Error())
+		ev.Message = err.Error()
+	}
+	b.PublishEvent(ev)
+}
+
+func (b *Bridge) publish(topic string, payload []byte) {
+	b.mu.Lock()
+	client, qos := b.client, b.conf.QoS
+	b.mu.Unlock()
+	if client == nil || topic == "" {
+		return
+	}
+	// published in the background, so a slow broker doesn't hold up the robot's request
+	go func() {
+		if err := wait(client.Publish(topic, qos, false, payload)); err != nil {
+			mqttLog.Warn("Error publishing to " + topic + ": " + err.Error())
+		}
+	}()
+}
+
+// PublishRecord sends a finished request to the intent and transcript topics. it's the voice history's
+// Notify.
+func (b *Bridge) PublishRecord(rec history.Record) {
+	b.mu.Lock()
+	topics := b.conf.Topics
+	b.mu.Unlock()
+	params := rec.Params
+	if params == nil {
+		params = map[string]string{}
+	}
+	data, _ := json.Marshal(intentMessage{
+		Time:       rec.Time,
+		ESN:        rec.ESN,
+		Kind:       rec.Kind,
+		Transcript: rec.Transcript,
+		Intent:     rec.Intent,
+		Params:     params,
+		Response:   rec.Response,
+		LatencyMs:  rec.LatencyMs,
+		Error:      rec.Error,
+	})
+	b.publish(Topic(topics.Intent, rec.ESN), data)
+	if rec.Transcript != "" {
+		b.publish(Topic(topics.Transcript, rec.ESN), []byte(rec.Transcript))
+	}
+}
+
+func (b *Bridge) PublishEvent(ev Event) {
+	b.mu.Lock()
+	topic := b.conf.Topics.Event
+	b.mu.Unlock()
+	data, _ := json.Marshal(ev)
+	b.publish(Topic(topic, ev.ESN), data)
+}
+
+// WatchLogs publishes robot events from the pod's log: a robot coming online, the first time it's heard
+// from or after being quiet for a while, and errors with it
+func (b *Bridge) WatchLogs(hub *logs.Hub) {
+	entries, _ := hub.Subscribe(logs.Filter{})
+	for e := range entries {
+		// its own errors would loop
+		if e.ESN == "" || e.Subsystem == "mqtt" {
+			continue
+		}
+		esn := strings.ToLower(e.ESN)
+		b.mu.Lock()
+		if b.seen == nil {
+			b.seen = map[string]time.Time{}
+		}
+		last, ok := b.seen[esn]
+		b.seen[esn] = e.Time
+		b.mu.Unlock()
+		if !ok || e.Time.Sub(last) >= quietAfter {
+			b.PublishEvent(Event{Time: e.Time, ESN: e.ESN, Type: "online"})
+		}
+		if e.Level >= logs.LevelError {
+			b.PublishEvent(Event{Time: e.Time, ESN: e.ESN, Type: "error", Message: e.Message})
+		}
+	}
+}
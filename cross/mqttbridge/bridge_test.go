@@ -0,0 +1,222 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/mqttbridge/mqtttest"
+)
+
+const testESN = "00e20100"
+
+// what a stand-in robot was told to do, failing with err
+type testActions struct {
+	did chan string
+	err error
+}
+
+func (a *testActions) do(s string) error {
+	a.did <- s
+	return a.err
+}
+
+func (a *testActions) Say(esn, text string) error           { return a.do(esn + " say " + text) }
+func (a *testActions) PlayAnimation(esn, name string) error { return a.do(esn + " animation " + name) }
+func (a *testActions) RunLua(esn, script string) error      { return a.do(esn + " lua " + script) }
+
+func startBridge(t *testing.T) (*mqtttest.Broker, *Bridge, *testActions) {
+	t.Helper()
+	broker := mqtttest.NewBroker()
+	t.Cleanup(broker.Close)
+	actions := &testActions{did: make(chan string, 10)}
+	bridge := &Bridge{Actions: actions}
+	conf := DefaultConfig()
+	conf.Enabled = true
+	conf.Broker = broker.URL
+	conf.ClientID = "wire-pod-test"
+	bridge.Start(conf)
+	t.Cleanup(bridge.Stop)
+	waitConnected(t, bridge)
+	return broker, bridge, actions
+}
+
+func waitConnected(t *testing.T, bridge *Bridge) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !bridge.Status().Connected {
+		if time.Now().After(deadline) {
+			t.Fatalf("didn't connect: %+v", bridge.Status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func receive(t *testing.T, ch <-chan mqtttest.Message) mqtttest.Message {
+	t.Helper()
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was published")
+	}
+	return mqtttest.Message{}
+}
+
+func TestPublishRecord(t *testing.T) {
+	broker, bridge, _ := startBridge(t)
+	intents, cancel := broker.Watch("wirepod/+/intent")
+	defer cancel()
+	transcripts, cancel2 := broker.Watch("wirepod/+/transcript")
+	defer cancel2()
+
+	bridge.PublishRecord(history.Record{
+		Time:       time.Now(),
+		ESN:        testESN,
+		Kind:       history.KindIntent,
+		Transcript: "turn on the kitchen lights",
+		Intent:     "intent_imperative_lights",
+		Params:     map[string]string{"room": "kitchen"},
+		LatencyMs:  420,
+	})
+	m := receive(t, intents)
+	if m.Topic != "wirepod/"+testESN+"/intent" {
+		t.Errorf("published to %s", m.Topic)
+	}
+	var msg intentMessage
+	if err := json.Unmarshal(m.Payload, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ESN != testESN || msg.Intent != "intent_imperative_lights" || msg.Params["room"] != "kitchen" || msg.LatencyMs != 420 {
+		t.Errorf("got %+v", msg)
+	}
+	if m := receive(t, transcripts); string(m.Payload) != "turn on the kitchen lights" {
+		t.Errorf("transcript %q", m.Payload)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	broker, _, actions := startBridge(t)
+	events, cancel := broker.Watch("wirepod/+/event")
+	defer cancel()
+	for _, c := range []struct{ topic, payload, want string }{
+		{"say", "hello there", testESN + " say hello there"},
+		{"animation", " happy\n", testESN + " animation happy"},
+		{"lua", `sayText("hi")`, testESN + ` lua sayText("hi")`},
+	} {
+		t.Run(c.topic, func(t *testing.T) {
+			broker.Publish("wirepod/"+testESN+"/"+c.topic, []byte(c.payload))
+			select {
+			case did := <-actions.did:
+				if did != c.want {
+					t.Errorf("did %q, want %q", did, c.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("nothing was done")
+			}
+			var ev Event
+			if err := json.Unmarshal(receive(t, events).Payload, &ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Type != "command" || ev.Command != c.topic || ev.ESN != testESN || ev.OK == nil || !*ev.OK {
+				t.Errorf("event %+v", ev)
+			}
+		})
+	}
+}
+
+// a command which fails says why in its event, and an empty one isn't done
+func TestCommandError(t *testing.T) {
+	broker, _, actions := startBridge(t)
+	actions.err = errors.New("robot not found")
+	events, cancel := broker.Watch("wirepod/+/event")
+	defer cancel()
+	broker.Publish("wirepod/"+testESN+"/say", []byte("  "))
+	broker.Publish("wirepod/"+testESN+"/say", []byte("hello"))
+	if did := <-actions.did; did != testESN+" say hello" {
+		t.Errorf("did %q", did)
+	}
+	var ev Event
+	if err := json.Unmarshal(receive(t, events).Payload, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.OK == nil || *ev.OK || ev.Message != "robot not found" {
+		t.Errorf("event %+v", ev)
+	}
+}
+
+// the status topic is online while the bridge is, and the will or Stop makes it offline
+func TestStatus(t *testing.T) {
+	broker, bridge, _ := startBridge(t)
+	if m, ok := broker.Retained("wirepod/status"); !ok || string(m.Payload) != "online" {
+		t.Errorf("status is %q", m.Payload)
+	}
+	// a dropped connection publishes the will, and the bridge comes back
+	broker.Disconnect()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if m, _ := broker.Retained("wirepod/status"); string(m.Payload) == "offline" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the will wasn't published")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for {
+		if m, _ := broker.Retained("wirepod/status"); string(m.Payload) == "online" && bridge.Status().Connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("didn't reconnect: %+v", bridge.Status())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	bridge.Stop()
+	if s := bridge.Status(); s.Connected {
+		t.Errorf("still connected: %+v", s)
+	}
+	for {
+		if m, _ := broker.Retained("wirepod/status"); string(m.Payload) == "offline" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("offline wasn't published on Stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// what "wire-pod mqtt --local" does
+func TestCheck(t *testing.T) {
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	conf := DefaultConfig()
+	conf.Broker = broker.URL
+	var steps []string
+	if err := Check(conf, 5*time.Second, func(step string) { steps = append(steps, step) }); err != nil {
+		t.Fatal(err)
+	}
+	// connecting, the request and three commands
+	if len(steps) != 5 {
+		t.Errorf("steps %q", steps)
+	}
+}
+
+func TestCommandFilter(t *testing.T) {
+	for _, tt := range []struct{ topic, filter, matched, esn string }{
+		{"wirepod/{esn}/say", "wirepod/+/say", "wirepod/00e20100/say", "00e20100"},
+		{"{esn}/lua", "+/lua", "00e20100/lua", "00e20100"},
+		{"home/robots/{esn}", "home/robots/+", "home/robots/00e20100", "00e20100"},
+	} {
+		filter, esnOf := commandFilter(tt.topic)
+		if filter != tt.filter || esnOf(tt.matched) != tt.esn {
+			t.Errorf("%s: filter %s, esn %s", tt.topic, filter, esnOf(tt.matched))
+		}
+	}
+	if Topic("wirepod/{esn}/intent", "00e20100") != "wirepod/00e20100/intent" {
+		t.Error(Topic("wirepod/{esn}/intent", "00e20100"))
+	}
+}
@@ -0,0 +1,196 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/mqttbridge/mqtttest"
+)
+
+const usage = `usage: wire-pod mqtt [options]
+
+connects to the broker in the pod's MQTT config and checks the bridge works through it: that it
+publishes requests and takes commands. commands go to a stand-in robot, so no robot does anything.
+the config doesn't need to be enabled for this.
+
+options:
+`
+
+// the robot commands and requests are checked with
+const checkESN = "mqtt-check"
+
+// RunCLI checks the bridge with the config in podDir
+func RunCLI(podDir string, args []string) int {
+	fs := flag.NewFlagSet("mqtt", flag.ContinueOnError)
+	broker := fs.String("broker", "", "use this broker instead of the config's")
+	local := fs.Bool("local", false, "use a broker in this process, to check the bridge itself")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long each step can take")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	conf, err := ReadConfig(podDir)
+	if err != nil {
+		fmt.Println("error reading " + ConfigPath(podDir) + ": " + err.Error())
+		return 1
+	}
+	if *broker != "" {
+		conf.Broker = *broker
+	}
+	if *local {
+		b := mqtttest.NewBroker()
+		defer b.Close()
+		conf.Broker = b.URL
+		conf.Username, conf.Password = "", ""
+	}
+	if err := Check(conf, *timeout, func(step string) { fmt.Println("ok   " + step) }); err != nil {
+		fmt.Println("FAIL " + err.Error())
+		return 1
+	}
+	return 0
+}
+
+// what a stand-in robot was told to do
+type checkActions struct {
+	done chan string
+}
+
+func (a *checkActions) did(s string) error {
+	a.done <- s
+	return nil
+}
+
+func (a *checkActions) Say(esn, text string) error           { return a.did("say " + text) }
+func (a *checkActions) PlayAnimation(esn, name string) error { return a.did("animation " + name) }
+func (a *checkActions) RunLua(esn, script string) error      { return a.did("lua " + script) }
+
+// Check runs a bridge with conf against its broker, with a second client in the role of the home
+// automation: it waits for a request to be published and sends each command the config has a topic for.
+// passed is called after each step which worked.
+func Check(conf Config, timeout time.Duration, passed func(step string)) error {
+	conf.Enabled = true
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	actions := &checkActions{done: make(chan string, 1)}
+	bridge := &Bridge{Actions: actions}
+	bridge.Start(conf)
+	defer bridge.Stop()
+	deadline := time.Now().Add(timeout)
+	for !bridge.Status().Connected {
+		if time.Now().After(deadline) {
+			if s := bridge.Status(); s.Error != "" {
+				return errors.New("connecting: " + s.Error)
+			}
+			return errors.New("connecting: timed out")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	passed("connected to " + conf.Broker)
+
+	// the home automation's side
+	other := conf
+	other.ClientID = clientID(conf) + "-check"
+	other.Topics.Status = ""
+	got := make(chan mqtt.Message, 10)
+	opts := mqtt.NewClientOptions().
+		AddBroker(other.Broker).
+		SetClientID(other.ClientID).
+		SetUsername(other.Username).
+		SetPassword(other.Password).
+		SetProtocolVersion(4).
+		SetAutoReconnect(false).
+		SetConnectTimeout(timeout)
+	if tlsConf, err := other.tlsConfig(); err == nil {
+		opts.SetTLSConfig(tlsConf)
+	}
+	client := mqtt.NewClient(opts)
+	if err := wait(client.Connect()); err != nil {
+		return errors.New("connecting the checking client: " + err.Error())
+	}
+	defer client.Disconnect(250)
+	receive := func(topic string) error {
+		return wait(client.Subscribe(topic, conf.QoS, func(_ mqtt.Client, m mqtt.Message) { got <- m }))
+	}
+	expect := func(topic string) (mqtt.Message, error) {
+		for {
+			select {
+			case m := <-got:
+				if m.Topic() == topic {
+					return m, nil
+				}
+			case <-time.After(timeout):
+				return nil, errors.New("nothing came on " + topic)
+			}
+		}
+	}
+
+	if conf.Topics.Intent != "" {
+		topic := Topic(conf.Topics.Intent, checkESN)
+		if err := receive(topic); err != nil {
+			return err
+		}
+		bridge.PublishRecord(history.Record{Time: time.Now(), ESN: checkESN, Kind: history.KindText, Transcript: "what time is it", Intent: "intent_clock_time"})
+		m, err := expect(topic)
+		if err != nil {
+			return err
+		}
+		var msg intentMessage
+		if err := json.Unmarshal(m.Payload(), &msg); err != nil || msg.Intent != "intent_clock_time" {
+			return fmt.Errorf("%s got %s", topic, m.Payload())
+		}
+		passed("request published to " + topic)
+	}
+
+	if conf.Topics.Event != "" {
+		if err := receive(Topic(conf.Topics.Event, checkESN)); err != nil {
+			return err
+		}
+	}
+	commands := []struct{ topic, payload, want string }{
+		{conf.Topics.Say, "hello", "say hello"},
+		{conf.Topics.Animation, "happy", "animation happy"},
+		{conf.Topics.Lua, "sayText(\"hello\")", "lua sayText(\"hello\")"},
+	}
+	for _, c := range commands {
+		if c.topic == "" {
+			continue
+		}
+		topic := Topic(c.topic, checkESN)
+		if err := wait(client.Publish(topic, conf.QoS, false, c.payload)); err != nil {
+			return fmt.Errorf("publishing to %s: %s", topic, err)
+		}
+		select {
+		case did := <-actions.done:
+			if did != c.want {
+				return fmt.Errorf("%s did %q", topic, did)
+			}
+		case <-time.After(timeout):
+			return errors.New("nothing was done for " + topic)
+		}
+		if conf.Topics.Event != "" {
+			m, err := expect(Topic(conf.Topics.Event, checkESN))
+			if err != nil {
+				return err
+			}
+			var ev Event
+			if err := json.Unmarshal(m.Payload(), &ev); err != nil || ev.Type != "command" || ev.OK == nil || !*ev.OK {
+				return fmt.Errorf("%s got %s", m.Topic(), m.Payload())
+			}
+		}
+		passed("command taken from " + topic)
+	}
+	return nil
+}
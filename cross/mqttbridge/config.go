@@ -0,0 +1,199 @@
+package mqttbridge
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the bridge to an MQTT broker, for home automation. off until a broker is set up.
+
+var ConfigName = "mqtt.json"
+
+// where {esn} is in a topic, the robot's serial number goes
+const esnPlaceholder = "{esn}"
+
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// tcp://host:1883, ssl://host:8883, ws://host/mqtt or wss://host/mqtt. mqtt:// and mqtts:// are taken too.
+	Broker string `json:"broker"`
+	// empty is wire-pod- and the host name
+	ClientID string `json:"clientid"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// PEM files: a CA for brokers with their own certificates, and a client certificate and key for ones
+	// which ask for them
+	CAFile   string `json:"cafile"`
+	CertFile string `json:"certfile"`
+	KeyFile  string `json:"keyfile"`
+	// don't check the broker's certificate
+	Insecure bool   `json:"insecure"`
+	QoS      byte   `json:"qos"`
+	Topics   Topics `json:"topics"`
+}
+
+// Topics say where things are published and where commands are taken from. an empty topic is off.
+// command topics need {esn} as one of their levels, to know which robot the command is for.
+type Topics struct {
+	// JSON for every voice or text request once it's done: intent, parameters, transcript, what was said
+	Intent string `json:"intent"`
+	// the transcript alone, as plain text
+	Transcript string `json:"transcript"`
+	// JSON for what a robot does besides requests: coming online, errors, how commands went
+	Event string `json:"event"`
+	// online or offline, retained. the broker sets it to offline if the pod goes away.
+	Status string `json:"status"`
+	// the text to say
+	Say string `json:"say"`
+	// an animation by the name the LLM uses: happy, sad, celebrate...
+	Animation string `json:"animation"`
+	// a Lua script to run, as /api-lua/run_script does
+	Lua string `json:"lua"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Broker: "tcp://localhost:1883",
+		Topics: Topics{
+			Intent:     "wirepod/{esn}/intent",
+			Transcript: "wirepod/{esn}/transcript",
+			Event:      "wirepod/{esn}/event",
+			Status:     "wirepod/status",
+			Say:        "wirepod/{esn}/say",
+			Animation:  "wirepod/{esn}/animation",
+			Lua:        "wirepod/{esn}/lua",
+		},
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig returns the defaults if the file doesn't exist
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := conf.Validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, nil
+}
+
+// the file has the broker's password, so only the pod's user can read it
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(podDir), data, 0600)
+}
+
+func (c Config) Validate() error {
+	if c.Enabled || c.Broker != "" {
+		u, err := url.Parse(c.Broker)
+		if err != nil {
+			return fmt.Errorf("broker: %s", err)
+		}
+		switch u.Scheme {
+		case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+		default:
+			return errors.New("the broker needs to start with tcp://, ssl://, ws:// or wss://")
+		}
+		if u.Host == "" {
+			return errors.New("the broker needs a host")
+		}
+	}
+	if c.QoS > 2 {
+		return errors.New("QoS can be 0, 1 or 2")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("a client certificate needs its key, and the other way around")
+	}
+	for _, t := range []struct{ name, topic string }{
+		{"intent", c.Topics.Intent}, {"transcript", c.Topics.Transcript}, {"event", c.Topics.Event}, {"status", c.Topics.Status},
+		{"say", c.Topics.Say}, {"animation", c.Topics.Animation}, {"lua", c.Topics.Lua},
+	} {
+		if strings.ContainsAny(t.topic, "+#") {
+			return fmt.Errorf("the %s topic can't have wildcards", t.name)
+		}
+	}
+	for _, t := range []struct{ name, topic string }{
+		{"say", c.Topics.Say}, {"animation", c.Topics.Animation}, {"lua", c.Topics.Lua},
+	} {
+		if t.topic != "" && esnLevel(t.topic) < 0 {
+			return fmt.Errorf("the %s topic needs {esn} as one of its levels", t.name)
+		}
+	}
+	if strings.Contains(c.Topics.Status, esnPlaceholder) {
+		return errors.New("the status topic is the pod's, it can't have {esn}")
+	}
+	return nil
+}
+
+// the scheme says whether it's TLS, the files what goes with it
+func (c Config) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New(c.CAFile + " has no PEM certificates")
+		}
+		conf.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// Topic is topic for the robot esn
+func Topic(topic, esn string) string {
+	return strings.ReplaceAll(topic, esnPlaceholder, esn)
+}
+
+// which level of topic is {esn}, or -1
+func esnLevel(topic string) int {
+	for i, level := range strings.Split(topic, "/") {
+		if level == esnPlaceholder {
+			return i
+		}
+	}
+	return -1
+}
+
+// the filter for a command topic's robots, and how to get the ESN from a topic it matched
+func commandFilter(topic string) (string, func(string) string) {
+	i := esnLevel(topic)
+	levels := strings.Split(topic, "/")
+	levels[i] = "+"
+	return strings.Join(levels, "/"), func(t string) string {
+		if got := strings.Split(t, "/"); i < len(got) {
+			return got[i]
+		}
+		return ""
+	}
+}
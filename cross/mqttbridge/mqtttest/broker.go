@@ -0,0 +1,511 @@
+package mqtttest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// an in-process MQTT 3.1.1 broker, for testing the bridge without one installed. it keeps no sessions and
+// delivers everything at QoS 0, which is all a test needs.
+
+// Message is a publish as the broker saw it
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retained bool
+}
+
+type Broker struct {
+	// tcp://127.0.0.1:port, or ssl:// for NewTLSBroker
+	URL string
+	// when set, clients must connect with these
+	Username string
+	Password string
+
+	ln       net.Listener
+	mu       sync.Mutex
+	clients  map[*client]bool
+	retained map[string]Message
+	watchers map[*watcher]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type client struct {
+	conn net.Conn
+	mu   sync.Mutex
+	subs []string
+	will *Message
+}
+
+type watcher struct {
+	filter string
+	ch     chan Message
+}
+
+// NewBroker starts a broker on a free port on 127.0.0.1
+func NewBroker() *Broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("mqtttest: " + err.Error())
+	}
+	return start(ln, "tcp://")
+}
+
+// NewTLSBroker starts a broker which takes TLS connections with cert
+func NewTLSBroker(cert tls.Certificate) *Broker {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		panic("mqtttest: " + err.Error())
+	}
+	return start(ln, "ssl://")
+}
+
+func start(ln net.Listener, scheme string) *Broker {
+	b := &Broker{
+		URL:      scheme + ln.Addr().String(),
+		ln:       ln,
+		clients:  map[*client]bool{},
+		retained: map[string]Message{},
+		watchers: map[*watcher]bool{},
+	}
+	b.wg.Add(1)
+	go b.accept()
+	return b
+}
+
+// Close stops the broker and drops every client
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	for w := range b.watchers {
+		close(w.ch)
+		delete(b.watchers, w)
+	}
+	b.mu.Unlock()
+	b.ln.Close()
+	b.Disconnect()
+	b.wg.Wait()
+}
+
+// Disconnect drops every client as a broken connection would, so their wills are published
+func (b *Broker) Disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		c.conn.Close()
+	}
+}
+
+// Clients is how many clients are connected
+func (b *Broker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// Publish sends a message to the subscribers, as a client publishing it would
+func (b *Broker) Publish(topic string, payload []byte) {
+	b.route(Message{Topic: topic, Payload: payload})
+}
+
+// Watch gets every message published to topics matching filter, until cancel is called
+func (b *Broker) Watch(filter string) (<-chan Message, func()) {
+	w := &watcher{filter: filter, ch: make(chan Message, 100)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(w.ch)
+		return w.ch, func() {}
+	}
+	b.watchers[w] = true
+	return w.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.watchers[w] {
+			delete(b.watchers, w)
+			close(w.ch)
+		}
+	}
+}
+
+// Retained is the retained message on topic
+func (b *Broker) Retained(topic string) (Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.retained[topic]
+	return m, ok
+}
+
+func (b *Broker) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.serve(conn)
+		}()
+	}
+}
+
+func (b *Broker) route(m Message) {
+	b.mu.Lock()
+	if m.Retained {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	var to []*client
+	for c := range b.clients {
+		if c.subscribed(m.Topic) {
+			to = append(to, c)
+		}
+	}
+	for w := range b.watchers {
+		if Matches(w.filter, m.Topic) {
+			select {
+			case w.ch <- m:
+			default:
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range to {
+		c.publish(m.Topic, m.Payload, false)
+	}
+}
+
+// packet types
+const (
+	typeConnect     = 1
+	typeConnack     = 2
+	typePublish     = 3
+	typePuback      = 4
+	typePubrec      = 5
+	typePubrel      = 6
+	typePubcomp     = 7
+	typeSubscribe   = 8
+	typeSuback      = 9
+	typeUnsubscribe = 10
+	typeUnsuback    = 11
+	typePingreq     = 12
+	typePingresp    = 13
+	typeDisconnect  = 14
+)
+
+var errProtocol = errors.New("protocol error")
+
+func (b *Broker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	kind, _, body, err := readPacket(r)
+	if err != nil || kind != typeConnect {
+		return
+	}
+	c := &client{conn: conn}
+	rc, err := b.connect(c, body)
+	if err != nil {
+		return
+	}
+	c.write(packet(typeConnack<<4, []byte{0, rc}))
+	if rc != 0 {
+		return
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.clients[c] = true
+	b.mu.Unlock()
+	clean := false
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+		if !clean && c.will != nil {
+			b.route(*c.will)
+		}
+	}()
+	for {
+		kind, flags, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch kind {
+		case typePublish:
+			m, id, err := parsePublish(flags, body)
+			if err != nil {
+				return
+			}
+			switch m.QoS {
+			case 1:
+				c.write(packet(typePuback<<4, id))
+			case 2:
+				c.write(packet(typePubrec<<4, id))
+			}
+			b.route(m)
+		case typePubrel:
+			c.write(packet(typePubcomp<<4, body))
+		case typePuback, typePubrec, typePubcomp:
+			// everything goes out at QoS 0, so these aren't expected
+		case typeSubscribe:
+			if err := b.subscribe(c, body); err != nil {
+				return
+			}
+		case typeUnsubscribe:
+			if len(body) < 2 {
+				return
+			}
+			id, rest := body[:2], body[2:]
+			for len(rest) > 0 {
+				var filter string
+				if filter, rest, err = readString(rest); err != nil {
+					return
+				}
+				c.unsubscribe(filter)
+			}
+			c.write(packet(typeUnsuback<<4, id))
+		case typePingreq:
+			c.write(packet(typePingresp<<4, nil))
+		case typeDisconnect:
+			clean = true
+			return
+		default:
+			return
+		}
+	}
+}
+
+// the CONNACK return code
+func (b *Broker) connect(c *client, body []byte) (byte, error) {
+	name, rest, err := readString(body)
+	if err != nil || len(rest) < 4 {
+		return 0, errProtocol
+	}
+	level, flags := rest[0], rest[1]
+	rest = rest[4:]
+	if name != "MQTT" || level != 4 {
+		return 1, nil
+	}
+	if _, rest, err = readString(rest); err != nil {
+		return 0, errProtocol
+	}
+	if flags&0x04 != 0 {
+		var topic, msg string
+		if topic, rest, err = readString(rest); err != nil {
+			return 0, errProtocol
+		}
+		if msg, rest, err = readString(rest); err != nil {
+			return 0, errProtocol
+		}
+		c.will = &Message{Topic: topic, Payload: []byte(msg), QoS: flags >> 3 & 3, Retained: flags&0x20 != 0}
+	}
+	var user, pass string
+	if flags&0x80 != 0 {
+		if user, rest, err = readString(rest); err != nil {
+			return 0, errProtocol
+		}
+	}
+	if flags&0x40 != 0 {
+		if pass, _, err = readString(rest); err != nil {
+			return 0, errProtocol
+		}
+	}
+	b.mu.Lock()
+	want, wantPass := b.Username, b.Password
+	b.mu.Unlock()
+	if (want != "" || wantPass != "") && (user != want || pass != wantPass) {
+		return 4, nil
+	}
+	return 0, nil
+}
+
+func (b *Broker) subscribe(c *client, body []byte) error {
+	if len(body) < 2 {
+		return errProtocol
+	}
+	id, rest := body[:2], body[2:]
+	ack := append([]byte{}, id...)
+	var filters []string
+	for len(rest) > 0 {
+		filter, r, err := readString(rest)
+		if err != nil || len(r) < 1 {
+			return errProtocol
+		}
+		rest = r[1:]
+		c.subscribe(filter)
+		filters = append(filters, filter)
+		ack = append(ack, 0)
+	}
+	c.write(packet(typeSuback<<4, ack))
+	// retained messages go to new subscribers
+	b.mu.Lock()
+	var retained []Message
+	for _, m := range b.retained {
+		for _, f := range filters {
+			if Matches(f, m.Topic) {
+				retained = append(retained, m)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, m := range retained {
+		c.publish(m.Topic, m.Payload, true)
+	}
+	return nil
+}
+
+func (c *client) subscribe(filter string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.subs {
+		if f == filter {
+			return
+		}
+	}
+	c.subs = append(c.subs, filter)
+}
+
+func (c *client) unsubscribe(filter string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, f := range c.subs {
+		if f == filter {
+			c.subs = append(c.subs[:i], c.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *client) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.subs {
+		if Matches(f, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *client) publish(topic string, payload []byte, retained bool) {
+	var flags byte
+	if retained {
+		flags = 1
+	}
+	c.write(packet(typePublish<<4|flags, append(appendString(nil, topic), payload...)))
+}
+
+func (c *client) write(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.Write(p)
+}
+
+// Matches says whether topic fits a subscription filter with + and # wildcards
+func Matches(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func readPacket(r *bufio.Reader) (kind, flags byte, body []byte, err error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	length, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+		if shift > 21 {
+			return 0, 0, nil, errProtocol
+		}
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return first >> 4, first & 0x0f, body, nil
+}
+
+func parsePublish(flags byte, body []byte) (Message, []byte, error) {
+	m := Message{QoS: flags >> 1 & 3, Retained: flags&1 != 0}
+	topic, rest, err := readString(body)
+	if err != nil {
+		return m, nil, err
+	}
+	m.Topic = topic
+	var id []byte
+	if m.QoS > 0 {
+		if len(rest) < 2 {
+			return m, nil, errProtocol
+		}
+		id, rest = rest[:2], rest[2:]
+	}
+	m.Payload = append([]byte{}, rest...)
+	return m, id, nil
+}
+
+func packet(first byte, body []byte) []byte {
+	p := []byte{first}
+	n := len(body)
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errProtocol
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errProtocol
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,156 @@
+package podapp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	"github.com/ncruces/zenity"
+)
+
+// quick checks of the things which usually go wrong, for the tray's "Run Diagnostics" and /api-chipper/diagnostics
+
+type DiagnosticCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+func RunDiagnostics() []DiagnosticCheck {
+	var checks []DiagnosticCheck
+	add := func(name string, ok bool, detail string) {
+		checks = append(checks, DiagnosticCheck{Name: name, OK: ok, Detail: detail})
+	}
+
+	if vars.APIConfig.PastInitialSetup {
+		add("Setup", true, "done")
+	} else {
+		add("Setup", false, "not done yet, use the web interface")
+	}
+
+	if !chipperServing {
+		add("Chipper", false, "not running")
+	} else if err := dialLocal(vars.APIConfig.Server.Port); err != nil {
+		add("Chipper", false, "port "+vars.APIConfig.Server.Port+" isn't reachable: "+err.Error())
+	} else {
+		add("Chipper", true, "listening on port "+vars.APIConfig.Server.Port)
+	}
+
+	ok, detail := checkCertificates()
+	add("Certificates", ok, detail)
+
+	if err := dialLocal(vars.WebPort); err != nil {
+		add("Web interface", false, "port "+vars.WebPort+" isn't reachable: "+err.Error())
+	} else {
+		add("Web interface", true, "listening on port "+vars.WebPort)
+	}
+
+	switch {
+	case pod.VoiceProcessor == nil:
+		add("Speech-to-text", false, os.Getenv("STT_SERVICE")+" isn't initialized")
+	case vars.APIConfig.STT.Service == "vosk" && pod.VoskModels != nil && !pod.VoskModels.IsInstalled(vars.APIConfig.STT.Language):
+		add("Speech-to-text", false, "no Vosk model is installed for "+vars.APIConfig.STT.Language)
+	default:
+		add("Speech-to-text", true, strings.TrimPrefix(sttText(), "Speech-to-text: "))
+	}
+
+	if len(vars.BotInfo.Robots) == 0 {
+		add("Robots", false, "no robot has been authenticated yet")
+	} else {
+		var seen []string
+		for _, robot := range vars.BotInfo.Robots {
+			seen = append(seen, robot.Esn+" last seen "+ago(robotLastSeen(robot.Esn)))
+		}
+		add("Robots", true, strings.Join(seen, ", "))
+	}
+
+	if hub := logs.Default(); hub != nil && hub.File() != nil {
+		f, err := os.OpenFile(hub.File().Path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			add("Log file", false, err.Error())
+		} else {
+			f.Close()
+			add("Log file", true, hub.File().Path())
+		}
+	} else {
+		add("Log file", false, "logging to a file isn't set up")
+	}
+	return checks
+}
+
+func dialLocal(port string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", port), 2*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// the same certificates StartChipper loads
+func checkCertificates() (bool, string) {
+	var certPub, certPriv []byte
+	var err error
+	if vars.APIConfig.Server.EPConfig {
+		certPub, err = os.ReadFile("./epod/ep.crt")
+		if err == nil {
+			certPriv, err = os.ReadFile("./epod/ep.key")
+		}
+	} else {
+		certPub, err = os.ReadFile(vars.CertPath)
+		if err == nil {
+			certPriv, err = os.ReadFile(vars.KeyPath)
+		}
+	}
+	if err != nil {
+		return false, err.Error()
+	}
+	pair, err := tls.X509KeyPair(certPub, certPriv)
+	if err != nil {
+		return false, err.Error()
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false, err.Error()
+	}
+	if time.Now().After(cert.NotAfter) {
+		return false, "expired on " + cert.NotAfter.Format("2006-01-02")
+	}
+	return true, "valid until " + cert.NotAfter.Format("2006-01-02")
+}
+
+func diagnosticsText(checks []DiagnosticCheck) string {
+	var text string
+	for _, check := range checks {
+		result := "OK"
+		if !check.OK {
+			result = "PROBLEM"
+		}
+		text += fmt.Sprintf("%s: %s - %s\n", check.Name, result, check.Detail)
+	}
+	return text
+}
+
+func showDiagnostics() {
+	checks := RunDiagnostics()
+	text := diagnosticsText(checks)
+	for _, check := range checks {
+		if !check.OK {
+			zenity.Warning(text, zenity.WarningIcon, zenity.Title(mBoxTitle+" Diagnostics"))
+			return
+		}
+	}
+	zenity.Info(text, zenity.Icon(mBoxIcon()), zenity.Title(mBoxTitle+" Diagnostics"))
+}
+
+func diagnosticsAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RunDiagnostics())
+}
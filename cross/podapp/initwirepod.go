@@ -13,8 +13,11 @@ import (
 	"github.com/digital-dream-labs/api/go/tokenpb"
 	"github.com/digital-dream-labs/hugh/log"
 	"github.com/getlantern/systray"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/slots"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/WirePod/cross/voicepause"
 	"github.com/kercre123/wire-pod/chipper/pkg/logger"
-	"github.com/kercre123/wire-pod/chipper/pkg/mdnshandler"
 	chipperserver "github.com/kercre123/wire-pod/chipper/pkg/servers/chipper"
 	jdocsserver "github.com/kercre123/wire-pod/chipper/pkg/servers/jdocs"
 	tokenserver "github.com/kercre123/wire-pod/chipper/pkg/servers/token"
@@ -34,7 +37,6 @@ var serverOne cmux.CMux
 var serverTwo cmux.CMux
 var listenerOne net.Listener
 var listenerTwo net.Listener
-var voiceProcessor *wp.Server
 
 var NotSetUp string = "Wire-pod is not setup. Use the webserver at port " + vars.WebPort + " to set up wire-pod."
 
@@ -89,17 +91,18 @@ func grpcServe(l net.Listener, p *wp.Server) error {
 		log.Fatal(err)
 	}
 
+	pp := history.Processor{Next: voicepause.Processor{Server: slots.Processor{Next: p, Slots: pod.Slots}, Pause: pod.Pause}, Recorder: pod.History}
 	s, _ := chipperserver.New(
-		chipperserver.WithIntentProcessor(p),
-		chipperserver.WithKnowledgeGraphProcessor(p),
-		chipperserver.WithIntentGraphProcessor(p),
+		chipperserver.WithIntentProcessor(pp),
+		chipperserver.WithKnowledgeGraphProcessor(pp),
+		chipperserver.WithIntentGraphProcessor(pp),
 	)
 
 	tokenServer := tokenserver.NewTokenServer()
 	jdocsServer := jdocsserver.NewJdocsServer()
 	//jdocsserver.IniToJson()
 
-	chipperpb.RegisterChipperGrpcServer(srv.Transport(), s)
+	chipperpb.RegisterChipperGrpcServer(srv.Transport(), history.ChipperServer{ChipperGrpcServer: s, Recorder: pod.History})
 	jdocspb.RegisterJdocsServer(srv.Transport(), jdocsServer)
 	tokenpb.RegisterTokenServer(srv.Transport(), tokenServer)
 
@@ -108,14 +111,18 @@ func grpcServe(l net.Listener, p *wp.Server) error {
 
 func BeginWirepodSpecific(sttInitFunc func() error, sttHandlerFunc interface{}, voiceProcessorName string) error {
 	logger.Init()
+	go watchStatus(pod.InitLogs())
 
 	// begin wirepod stuff
 	vars.Init()
+	applyProfilePorts()
+	pod.Init(voiceProcessorName)
 	var err error
-	voiceProcessor, err = wp.New(sttInitFunc, sttHandlerFunc, voiceProcessorName)
+	pod.VoiceProcessor, err = pod.BuildVoiceProcessor(sttengine.Engine{Name: voiceProcessorName, Init: sttInitFunc, STT: sttHandlerFunc})
 	wpweb.SttInitFunc = sttInitFunc
 	go sdkWeb.BeginServer()
 	http.HandleFunc("/api-chipper/", ChipperHTTPApi)
+	pod.HandlePage("/update", serveUpdatePage)
 	if err != nil {
 		return err
 	}
@@ -129,20 +136,21 @@ func StartFromProgramInit(sttInitFunc func() error, sttHandlerFunc interface{},
 		vars.APIConfig.PastInitialSetup = false
 		vars.WriteConfigToDisk()
 		NeedsSetupMsg()
-		systray.SetTooltip("wire-pod must be set up at http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort)
+		systray.SetTooltip(normalTooltip())
 	} else if !vars.APIConfig.PastInitialSetup {
 		logger.Println("Wire-pod is not setup. Use the webserver at port " + vars.WebPort + " to set up wire-pod.")
 		NeedsSetupMsg()
-		systray.SetTooltip("wire-pod must be set up at http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort)
+		systray.SetTooltip(normalTooltip())
 	} else if vars.APIConfig.STT.Service == "vosk" && vars.APIConfig.STT.Language == "" {
 		logger.Println("\033[33m\033[1mLanguage value is blank, but STT service is Vosk. Reinitiating setup process.\033[0m")
 		logger.Println("Wire-pod is not setup. Use the webserver at port " + vars.WebPort + " to set up wire-pod.")
-		NeedsSetupMsg()
-		systray.SetTooltip("wire-pod must be set up at http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort)
 		vars.APIConfig.PastInitialSetup = false
+		NeedsSetupMsg()
+		systray.SetTooltip(normalTooltip())
 	} else {
 		go StartChipper(true)
 	}
+	go confirmUpdateHealth()
 	// main thread is configuration ws
 	wpweb.StartWebServer()
 }
@@ -155,19 +163,30 @@ func IfFileExist(name string) bool {
 	return true
 }
 
-func RestartServer() {
-	if chipperServing {
-		serverOne.Close()
+// closes what StartChipper opened. the second listener is only there in escape pod mode.
+func stopChipper() {
+	if !chipperServing {
+		return
+	}
+	chipperServing = false
+	serverOne.Close()
+	listenerOne.Close()
+	if serverTwo != nil {
 		serverTwo.Close()
-		listenerOne.Close()
 		listenerTwo.Close()
+		serverTwo, listenerTwo = nil, nil
 	}
+}
+
+func RestartServer() {
+	stopChipper()
 	go StartChipper(false)
 }
 
 func StartChipper(fromInit bool) {
+	chipperWanted = true
 	if vars.APIConfig.Server.EPConfig {
-		go mdnshandler.PostmDNS()
+		pod.StartMDNS()
 	}
 	// load certs
 	var certPub []byte
@@ -201,6 +220,7 @@ func StartChipper(fromInit bool) {
 		logger.Println(err)
 		ExitProgram(1)
 	}
+	applyProfilePorts()
 	logger.Println("Starting chipper server at port " + vars.APIConfig.Server.Port)
 	listenerOne, err = tls.Listen("tcp", ":"+vars.APIConfig.Server.Port, &tls.Config{
 		Certificates: []tls.Certificate{cert},
@@ -214,7 +234,7 @@ func StartChipper(fromInit bool) {
 	serverOne = cmux.New(listenerOne)
 	grpcListenerOne := serverOne.Match(cmux.HTTP2())
 	httpListenerOne := serverOne.Match(cmux.HTTP1Fast())
-	go grpcServe(grpcListenerOne, voiceProcessor)
+	go grpcServe(grpcListenerOne, pod.VoiceProcessor)
 	go httpServe(httpListenerOne)
 
 	if vars.APIConfig.Server.EPConfig && os.Getenv("NO8084") != "true" {
@@ -231,11 +251,11 @@ func StartChipper(fromInit bool) {
 		serverTwo = cmux.New(listenerTwo)
 		grpcListenerTwo := serverTwo.Match(cmux.HTTP2())
 		httpListenerTwo := serverTwo.Match(cmux.HTTP1Fast())
-		go grpcServe(grpcListenerTwo, voiceProcessor)
+		go grpcServe(grpcListenerTwo, pod.VoiceProcessor)
 		go httpServe(httpListenerTwo)
 	}
 
-	systray.SetTooltip("wire-pod is running.\n" + "http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort)
+	systray.SetTooltip(normalTooltip())
 	var discrete bool
 	if len(os.Args) > 1 {
 		if strings.Contains(os.Args[1], "-d") {
@@ -252,14 +272,20 @@ func StartChipper(fromInit bool) {
 	fmt.Println("\033[33m\033[1mwire-pod started successfully!\033[0m")
 
 	chipperServing = true
+	// a restart may have started the next server by the time this one stops
+	serving := serverOne
 	if vars.APIConfig.Server.EPConfig && os.Getenv("NO8084") != "true" {
 		go serverOne.Serve()
 		serverTwo.Serve()
 		logger.Println("Stopping chipper server")
-		chipperServing = false
+		if serverOne == serving {
+			chipperServing = false
+		}
 	} else {
 		serverOne.Serve()
 		logger.Println("Stopping chipper server")
-		chipperServing = false
+		if serverOne == serving {
+			chipperServing = false
+		}
 	}
 }
@@ -8,12 +8,12 @@ import (
 	"runtime"
 	"runtime/debug"
 	"strconv"
+	"strings"
 
 	"github.com/getlantern/systray"
 	all "github.com/kercre123/WirePod/cross/all"
 	"github.com/kercre123/wire-pod/chipper/pkg/logger"
 	"github.com/kercre123/wire-pod/chipper/pkg/vars"
-	stt "github.com/kercre123/wire-pod/chipper/pkg/wirepod/stt/vosk"
 	"github.com/ncruces/zenity"
 )
 
@@ -42,9 +42,9 @@ func checkIfRestartNeeded() bool {
 	if err != nil {
 		return false
 	}
-	if conf.NeedsRestart && host != "escapepod" {
+	if conf.NeedsRestart && !strings.EqualFold(host, mdnsHostname()) {
 		return true
-	} else if conf.NeedsRestart && host == "escapepod" {
+	} else if conf.NeedsRestart {
 		conf.NeedsRestart = false
 		cross.WriteConfig(conf)
 		return false
@@ -54,7 +54,10 @@ func checkIfRestartNeeded() bool {
 
 // the actual entrypoint function!
 func StartWirePod(crossOS all.OSFuncs) {
-	cross = crossOS
+	os.Args = append(os.Args[:1], initApp(crossOS, os.Args[1:])...)
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -70,9 +73,9 @@ func StartWirePod(crossOS all.OSFuncs) {
 		}
 	}()
 
-	err := cross.Init()
-	if err != nil {
-		ErrMsg(err)
+	if len(os.Args) > 2 && os.Args[1] == "update-apply" {
+		pid, _ := strconv.Atoi(os.Args[2])
+		waitForPID(pid)
 	}
 	if checkIfRestartNeeded() {
 		zenity.Error(
@@ -88,7 +91,7 @@ func StartWirePod(crossOS all.OSFuncs) {
 		ErrMsg(err)
 	}
 	pidFile, err := os.ReadFile(confDir + "/runningPID")
-	if err == nil {
+	if err == nil && activeProfile.IsDefault() {
 		pid, _ := strconv.Atoi(string(pidFile))
 		if is, _ := cross.IsPIDProcessRunning(pid); is {
 			zenity.Error(
@@ -99,7 +102,7 @@ func StartWirePod(crossOS all.OSFuncs) {
 			os.Exit(1)
 		}
 	}
-	if cross.IsPodAlreadyRunning() {
+	if (activeProfile.IsDefault() && cross.IsPodAlreadyRunning()) || (!activeProfile.IsDefault() && profileAlreadyRunning()) {
 		zenity.Error(
 			"WirePod is already running.",
 			zenity.ErrorIcon,
@@ -112,8 +115,17 @@ func StartWirePod(crossOS all.OSFuncs) {
 	if err != nil {
 		ErrMsg(err)
 	}
-	conf.LastRunningPID = os.Getpid()
-	err = cross.WriteConfig(conf)
+	if activeProfile.IsDefault() {
+		// the install is shared, so only the default profile updates it
+		initUpdater(conf)
+		if applyPendingUpdate(conf) {
+			os.Exit(0)
+		}
+		conf.LastRunningPID = os.Getpid()
+		err = cross.WriteConfig(conf)
+	} else {
+		err = writeProfilePID()
+	}
 	if err != nil {
 		ErrMsg(err)
 	}
@@ -124,15 +136,62 @@ func StartWirePod(crossOS all.OSFuncs) {
 		ErrMsg(fmt.Errorf("error setting runtime directory to " + conf.InstallPath + "/chipper"))
 	}
 
-	if conf.WSPort != "8080" && conf.WSPort != "0" {
+	if !activeProfile.IsDefault() {
+		os.Setenv("WEBSERVER_PORT", activeProfile.WebPort)
+	} else if conf.WSPort != "8080" && conf.WSPort != "0" {
 		os.Setenv("WEBSERVER_PORT", conf.WSPort)
 	}
 
 	systray.Run(onReady, onExit)
 }
 
+// sets up the OS config, then the profile and the pod. the subcommands and the tray read the config, which
+// on windows is in the registry and can't be read before cross.Init. returns args without --profile.
+func initApp(crossOS all.OSFuncs, args []string) []string {
+	cross = crossOS
+	if err := cross.Init(); err != nil {
+		ErrMsg(err)
+	}
+	if len(args) > 0 && args[0] == "profile" {
+		return args
+	}
+	args = selectProfile(args)
+	usePod()
+	return args
+}
+
+// the subcommands which run instead of the tray. ok is false if args isn't one.
+func runCommand(args []string) (code int, ok bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "profile":
+		return RunProfileCLI(args[1:]), true
+	case "backup":
+		return pod.RunBackupCLI(args[1:]), true
+	case "pause":
+		return RunPauseCLI(args[1:]), true
+	case "replay":
+		return pod.RunReplayCLI(args[1:]), true
+	case "intent-test":
+		return pod.RunIntentTestCLI(args[1:]), true
+	case "simulate":
+		return RunSimulateCLI(args[1:]), true
+	case "selftest":
+		return pod.RunSelfTestCLI(ChipperHTTPApi, args[1:]), true
+	case "mqtt":
+		return pod.RunMQTTCLI(args[1:]), true
+	}
+	return 0, false
+}
+
 func ExitProgram(code int) {
-	cross.OnExit()
+	if activeProfile.IsDefault() {
+		cross.OnExit()
+	} else {
+		os.Remove(profilePIDPath())
+	}
 	systray.Quit()
 	os.Exit(code)
 }
@@ -144,7 +203,8 @@ func onExit() {
 func onReady() {
 	// windows-specific
 
-	os.Setenv("STT_SERVICE", "vosk")
+	engine := pod.SelectSTTEngine()
+	os.Setenv("STT_SERVICE", engine.Name)
 	os.Setenv("DEBUG_LOGGING", "true")
 
 	systrayIcon, err := os.ReadFile(filepath.Join(cross.ResourcesPath(), "icons/ico") + "/pod24x24.ico")
@@ -158,14 +218,21 @@ func onReady() {
 
 	systray.SetIcon(systrayIcon)
 	if runtime.GOOS == "windows" {
-		systray.SetTitle("WirePod")
+		systray.SetTitle(mBoxTitle)
 	}
-	systray.SetTooltip("WirePod is starting...")
+	systray.SetTooltip(mBoxTitle + " is starting...")
 	mQuit := systray.AddMenuItem("Quit", "Quit WirePod")
 	mBrowse := systray.AddMenuItem("Web Interface", "Open web UI")
+	addProfilesMenu()
+	mStatus := addStatusMenu(systrayIcon)
+	mRestart := systray.AddMenuItem("Restart Chipper", "Restart the server robots talk to")
+	mPause := systray.AddMenuItemCheckbox("Pause Voice Processing", "Stop processing voice requests until resumed or the pause schedule changes", false)
+	mDiagnostics := systray.AddMenuItem("Run Diagnostics", "Check the server, certificates, speech-to-text and robots")
 	mConfig := systray.AddMenuItem("Config Folder", "Open config folder in case you need to. The web UI should have everything you need.")
+	mLogs := systray.AddMenuItem("Logs", "Open the live log viewer")
 	mStartup := systray.AddMenuItem("Run On Startup", "")
 	mAbout := systray.AddMenuItem("About", "About WirePod")
+	mUpdate := systray.AddMenuItem("Check For Updates", "Check for a new version of WirePod")
 
 	conf, _ := cross.ReadConfig()
 	if conf.RunAtStartup {
@@ -173,6 +240,10 @@ func onReady() {
 	} else {
 		mStartup.Uncheck()
 	}
+	if !activeProfile.IsDefault() {
+		// startup runs the default profile
+		mStartup.Hide()
+	}
 
 	go func() {
 		for {
@@ -186,6 +257,17 @@ func onReady() {
 				ExitProgram(0)
 			case <-mBrowse.ClickedCh:
 				go openBrowser("http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort)
+			case <-mLogs.ClickedCh:
+				go openBrowser(logsURL())
+			case <-mRestart.ClickedCh:
+				if vars.APIConfig.PastInitialSetup {
+					RestartServer()
+				}
+			case <-mPause.ClickedCh:
+				// the checkmark follows through OnChange
+				pod.Pause.Set(!mPause.Checked())
+			case <-mDiagnostics.ClickedCh:
+				go showDiagnostics()
 			case <-mConfig.ClickedCh:
 				conf, _ := os.UserConfigDir()
 				go openFileExplorer(filepath.Join(conf, vars.PodName))
@@ -193,6 +275,8 @@ func onReady() {
 				zenity.Info("WirePod is an Escape Pod alternative which is able to get any Anki/DDL Vector robot setup and working with voice commands.\n\nVersion: "+conf.Version,
 					zenity.Icon(mBoxIcon()),
 					zenity.Title("WirePod"))
+			case <-mUpdate.ClickedCh:
+				go onUpdateClicked(mUpdate)
 			case <-mStartup.ClickedCh:
 				if mStartup.Checked() {
 					mStartup.Uncheck()
@@ -204,8 +288,17 @@ func onReady() {
 			}
 		}
 	}()
+	pod.Pause.OnChange = func(paused bool) {
+		if paused {
+			mPause.Check()
+		} else {
+			mPause.Uncheck()
+		}
+	}
+	go updateLoop(mUpdate)
+	go mStatus.run()
 
-	StartFromProgramInit(stt.Init, stt.STT, stt.Name)
+	StartFromProgramInit(engine.Init, engine.STT, engine.Name)
 }
 
 func openBrowser(url string) {
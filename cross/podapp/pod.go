@@ -0,0 +1,83 @@
+package podapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kercre123/WirePod/cross/mdns"
+	"github.com/kercre123/WirePod/cross/podkit"
+	"github.com/kercre123/WirePod/cross/profiles"
+	"github.com/kercre123/WirePod/cross/robotsim"
+	"github.com/kercre123/WirePod/cross/voicepause"
+	"github.com/kercre123/wire-pod/chipper/pkg/logger"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// the features shared with the debian package, see podkit. set by usePod once the profile is known.
+var pod *podkit.Pod
+
+// the pod's config dir, which is the profile's
+func podDir() string {
+	confDir, _ := os.UserConfigDir()
+	return filepath.Join(confDir, vars.PodName)
+}
+
+// must run after selectProfile, which sets vars.PodName
+func usePod() {
+	pod = podkit.New(podDir())
+	if !activeProfile.IsDefault() {
+		pod.Profile = activeProfile.Name
+	}
+	pod.Restart = RestartServer
+	pod.Start = func() { StartChipper(false) }
+	pod.Stop = stopChipper
+	pod.InitOffline = initOffline
+}
+
+// readies chipper for use without the server: working directory, config, intents. initApp must have run,
+// the install path is in the OS config.
+func initOffline() error {
+	conf, err := cross.ReadConfig()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(filepath.Join(conf.InstallPath, "chipper")); err != nil {
+		return fmt.Errorf("error setting runtime directory to %s: %s", filepath.Join(conf.InstallPath, "chipper"), err)
+	}
+	vars.Packaged = true
+	logger.Init()
+	vars.Init()
+	return nil
+}
+
+// the hostname robots look for, escapepod unless configured otherwise
+func mdnsHostname() string {
+	conf, _ := mdns.ReadConfig(pod.Dir)
+	return conf.Hostname
+}
+
+func logsURL() string {
+	return "http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort + "/logs"
+}
+
+// "pause on|off|status", against the running instance
+func RunPauseCLI(args []string) int {
+	port := activeProfile.WebPort
+	if activeProfile.IsDefault() {
+		// the installer's web port, a guess at 8080 could reach some other pod
+		conf, err := cross.ReadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading the wire-pod config:", err)
+			return 1
+		}
+		port = conf.WSPort
+	}
+	return voicepause.RunCLI("http://127.0.0.1:"+port, args)
+}
+
+// "simulate", against the running instance's chipper server
+func RunSimulateCLI(args []string) int {
+	confDir, _ := os.UserConfigDir()
+	return robotsim.RunCLI("127.0.0.1:"+profiles.ChipperPort(confDir, activeProfile), args)
+}
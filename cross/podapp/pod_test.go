@@ -0,0 +1,124 @@
+package podapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	all "github.com/kercre123/WirePod/cross/all"
+	"github.com/kercre123/WirePod/cross/podtest"
+)
+
+// like the registry on windows, the config can't be read before Init
+type testOS struct {
+	all.OSFuncs
+	conf   all.WPConfig
+	inited bool
+}
+
+func (o *testOS) Init() error {
+	o.inited = true
+	return nil
+}
+
+func (o *testOS) ReadConfig() (all.WPConfig, error) {
+	if !o.inited {
+		return all.WPConfig{}, errors.New("you must run podonwin.Init()")
+	}
+	return o.conf, nil
+}
+
+// not the user's config dir or profile
+func testConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("WIREPOD_PROFILE", "")
+	return dir
+}
+
+func testPod(t *testing.T) {
+	t.Helper()
+	usePod()
+	// not the user's config dir
+	pod.Dir = t.TempDir()
+}
+
+// StartChipper, RestartServer and ChipperHTTPApi against the harness's stub engine and robot
+func TestPod(t *testing.T) {
+	testPod(t)
+	podtest.Test(t, pod.SelfTest(ChipperHTTPApi), podtest.Options{})
+}
+
+// what ChipperHTTPApi doesn't answer itself goes to the pod's features
+func TestChipperHTTPApiServesPod(t *testing.T) {
+	testPod(t)
+	w := httptest.NewRecorder()
+	ChipperHTTPApi(w, httptest.NewRequest("GET", "/api-chipper/get_stt_engines", nil))
+	var engines []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &engines); err != nil {
+		t.Fatalf("get_stt_engines answered %q: %s", w.Body.String(), err)
+	}
+	if len(engines) == 0 {
+		t.Error("no STT engines")
+	}
+}
+
+// pause goes to the installer's web port, and doesn't guess one without the config
+func TestPauseCLIWebPort(t *testing.T) {
+	testConfigDir(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api-chipper/pause_status" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"paused":false}`)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	fake := &testOS{conf: all.WPConfig{WSPort: u.Port()}}
+	args := initApp(fake, []string{"pause", "status"})
+	if code, ok := runCommand(args); !ok || code != 0 {
+		t.Errorf("pause status = %d, %t, want 0 from the server on port %s", code, ok, u.Port())
+	}
+
+	fake.inited = false
+	if code := RunPauseCLI([]string{"status"}); code != 1 {
+		t.Errorf("pause status without a config = %d, want 1", code)
+	}
+}
+
+// replay and intent-test ready chipper through InitOffline, which reads the install path from the config
+func TestOfflineCommandsAfterInit(t *testing.T) {
+	testConfigDir(t)
+	install := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(install, "chipper"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	args := initApp(&testOS{conf: all.WPConfig{InstallPath: install, WSPort: "8080"}}, []string{"--profile", "default", "intent-test", "smoke.yaml"})
+	if want := []string{"intent-test", "smoke.yaml"}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("initApp() = %q, want %q", args, want)
+	}
+	if err := pod.InitOffline(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.Getwd()
+	want, _ := filepath.EvalSymlinks(filepath.Join(install, "chipper"))
+	if got, _ = filepath.EvalSymlinks(got); got != want {
+		t.Errorf("working directory = %s, want %s", got, want)
+	}
+}
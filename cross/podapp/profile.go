@@ -0,0 +1,127 @@
+package podapp
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/getlantern/systray"
+	"github.com/kercre123/WirePod/cross/profiles"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	"github.com/ncruces/zenity"
+)
+
+// the default profile behaves exactly like wire-pod did before profiles. other profiles get their own
+// data directory through vars.PodName, their own ports and mDNS setting, and don't update the install.
+
+var activeProfile = profiles.Profile{Name: profiles.Default}
+
+// profiles can't take the default profile's web port, which the installer may have changed. the config is
+// only readable after cross.Init.
+func setDefaultWebPort() {
+	if conf, err := cross.ReadConfig(); err == nil && conf.WSPort != "" && conf.WSPort != "0" {
+		profiles.DefaultWebPort = conf.WSPort
+	}
+}
+
+// must run before anything reads vars.PodName
+func selectProfile(args []string) []string {
+	setDefaultWebPort()
+	name, rest, err := profiles.ParseFlag(args)
+	if err == nil {
+		confDir, _ := os.UserConfigDir()
+		activeProfile, err = profiles.Select(confDir, name)
+	}
+	if err != nil {
+		zenity.Error(err.Error(), zenity.ErrorIcon, zenity.Title(mBoxTitle))
+		os.Exit(2)
+	}
+	vars.PodName = activeProfile.PodName()
+	mBoxTitle = activeProfile.Title("WirePod")
+	if !activeProfile.IsDefault() {
+		if !activeProfile.MDNS {
+			os.Setenv("DISABLE_MDNS", "true")
+		}
+		// 8084 is a fixed port, the default profile has it in escape pod mode
+		os.Setenv("NO8084", "true")
+	}
+	return rest
+}
+
+func RunProfileCLI(args []string) int {
+	setDefaultWebPort()
+	confDir, _ := os.UserConfigDir()
+	return profiles.RunCLI(confDir, args)
+}
+
+// must run after vars.Init, which reads the port chosen during setup, and before chipper listens, since
+// use_ip and use_ep set it again
+func applyProfilePorts() {
+	if !activeProfile.IsDefault() {
+		vars.APIConfig.Server.Port = activeProfile.ChipperPort
+	}
+}
+
+// the default profile's PID is in the OS config (LastRunningPID), the others' in their data directory
+func profilePIDPath() string {
+	confDir, _ := os.UserConfigDir()
+	return filepath.Join(confDir, vars.PodName, "running.pid")
+}
+
+func profileAlreadyRunning() bool {
+	data, err := os.ReadFile(profilePIDPath())
+	if err != nil {
+		return false
+	}
+	pid, _ := strconv.Atoi(string(data))
+	running, _ := cross.IsPIDProcessRunning(pid)
+	return pid != 0 && running
+}
+
+func writeProfilePID() error {
+	os.MkdirAll(filepath.Dir(profilePIDPath()), 0777)
+	return os.WriteFile(profilePIDPath(), []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// a tray submenu to start the other profiles, each of which gets its own tray icon
+func addProfilesMenu() {
+	confDir, _ := os.UserConfigDir()
+	ps, _ := profiles.Read(confDir)
+	if len(ps.Profiles) == 0 {
+		return
+	}
+	others := append([]profiles.Profile{{Name: profiles.Default}}, ps.Profiles...)
+	menu := systray.AddMenuItem("Profile: "+activeProfile.Name, "Start another WirePod profile")
+	for _, p := range others {
+		if p.Name == activeProfile.Name {
+			continue
+		}
+		item := menu.AddSubMenuItem("Start "+p.Name, "Start the "+p.Name+" profile in another WirePod")
+		go func(p profiles.Profile) {
+			for range item.ClickedCh {
+				startProfile(p)
+			}
+		}(p)
+	}
+}
+
+func startProfile(p profiles.Profile) {
+	exe, err := os.Executable()
+	if err == nil {
+		err = exec.Command(exe, "--profile", p.Name).Start()
+	}
+	if err != nil {
+		zenity.Error("Error starting profile "+p.Name+": "+err.Error(), zenity.ErrorIcon, zenity.Title(mBoxTitle))
+	}
+}
+
+func profileAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		profiles.Profile
+		DataDir string `json:"datadir"`
+	}{activeProfile, filepath.Dir(vars.ApiConfigPath)})
+}
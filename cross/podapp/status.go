@@ -0,0 +1,245 @@
+package podapp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// the tray's status submenu. what chipper doesn't keep track of itself (when a robot was last heard from,
+// what it said, the last error) is picked up from the log hub.
+
+// errors older than this don't count toward the error state anymore
+const statusErrorWindow = 2 * time.Minute
+
+// the tray can't add items on the fly in a sensible place, so there is a fixed number of robot slots
+const statusRobotSlots = 8
+
+var status struct {
+	sync.Mutex
+	lastSeen      map[string]time.Time
+	utterance     string
+	utteranceESN  string
+	utteranceTime time.Time
+	lastError     string
+	lastErrorTime time.Time
+}
+
+// set once StartChipper is called, so a chipper which stopped can be told apart from one which never started
+var chipperWanted bool
+
+func watchStatus(hub *logs.Hub) {
+	status.Lock()
+	status.lastSeen = map[string]time.Time{}
+	status.Unlock()
+	entries, _ := hub.Subscribe(logs.Filter{})
+	for e := range entries {
+		status.Lock()
+		if e.ESN != "" {
+			status.lastSeen[strings.ToLower(e.ESN)] = e.Time
+		}
+		if _, text, ok := strings.Cut(e.Message, "Transcribed text: "); ok {
+			status.utterance = text
+			status.utteranceESN = e.ESN
+			status.utteranceTime = e.Time
+		}
+		if e.Level >= logs.LevelError {
+			status.lastError = e.Message
+			status.lastErrorTime = e.Time
+		}
+		status.Unlock()
+	}
+}
+
+func robotLastSeen(esn string) time.Time {
+	status.Lock()
+	defer status.Unlock()
+	return status.lastSeen[strings.ToLower(esn)]
+}
+
+// podError is what's wrong right now, or "" if nothing is
+func podError() string {
+	if chipperWanted && !chipperServing {
+		return "chipper is not running"
+	}
+	status.Lock()
+	defer status.Unlock()
+	if status.lastError != "" && time.Since(status.lastErrorTime) < statusErrorWindow {
+		return status.lastError
+	}
+	return ""
+}
+
+func ago(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return t.Format("2006-01-02")
+}
+
+func shorten(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func serverModeText() string {
+	if vars.APIConfig.Server.EPConfig {
+		return "Mode: Escape Pod (" + pod.MDNS.Config().Hostname + ".local), port " + vars.APIConfig.Server.Port
+	}
+	return "Mode: IP (" + vars.GetOutboundIP().String() + "), port " + vars.APIConfig.Server.Port
+}
+
+func sttText() string {
+	// onReady and SwitchSTTEngine keep this set to the engine in use
+	stt := "Speech-to-text: " + os.Getenv("STT_SERVICE")
+	if vars.APIConfig.STT.Language != "" {
+		stt += " (" + vars.APIConfig.STT.Language + ")"
+	}
+	return stt
+}
+
+// the tooltip when nothing is wrong
+func normalTooltip() string {
+	name := activeProfile.Title("wire-pod")
+	if !vars.APIConfig.PastInitialSetup {
+		return name + " must be set up at http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort
+	}
+	return name + " is running.\n" + "http://" + vars.GetOutboundIP().String() + ":" + vars.WebPort
+}
+
+type statusMenu struct {
+	root      *systray.MenuItem
+	mode      *systray.MenuItem
+	stt       *systray.MenuItem
+	web       *systray.MenuItem
+	robots    *systray.MenuItem
+	slots     []*systray.MenuItem
+	utterance *systray.MenuItem
+	problem   *systray.MenuItem
+
+	normalIcon []byte
+	errorIcon  []byte
+	inError    bool
+}
+
+func addStatusMenu(normalIcon []byte) *statusMenu {
+	m := &statusMenu{normalIcon: normalIcon}
+	m.root = systray.AddMenuItem("Status", "What WirePod is doing right now")
+	add := func() *systray.MenuItem {
+		item := m.root.AddSubMenuItem("", "")
+		item.Disable()
+		return item
+	}
+	m.problem = add()
+	m.problem.Hide()
+	m.mode = add()
+	m.stt = add()
+	m.web = add()
+	m.robots = add()
+	for i := 0; i < statusRobotSlots; i++ {
+		slot := add()
+		slot.Hide()
+		m.slots = append(m.slots, slot)
+	}
+	m.utterance = add()
+	if icon, err := makeErrorIcon(); err == nil {
+		m.errorIcon = icon
+	} else {
+		logs.For("tray").Warn("Error making the error icon: " + err.Error())
+	}
+	return m
+}
+
+func (m *statusMenu) refresh() {
+	if !vars.APIConfig.PastInitialSetup {
+		m.root.SetTitle("Status: needs setup")
+	} else if chipperServing {
+		if pod.Pause.Paused() {
+			m.root.SetTitle("Status: paused")
+		} else {
+			m.root.SetTitle("Status: running")
+		}
+	} else {
+		m.root.SetTitle("Status: not running")
+	}
+	m.mode.SetTitle(serverModeText())
+	m.stt.SetTitle(sttText())
+	m.web.SetTitle("Web interface: port " + vars.WebPort)
+
+	robots := vars.BotInfo.Robots
+	m.robots.SetTitle(fmt.Sprintf("Robots: %d", len(robots)))
+	for i, slot := range m.slots {
+		if i >= len(robots) {
+			slot.Hide()
+			continue
+		}
+		if i == len(m.slots)-1 && len(robots) > len(m.slots) {
+			slot.SetTitle(fmt.Sprintf("    and %d more", len(robots)-i))
+		} else {
+			robot := robots[i]
+			slot.SetTitle("    " + robot.Esn + " (" + robot.IPAddress + "), last seen " + ago(robotLastSeen(robot.Esn)))
+		}
+		slot.Show()
+	}
+
+	status.Lock()
+	utterance, esn, when := status.utterance, status.utteranceESN, status.utteranceTime
+	status.Unlock()
+	if utterance == "" {
+		m.utterance.SetTitle("Last heard: nothing yet")
+	} else {
+		heard := "Last heard: \"" + shorten(utterance, 60) + "\" " + ago(when)
+		if esn != "" {
+			heard += " from " + esn
+		}
+		m.utterance.SetTitle(heard)
+	}
+
+	m.setError(podError())
+}
+
+// the icon and tooltip only change when the error state does, so they don't flicker every refresh
+func (m *statusMenu) setError(problem string) {
+	if problem == "" {
+		m.problem.Hide()
+		if m.inError {
+			m.inError = false
+			systray.SetIcon(m.normalIcon)
+			systray.SetTooltip(normalTooltip())
+		}
+		return
+	}
+	m.problem.SetTitle("Error: " + shorten(problem, 80))
+	m.problem.Show()
+	systray.SetTooltip(mBoxTitle + ": error - " + shorten(problem, 100))
+	if !m.inError {
+		m.inError = true
+		if m.errorIcon != nil {
+			systray.SetIcon(m.errorIcon)
+		}
+	}
+}
+
+func (m *statusMenu) run() {
+	for {
+		m.refresh()
+		time.Sleep(5 * time.Second)
+	}
+}
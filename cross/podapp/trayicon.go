@@ -0,0 +1,74 @@
+package podapp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// there is no error icon among the resources, so one is made from the normal one: the pod with a red dot
+// in the corner. it's wrapped in an .ico (which may hold a png) like the normal tray icon is.
+
+func makeErrorIcon() ([]byte, error) {
+	f, err := os.Open(filepath.Join(cross.ResourcesPath(), "icons/png/pod256x256.png"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	img := image.NewNRGBA(b)
+	draw.Draw(img, b, src, b.Min, draw.Src)
+
+	r := b.Dx() * 3 / 10
+	cx, cy := b.Max.X-r, b.Max.Y-r
+	red := color.NRGBA{R: 0xe0, G: 0x20, B: 0x20, A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	for y := cy - r; y < cy+r; y++ {
+		for x := cx - r; x < cx+r; x++ {
+			d := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+			switch {
+			case d <= (r-r/6)*(r-r/6):
+				img.Set(x, y, red)
+			case d <= r*r:
+				img.Set(x, y, white)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return pngToICO(buf.Bytes(), b.Dx(), b.Dy()), nil
+}
+
+// an .ico with one png image in it
+func pngToICO(data []byte, width, height int) []byte {
+	// 0 means 256 in the directory entry
+	size := func(n int) byte {
+		if n >= 256 {
+			return 0
+		}
+		return byte(n)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Reserved, Type, Count uint16
+	}{0, 1, 1})
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Width, Height, Colors, Reserved byte
+		Planes, BitCount                uint16
+		Size, Offset                    uint32
+	}{size(width), size(height), 0, 0, 1, 32, uint32(len(data)), 6 + 16})
+	buf.Write(data)
+	return buf.Bytes()
+}
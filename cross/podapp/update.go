@@ -0,0 +1,329 @@
+package podapp
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getlantern/systray"
+	all "github.com/kercre123/WirePod/cross/all"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/updater"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	"github.com/ncruces/zenity"
+)
+
+var updateLog = logs.For("update")
+
+// set at build time: -ldflags "-X github.com/kercre123/WirePod/cross/podapp.UpdatePublicKey=<base64 key>".
+// without a key (here or in update.json) the updater is off.
+var UpdatePublicKey string
+
+var DefaultUpdateURL = "https://github.com/kercre123/WirePod/releases/latest/download/manifest.json"
+
+var podUpdater *updater.Updater
+
+// how long a new version gets to confirm it's healthy, and how long the old instance gets to exit before it
+// starts. the confirm and wait loops go by it too, so they give up when the updater does.
+const updateHealthTimeout = 2 * time.Minute
+
+// <pod dir>/update.json, every field optional. WIREPOD_UPDATE_URL overrides the manifest URL.
+type updateConfig struct {
+	ManifestURL        string `json:"manifesturl"`
+	PublicKey          string `json:"publickey"`
+	CheckIntervalHours int    `json:"checkintervalhours"`
+}
+
+var updateConf updateConfig
+
+// set by the updater child process so it doesn't try to apply again
+const updateChildEnv = "WIREPOD_UPDATE_CHILD"
+
+func updatePodDir() string {
+	confDir, _ := os.UserConfigDir()
+	return filepath.Join(confDir, vars.PodName)
+}
+
+// what a release zip unpacks onto
+func updateRoot(conf all.WPConfig) string {
+	switch runtime.GOOS {
+	case "windows":
+		return conf.InstallPath
+	case "darwin":
+		// the .app bundle
+		execu, _ := os.Executable()
+		return filepath.Clean(filepath.Join(filepath.Dir(execu), "../.."))
+	}
+	return ""
+}
+
+func initUpdater(conf all.WPConfig) {
+	updateConf = updateConfig{ManifestURL: DefaultUpdateURL, PublicKey: UpdatePublicKey, CheckIntervalHours: 12}
+	if data, err := os.ReadFile(filepath.Join(updatePodDir(), "update.json")); err == nil {
+		json.Unmarshal(data, &updateConf)
+	}
+	if os.Getenv("WIREPOD_UPDATE_URL") != "" {
+		updateConf.ManifestURL = os.Getenv("WIREPOD_UPDATE_URL")
+	}
+	if updateConf.CheckIntervalHours < 1 {
+		updateConf.CheckIntervalHours = 12
+	}
+	root := updateRoot(conf)
+	if root == "" {
+		return
+	}
+	key, err := updater.ParsePublicKey(updateConf.PublicKey)
+	if err != nil {
+		fmt.Println("Updater disabled: " + err.Error())
+		return
+	}
+	podUpdater = &updater.Updater{
+		ManifestURL:    updateConf.ManifestURL,
+		PublicKey:      key,
+		CurrentVersion: conf.Version,
+		Root:           root,
+		StateDir:       filepath.Join(updatePodDir(), "update"),
+		HealthTimeout:  updateHealthTimeout,
+	}
+}
+
+// runs before this instance claims LastRunningPID. returns true if the update process took over and this one should exit.
+func applyPendingUpdate(conf all.WPConfig) bool {
+	if podUpdater == nil || os.Getenv(updateChildEnv) != "" {
+		return false
+	}
+	state := podUpdater.State()
+	if state.Applying != nil {
+		// an update was interrupted before it was confirmed
+		fmt.Println("Rolling back interrupted update to " + state.Applying.Version)
+		podUpdater.Rollback()
+		return false
+	}
+	if state.Staged == "" {
+		return false
+	}
+	execu, _ := os.Executable()
+	previous := conf.Version
+	fmt.Println("Applying update to " + state.Staged)
+	err := podUpdater.ApplyAndWatch(func() (*exec.Cmd, error) {
+		c, _ := cross.ReadConfig()
+		cmd := exec.Command(execu, "-d")
+		cmd.Env = os.Environ()
+		if s := podUpdater.State(); s.Applying != nil {
+			c.Version = s.Applying.Version
+			cmd.Env = append(cmd.Env, updateChildEnv+"=true")
+		} else {
+			c.Version = previous
+		}
+		cross.WriteConfig(c)
+		return cmd, cmd.Start()
+	})
+	if err != nil {
+		fmt.Println("Update failed: " + err.Error())
+		zenity.Warning("The WirePod update failed and the previous version was restored: "+err.Error(),
+			zenity.WarningIcon,
+			zenity.Title(mBoxTitle))
+	}
+	return true
+}
+
+// the new version counts as healthy once its web server answers and, if set up, chipper is serving
+func confirmUpdateHealth() {
+	if podUpdater == nil {
+		return
+	}
+	for deadline := time.Now().Add(podUpdater.HealthTimeout); time.Now().Before(deadline); {
+		time.Sleep(time.Second)
+		resp, err := http.Get("http://127.0.0.1:" + vars.WebPort + "/api-chipper/update_status")
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if vars.APIConfig.PastInitialSetup && !chipperServing {
+			continue
+		}
+		if err := podUpdater.MarkHealthy(); err != nil {
+			updateLog.Error("Error confirming update: " + err.Error())
+		}
+		return
+	}
+	updateLog.Warn("wire-pod did not become healthy, not confirming update")
+}
+
+// restarts wire-pod into the staged update. Program Files needs admin on Windows, so it asks for elevation there.
+func restartIntoUpdate() error {
+	execu, _ := os.Executable()
+	pid := strconv.Itoa(os.Getpid())
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		// single quoted powershell strings only escape ' by doubling it
+		exe := strings.ReplaceAll(execu, "'", "''")
+		cmd = exec.Command("powershell", "-Command", "Start-Process -FilePath '"+exe+"' -ArgumentList 'update-apply','"+pid+"' -Verb RunAs")
+	} else {
+		cmd = exec.Command(execu, "update-apply", pid)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	ExitProgram(0)
+	return nil
+}
+
+// for the update-apply argument: wait for the old instance to exit, then start up normally, which applies the update
+func waitForPID(pid int) {
+	for deadline := time.Now().Add(updateHealthTimeout); time.Now().Before(deadline); {
+		if running, _ := cross.IsPIDProcessRunning(pid); !running {
+			return
+		}
+		time.Sleep(time.Second / 2)
+	}
+}
+
+func checkAndStage(autoStage bool) (updater.State, error) {
+	m, a, available, err := podUpdater.Check()
+	if err != nil {
+		return podUpdater.State(), err
+	}
+	if available && autoStage && podUpdater.State().Staged != m.Version {
+		updateLog.Info("Downloading update " + m.Version)
+		err = podUpdater.Stage(m, a)
+	}
+	return podUpdater.State(), err
+}
+
+func updateMenuTitle(item *systray.MenuItem, state updater.State) {
+	switch {
+	case state.Staged != "":
+		item.SetTitle("Restart To Update (" + state.Staged + ")")
+	case state.Available != "":
+		item.SetTitle("Update Available: " + state.Available)
+	default:
+		item.SetTitle("Check For Updates")
+	}
+}
+
+// periodic check. with AutoUpdate the update is downloaded right away and applied on the next start.
+func updateLoop(item *systray.MenuItem) {
+	if podUpdater == nil {
+		item.Hide()
+		return
+	}
+	updateMenuTitle(item, podUpdater.State())
+	for {
+		conf, _ := cross.ReadConfig()
+		state, err := checkAndStage(conf.AutoUpdate)
+		if err != nil {
+			updateLog.Error("Update check failed: " + err.Error())
+		}
+		updateMenuTitle(item, state)
+		time.Sleep(time.Duration(updateConf.CheckIntervalHours) * time.Hour)
+	}
+}
+
+func onUpdateClicked(item *systray.MenuItem) {
+	if podUpdater == nil {
+		return
+	}
+	state := podUpdater.State()
+	if state.Staged != "" {
+		err := zenity.Question("WirePod will restart to install "+state.Staged+".",
+			zenity.Title(mBoxTitle),
+			zenity.OKLabel("Restart"),
+			zenity.CancelLabel("Later"))
+		if err == nil {
+			if err := restartIntoUpdate(); err != nil {
+				zenity.Error("Error restarting into the update: "+err.Error(), zenity.ErrorIcon, zenity.Title(mBoxTitle))
+			}
+		}
+		return
+	}
+	state, err := checkAndStage(true)
+	updateMenuTitle(item, state)
+	if err != nil {
+		zenity.Error("Error checking for updates: "+err.Error(), zenity.ErrorIcon, zenity.Title(mBoxTitle))
+	} else if state.Staged == "" {
+		zenity.Info("WirePod is up to date.", zenity.Icon(mBoxIcon()), zenity.Title(mBoxTitle))
+	} else {
+		zenity.Info(state.Staged+" has been downloaded and will be installed the next time WirePod starts.",
+			zenity.Icon(mBoxIcon()), zenity.Title(mBoxTitle))
+	}
+}
+
+//go:embed update.html
+var updatePage []byte
+
+// serveUpdatePage checks for, downloads and applies updates through the update_ endpoints
+func serveUpdatePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(updatePage)
+}
+
+func updateAPI(w http.ResponseWriter, r *http.Request) {
+	if podUpdater == nil && r.URL.Path != "/api-chipper/update_status" {
+		fmt.Fprint(w, "error: updater is not available on this build")
+		return
+	}
+	switch {
+	case r.URL.Path == "/api-chipper/update_status":
+		type status struct {
+			Enabled bool `json:"enabled"`
+			updater.State
+			CurrentVersion string `json:"currentversion"`
+			AutoUpdate     bool   `json:"autoupdate"`
+		}
+		var st status
+		conf, _ := cross.ReadConfig()
+		st.AutoUpdate = conf.AutoUpdate
+		if podUpdater != nil {
+			st.Enabled = true
+			st.State = podUpdater.State()
+			st.CurrentVersion = podUpdater.CurrentVersion
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+		return
+	case r.URL.Path == "/api-chipper/update_check", r.URL.Path == "/api-chipper/update_stage":
+		state, err := checkAndStage(r.URL.Path == "/api-chipper/update_stage")
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+		return
+	case r.URL.Path == "/api-chipper/update_apply":
+		if podUpdater.State().Staged == "" {
+			fmt.Fprint(w, "error: no update is staged")
+			return
+		}
+		fmt.Fprint(w, "done")
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		go func() {
+			time.Sleep(time.Second)
+			if err := restartIntoUpdate(); err != nil {
+				updateLog.Error("Error restarting into update: " + err.Error())
+			}
+		}()
+		return
+	case r.URL.Path == "/api-chipper/update_autoupdate":
+		conf, err := cross.ReadConfig()
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		conf.AutoUpdate = strings.EqualFold(r.FormValue("enabled"), "true")
+		cross.WriteConfig(conf)
+		fmt.Fprint(w, "done")
+		return
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/kercre123/wire-pod/chipper/pkg/logger"
 	"github.com/kercre123/wire-pod/chipper/pkg/vars"
@@ -52,5 +53,17 @@ func ChipperHTTPApi(w http.ResponseWriter, r *http.Request) {
 		RestartServer()
 		fmt.Fprint(w, "done")
 		return
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/update_"):
+		updateAPI(w, r)
+		return
+	case r.URL.Path == "/api-chipper/profile":
+		profileAPI(w, r)
+		return
+	case r.URL.Path == "/api-chipper/diagnostics":
+		diagnosticsAPI(w, r)
+		return
+	default:
+		// the features shared with the debian package
+		pod.ServeAPI(w, r)
 	}
 }
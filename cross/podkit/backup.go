@@ -0,0 +1,215 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/backup"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/WirePod/cross/webhook"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+var backupLog = logs.For("backup")
+
+// everything in backup.DefaultComponents plus the stt engine choice and the custom intents' webhooks
+func (p *Pod) backupComponents() []backup.Component {
+	return append(backup.DefaultComponents(), backup.Component{
+		Name:  "stt-engine",
+		Paths: []string{sttengine.ConfigPath(p.Dir)},
+	}, backup.Component{
+		Name:  "webhooks",
+		Paths: []string{webhook.ConfigPath(webhooksDir())},
+	})
+}
+
+// must be called after vars.Init
+func (p *Pod) initBackups() {
+	sched, err := backup.ReadSchedule(p.Dir)
+	if err != nil {
+		backupLog.Error("Error reading backup schedule: " + err.Error())
+	}
+	p.Backups.Start(sched)
+	p.HandlePage("/backup", backup.ServePage)
+}
+
+// RunBackupCLI handles "backup ..." without starting wire-pod
+func (p *Pod) RunBackupCLI(args []string) int {
+	vars.Packaged = true
+	vars.Init()
+	return backup.RunCLI(p.Dir, p.backupComponents(), args)
+}
+
+// loads restored files into memory. certs need a restart of the chipper server, a restored engine choice
+// or language a new voice processor.
+func (p *Pod) reloadAfterRestore(restored backup.Manifest) {
+	vars.ReadConfig()
+	if jsonBytes, err := os.ReadFile(vars.JdocsPath); err == nil {
+		json.Unmarshal(jsonBytes, &vars.BotJdocs)
+	}
+	if botBytes, err := os.ReadFile(vars.BotInfoPath); err == nil {
+		json.Unmarshal(botBytes, &vars.BotInfo)
+	}
+	vars.ReadSessionCerts()
+	vars.LoadCustomIntents()
+	if conf, err := webhook.ReadConfig(webhooksDir()); err == nil {
+		p.Webhooks.Set(conf)
+	}
+	vars.ChipperKeysLoaded = false
+	for _, c := range restored.Components {
+		if c.Name != "stt-engine" && c.Name != "api-config" {
+			continue
+		}
+		// restarts the server with the new processor
+		err := p.SwitchSTTEngine(sttengine.ReadConfig(sttengine.ConfigPath(p.Dir)))
+		if err == nil {
+			return
+		}
+		backupLog.Error("Error starting the restored STT engine, keeping " + p.engine.Name + ": " + err.Error())
+		vars.APIConfig.STT.Service = p.engine.Name
+		break
+	}
+	p.Restart()
+}
+
+func (p *Pod) backupAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/backup_export":
+		var names []string
+		if r.FormValue("components") != "" {
+			names = strings.Split(r.FormValue("components"), ",")
+		}
+		components, err := backup.Filter(p.backupComponents(), names)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename=wirepod-export-"+time.Now().Format("20060102-150405")+backup.BundleExt)
+		_, err = backup.Export(w, components, r.FormValue("passphrase"))
+		if err != nil {
+			backupLog.Error("Backup export failed: " + err.Error())
+		}
+		return
+	case r.URL.Path == "/api-chipper/backup_import", r.URL.Path == "/api-chipper/backup_inspect":
+		file, _, err := r.FormFile("bundle")
+		if err != nil {
+			fmt.Fprint(w, "error: must upload a bundle")
+			return
+		}
+		defer file.Close()
+		bundle, err := io.ReadAll(file)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		var manifest backup.Manifest
+		if r.URL.Path == "/api-chipper/backup_inspect" {
+			manifest, err = backup.ReadManifest(bundle, r.FormValue("passphrase"))
+		} else {
+			var names []string
+			if r.FormValue("components") != "" {
+				names = strings.Split(r.FormValue("components"), ",")
+			}
+			var components []backup.Component
+			components, err = backup.Filter(p.backupComponents(), names)
+			if err == nil {
+				manifest, err = backup.Import(bundle, components, r.FormValue("passphrase"))
+			}
+			if err == nil {
+				backupLog.Info("Restored backup from " + manifest.CreatedAt.String())
+				p.reloadAfterRestore(manifest)
+			}
+		}
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(manifest)
+		return
+	case r.URL.Path == "/api-chipper/backup_components":
+		var names []string
+		for _, c := range p.backupComponents() {
+			names = append(names, c.Name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(names)
+		return
+	case r.URL.Path == "/api-chipper/backup_list":
+		sched, _ := backup.ReadSchedule(p.Dir)
+		files, err := backup.List(sched.Dir)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(files)
+		return
+	case r.URL.Path == "/api-chipper/backup_download":
+		sched, _ := backup.ReadSchedule(p.Dir)
+		name := filepath.Base(r.FormValue("name"))
+		if !strings.HasSuffix(name, backup.BundleExt) {
+			fmt.Fprint(w, "error: invalid backup name")
+			return
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename="+name)
+		http.ServeFile(w, r, filepath.Join(sched.Dir, name))
+		return
+	case r.URL.Path == "/api-chipper/backup_run":
+		sched, _ := backup.ReadSchedule(p.Dir)
+		file, err := backup.RunOnce(sched, p.backupComponents())
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(file)
+		return
+	case r.URL.Path == "/api-chipper/get_backup_schedule":
+		sched, err := backup.ReadSchedule(p.Dir)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		// never send the passphrase back out
+		hasPassphrase := sched.Passphrase != ""
+		sched.Passphrase = ""
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			backup.Schedule
+			HasPassphrase bool `json:"haspassphrase"`
+		}{sched, hasPassphrase})
+		return
+	case r.URL.Path == "/api-chipper/set_backup_schedule":
+		sched, _ := backup.ReadSchedule(p.Dir)
+		sched.Enabled = r.FormValue("enabled") == "true"
+		if v := r.FormValue("intervalhours"); v != "" {
+			sched.IntervalHours, _ = strconv.Atoi(v)
+		}
+		if v := r.FormValue("keep"); v != "" {
+			sched.Keep, _ = strconv.Atoi(v)
+		}
+		if v := r.FormValue("dir"); v != "" {
+			sched.Dir = v
+		}
+		if _, ok := r.Form["passphrase"]; ok {
+			sched.Passphrase = r.FormValue("passphrase")
+		}
+		if err := backup.WriteSchedule(p.Dir, sched); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.Backups.Start(sched)
+		fmt.Fprint(w, "done")
+		return
+	}
+}
@@ -0,0 +1,82 @@
+package podkit
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kercre123/WirePod/cross/backup"
+	"github.com/kercre123/WirePod/cross/podtest"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+func importBundle(t *testing.T, p *Pod, bundle []byte, components string) string {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("bundle", "test"+backup.BundleExt)
+	fw.Write(bundle)
+	mw.WriteField("components", components)
+	mw.Close()
+	req := httptest.NewRequest("POST", "/api-chipper/backup_import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	p.backupAPI(w, req)
+	return w.Body.String()
+}
+
+// a restored engine choice is started, not just written
+func TestRestoreSTTEngine(t *testing.T) {
+	p, _ := testSTTPod(t)
+	p.Restart = func() {}
+	vars.WriteConfigToDisk()
+	restored := &podtest.StubSTT{Default: "restored engine"}
+	sttengine.Register(sttengine.Engine{Name: "test-restored", Init: restored.Init, STT: restored.STT})
+
+	if err := sttengine.WriteConfig(p.sttConfPath, sttengine.Config{Engine: "test-restored"}); err != nil {
+		t.Fatal(err)
+	}
+	components, _ := backup.Filter(p.backupComponents(), []string{"stt-engine"})
+	var bundle bytes.Buffer
+	if _, err := backup.Export(&bundle, components, ""); err != nil {
+		t.Fatal(err)
+	}
+	sttengine.WriteConfig(p.sttConfPath, sttengine.Config{Engine: "vosk"})
+
+	if resp := importBundle(t, p, bundle.Bytes(), "stt-engine"); strings.HasPrefix(resp, "error") {
+		t.Fatal(resp)
+	}
+	if p.engine.Name != "test-restored" || vars.APIConfig.STT.Service != "test-restored" {
+		t.Errorf("running %s (config says %s) after the restore, want test-restored", p.engine.Name, vars.APIConfig.STT.Service)
+	}
+}
+
+// if the restored engine doesn't start, the old one keeps running and the server is still restarted for
+// the other restored files
+func TestRestoreSTTEngineFails(t *testing.T) {
+	p, _ := testSTTPod(t)
+	restarts := 0
+	p.Restart = func() { restarts++ }
+	vars.WriteConfigToDisk()
+	sttengine.Register(sttengine.Engine{Name: "test-restore-broken", Init: func() error { return errTestInit }, STT: (&podtest.StubSTT{}).STT})
+
+	sttengine.WriteConfig(p.sttConfPath, sttengine.Config{Engine: "test-restore-broken"})
+	components, _ := backup.Filter(p.backupComponents(), []string{"stt-engine"})
+	var bundle bytes.Buffer
+	if _, err := backup.Export(&bundle, components, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := importBundle(t, p, bundle.Bytes(), "stt-engine"); strings.HasPrefix(resp, "error") {
+		t.Fatal(resp)
+	}
+	if p.engine.Name != "vosk" || vars.APIConfig.STT.Service != "vosk" {
+		t.Errorf("running %s (config says %s), want the old engine", p.engine.Name, vars.APIConfig.STT.Service)
+	}
+	if restarts != 1 {
+		t.Errorf("restarted %d times, want 1", restarts)
+	}
+}
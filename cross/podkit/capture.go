@@ -0,0 +1,103 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/capture"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/logs"
+)
+
+func (p *Pod) captureConfig() capture.Config {
+	p.captureConf.Lock()
+	defer p.captureConf.Unlock()
+	return p.captureConf.conf
+}
+
+func (p *Pod) captureDir() string {
+	return filepath.Join(p.Dir, capture.DirName)
+}
+
+// must be called with initHistory, the recordings are saved with history entries
+func (p *Pod) initCapture() {
+	conf, err := capture.ReadConfig(p.Dir)
+	if err != nil {
+		logs.For("capture").Warn("Error reading audio capture config, using defaults: " + err.Error())
+	}
+	p.captureConf.conf = conf
+	p.History.CaptureAudio = func(esn string) bool { return p.captureConfig().Wants(esn) }
+	p.History.SaveAudio = p.saveAudio
+	go p.pruneCaptures()
+}
+
+func (p *Pod) saveAudio(esn string, t time.Time, audio []byte) (string, error) {
+	conf := p.captureConfig()
+	var pcm, ogg []byte
+	if history.IsOpus(audio) {
+		ogg = audio
+	}
+	if conf.Format == capture.FormatWAV || ogg == nil {
+		var err error
+		if pcm, err = history.PCM(audio); err != nil {
+			return "", err
+		}
+	}
+	name, err := capture.Save(p.captureDir(), esn, t, conf.Format, pcm, ogg)
+	if err == nil {
+		// keep the cap as recordings come in, not just once an hour
+		go capture.Prune(p.captureDir(), conf)
+	}
+	return name, err
+}
+
+func (p *Pod) pruneCaptures() {
+	for {
+		if n, err := capture.Prune(p.captureDir(), p.captureConfig()); err != nil {
+			logs.For("capture").Warn("Error deleting old audio captures: " + err.Error())
+		} else if n > 0 {
+			logs.For("capture").Info("Deleted " + strconv.Itoa(n) + " old audio captures")
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+func (p *Pod) captureAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/history_audio":
+		path, err := capture.Path(p.captureDir(), r.FormValue("file"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("download") == "true" {
+			w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(path))
+		}
+		// ServeFile answers 404 for recordings which have expired
+		http.ServeFile(w, r, path)
+	case r.URL.Path == "/api-chipper/get_capture_config":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.captureConfig())
+	case r.URL.Path == "/api-chipper/set_capture_config":
+		conf := p.captureConfig()
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := capture.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.captureConf.Lock()
+		p.captureConf.conf = conf
+		p.captureConf.Unlock()
+		go capture.Prune(p.captureDir(), conf)
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,138 @@
+package podkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fforchino/vector-go-sdk/pkg/vector"
+	"github.com/fforchino/vector-go-sdk/pkg/vectorpb"
+	"github.com/kercre123/WirePod/cross/discovery"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// the GUID robots accept once they have been set up with a wire-pod, see jdocs' StoreBotInfo
+const podGUID = "tni1TRsTRTaNSapjo0Y+Sw=="
+
+// must be called after vars.Init
+func (p *Pod) initDiscovery() {
+	p.Discovery.Match = matchRobot
+	p.Discovery.OnFound = p.updateRobotIP
+	p.HandlePage("/discovery", discovery.ServePage)
+	if os.Getenv("DISABLE_DISCOVERY") == "true" {
+		fmt.Println("Robot discovery is disabled")
+		return
+	}
+	go p.Discovery.Run()
+}
+
+// robots which have connected to the pod have a session cert, which maps their name to their ESN
+func matchRobot(name string) (string, bool) {
+	var esn string
+	for _, info := range vars.RecurringInfo {
+		if strings.EqualFold(info.ID, name) {
+			esn = info.ESN
+			break
+		}
+	}
+	if esn == "" {
+		return "", false
+	}
+	for _, robot := range vars.BotInfo.Robots {
+		if strings.EqualFold(robot.Esn, esn) {
+			return esn, true
+		}
+	}
+	return esn, false
+}
+
+// keeps the SDK info file's address of a known robot up to date, so PingAllBots and the SDK app reach it
+func (p *Pod) updateRobotIP(robot discovery.Robot) {
+	if !robot.Authenticated {
+		return
+	}
+	p.botInfoMu.Lock()
+	defer p.botInfoMu.Unlock()
+	for i, bot := range vars.BotInfo.Robots {
+		if strings.EqualFold(bot.Esn, robot.ESN) && bot.IPAddress != robot.IP {
+			logs.For("discovery").Robot(robot.ESN).Info("Updating " + robot.Name + "'s address from " + bot.IPAddress + " to " + robot.IP)
+			vars.BotInfo.Robots[i].IPAddress = robot.IP
+			vars.AddToRInfo(robot.ESN, robot.Name, robot.IP)
+			writeBotInfo()
+			return
+		}
+	}
+}
+
+func writeBotInfo() {
+	data, _ := json.Marshal(vars.BotInfo)
+	if err := os.WriteFile(vars.BotInfoPath, data, 0644); err != nil {
+		logs.For("discovery").Error("Error writing " + vars.BotInfoPath + ": " + err.Error())
+	}
+}
+
+// addRobot puts a discovered robot in the SDK info file. this only works for robots which
+// have been set up with a wire-pod before, others have to go through setup.
+func (p *Pod) addRobot(name string) error {
+	robot, ok := p.Discovery.Get(name)
+	if !ok {
+		return fmt.Errorf("%s hasn't been found on the network", name)
+	}
+	esn, authenticated := matchRobot(name)
+	if authenticated {
+		return nil
+	}
+	if esn == "" {
+		var err error
+		if esn, err = discovery.ProbeESN(robot.IP); err != nil {
+			return fmt.Errorf("couldn't get %s's ESN: %s", name, err)
+		}
+	}
+	guid := vars.BotInfo.GlobalGUID
+	if guid == "" {
+		guid = podGUID
+	}
+	bot, err := vector.New(vector.WithTarget(robot.IP+":443"), vector.WithSerialNo(esn), vector.WithToken(guid))
+	if err != nil {
+		return err
+	}
+	if _, err := bot.Conn.BatteryState(context.Background(), &vectorpb.BatteryStateRequest{}); err != nil {
+		return fmt.Errorf("%s didn't accept the pod, set it up first (%s)", name, err)
+	}
+	p.botInfoMu.Lock()
+	defer p.botInfoMu.Unlock()
+	vars.BotInfo.GlobalGUID = guid
+	vars.BotInfo.Robots = append(vars.BotInfo.Robots, struct {
+		Esn       string `json:"esn"`
+		IPAddress string `json:"ip_address"`
+		GUID      string `json:"guid"`
+		Activated bool   `json:"activated"`
+	}{Esn: esn, IPAddress: robot.IP, Activated: true})
+	vars.AddToRInfo(esn, name, robot.IP)
+	writeBotInfo()
+	logs.For("discovery").Robot(esn).Info("Added " + name + " from the network")
+	return nil
+}
+
+func (p *Pod) discoveryAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/discovery_robots":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Discovery.Robots())
+	case r.URL.Path == "/api-chipper/discovery_scan":
+		p.Discovery.Now()
+		fmt.Fprint(w, "done")
+	case r.URL.Path == "/api-chipper/discovery_add":
+		if err := p.addRobot(r.FormValue("name")); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
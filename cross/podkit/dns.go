@@ -0,0 +1,75 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kercre123/WirePod/cross/dnsserver"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// must be called after initMDNS, the server answers for the mDNS hostname too
+func (p *Pod) initDNS() {
+	conf, err := dnsserver.ReadConfig(p.Dir)
+	if err != nil {
+		logs.For("dns").Warn("Error reading DNS config, using defaults: " + err.Error())
+	}
+	p.DNS.SetConfig(conf)
+	p.DNS.IP = func() string { return vars.GetOutboundIP().String() }
+	p.DNS.Names = func() []string {
+		names := []string{"escapepod.local"}
+		if host := p.MDNS.Config().Hostname + ".local"; host != names[0] {
+			names = append(names, host)
+		}
+		return names
+	}
+	p.HandlePage("/dns", dnsserver.ServeInstructions)
+	p.restartDNS()
+}
+
+// stops the server and starts it again if it's enabled, e.g. after the port changed
+func (p *Pod) restartDNS() {
+	p.dnsMu.Lock()
+	defer p.dnsMu.Unlock()
+	p.DNS.Shutdown()
+	conf := p.DNS.Config()
+	if !conf.Enabled || os.Getenv("DISABLE_DNS") == "true" {
+		return
+	}
+	if err := p.DNS.Start(":" + conf.Port); err != nil {
+		fmt.Println("Unable to start DNS server on port " + conf.Port + ": " + err.Error())
+	}
+}
+
+func (p *Pod) dnsAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/dns_status":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.DNS.Status())
+	case r.URL.Path == "/api-chipper/get_dns_config":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.DNS.Config())
+	case r.URL.Path == "/api-chipper/set_dns_config":
+		conf := p.DNS.Config()
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := dnsserver.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.DNS.SetConfig(conf)
+		p.restartDNS()
+		if conf.Enabled && !p.DNS.Status().Running {
+			fmt.Fprint(w, "error: saved, but the server couldn't start: "+p.DNS.Status().LastError)
+			return
+		}
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,132 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/logs"
+)
+
+func (p *Pod) historyConfig() history.Config {
+	p.historyConf.Lock()
+	defer p.historyConf.Unlock()
+	return p.historyConf.conf
+}
+
+// must be called before wp.New, which gets the wrapped STT handler
+func (p *Pod) initHistory(engine string) {
+	conf, err := history.ReadConfig(p.Dir)
+	if err != nil {
+		logs.For("history").Warn("Error reading history config, using defaults: " + err.Error())
+	}
+	p.historyConf.conf = conf
+	p.History.Engine = engine
+	p.History.Enabled = func() bool { return p.historyConfig().Enabled }
+	store, err := history.Open(filepath.Join(p.Dir, history.DBName))
+	if err != nil {
+		logs.For("history").Error("Error opening voice history, requests won't be recorded: " + err.Error())
+	} else {
+		p.History.Store = store
+		go p.pruneHistory()
+	}
+	p.HandlePage("/history", history.ServePage)
+}
+
+func (p *Pod) pruneHistory() {
+	for {
+		if n, err := p.History.Store.Prune(p.historyConfig()); err != nil {
+			logs.For("history").Warn("Error pruning voice history: " + err.Error())
+		} else if n > 0 {
+			logs.For("history").Info("Deleted " + strconv.FormatInt(n, 10) + " old requests from the voice history")
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// dates are YYYY-MM-DD in local time, until includes the whole day
+func historyQuery(r *http.Request) history.Query {
+	q := history.Query{
+		Text:   r.FormValue("q"),
+		ESN:    r.FormValue("esn"),
+		Intent: r.FormValue("intent"),
+	}
+	if t, err := time.ParseInLocation("2006-01-02", r.FormValue("since"), time.Local); err == nil {
+		q.Since = t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", r.FormValue("until"), time.Local); err == nil {
+		q.Until = t.AddDate(0, 0, 1)
+	}
+	q.Limit, _ = strconv.Atoi(r.FormValue("limit"))
+	q.Offset, _ = strconv.Atoi(r.FormValue("offset"))
+	return q
+}
+
+func (p *Pod) historyAPI(w http.ResponseWriter, r *http.Request) {
+	if p.History.Store == nil && r.URL.Path != "/api-chipper/get_history_config" && r.URL.Path != "/api-chipper/set_history_config" {
+		fmt.Fprint(w, "error: the voice history database couldn't be opened, see the logs")
+		return
+	}
+	switch {
+	case r.URL.Path == "/api-chipper/history_search":
+		q := historyQuery(r)
+		records, err := p.History.Store.Search(q)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		total, _ := p.History.Store.Count(q)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"total": total, "records": records})
+	case r.URL.Path == "/api-chipper/history_export":
+		q := historyQuery(r)
+		q.Limit, _ = p.History.Store.Count(q)
+		records, err := p.History.Store.Search(q)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		name := "wire-pod-history-" + time.Now().Format("2006-01-02")
+		if r.FormValue("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", "attachment; filename="+name+".csv")
+			history.WriteCSV(w, records)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename="+name+".json")
+		history.WriteJSON(w, records)
+	case r.URL.Path == "/api-chipper/history_clear":
+		if err := p.History.Store.Clear(); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		fmt.Fprint(w, "done")
+	case r.URL.Path == "/api-chipper/get_history_config":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.historyConfig())
+	case r.URL.Path == "/api-chipper/set_history_config":
+		conf := p.historyConfig()
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := history.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.historyConf.Lock()
+		p.historyConf.conf = conf
+		p.historyConf.Unlock()
+		if p.History.Store != nil {
+			go p.History.Store.Prune(conf)
+		}
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
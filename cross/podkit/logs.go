@@ -0,0 +1,70 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/wire-pod/chipper/pkg/logger"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// InitLogs must be called after logger.Init, which makes the channel chipper's lines are captured from
+func (p *Pod) InitLogs() *logs.Hub {
+	conf, err := logs.ReadConfig(p.Dir)
+	hub := logs.NewHub(logs.LogDir(p.Dir), conf)
+	logs.Init(hub)
+	if err != nil {
+		logs.For("logs").Warn("Error reading log config, using defaults: " + err.Error())
+	}
+	go logs.CaptureChipper(hub, logger.GetLogTrayChan(), knownESNs)
+	p.HandlePage("/logs", logs.ServeViewer)
+	return hub
+}
+
+func knownESNs() []string {
+	var esns []string
+	for _, robot := range vars.BotInfo.Robots {
+		esns = append(esns, robot.Esn)
+	}
+	return esns
+}
+
+func (p *Pod) logsAPI(w http.ResponseWriter, r *http.Request) {
+	hub := logs.Default()
+	if hub == nil {
+		fmt.Fprint(w, "error: logging isn't initialized")
+		return
+	}
+	switch {
+	case r.URL.Path == "/api-chipper/logs_stream":
+		hub.ServeStream(w, r)
+	case r.URL.Path == "/api-chipper/logs_recent":
+		hub.ServeRecent(w, r)
+	case r.URL.Path == "/api-chipper/logs_download":
+		if hub.File() == nil {
+			fmt.Fprint(w, "error: no log file")
+			return
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename=wire-pod.log")
+		http.ServeFile(w, r, hub.File().Path())
+	case r.URL.Path == "/api-chipper/get_log_config":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hub.Config())
+	case r.URL.Path == "/api-chipper/set_log_config":
+		conf := hub.Config()
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := logs.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		hub.SetConfig(conf)
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
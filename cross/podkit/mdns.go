@@ -0,0 +1,60 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/mdns"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// must be called after vars.Init
+func (p *Pod) initMDNS() {
+	conf, err := mdns.ReadConfig(p.Dir)
+	if err != nil {
+		logs.For("mdns").Warn("Error reading mDNS config, using defaults: " + err.Error())
+	}
+	p.MDNS.SetConfig(conf)
+	p.MDNS.IP = func() string { return vars.GetOutboundIP().String() }
+	p.MDNS.WebPort = func() string { return vars.WebPort }
+}
+
+// StartMDNS is called by StartChipper on every (re)start, the announcer only starts once
+func (p *Pod) StartMDNS() {
+	if os.Getenv("DISABLE_MDNS") == "true" {
+		fmt.Println("mDNS is disabled")
+		return
+	}
+	p.mdnsOnce.Do(func() {
+		go p.MDNS.WatchForVectors()
+		go p.MDNS.Run()
+	})
+}
+
+func (p *Pod) mdnsAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/mdns_status":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.MDNS.Status())
+	case r.URL.Path == "/api-chipper/get_mdns_config":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.MDNS.Config())
+	case r.URL.Path == "/api-chipper/set_mdns_config":
+		conf := p.MDNS.Config()
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := mdns.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.MDNS.SetConfig(conf)
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,132 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/voskmodels"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+var modelsLog = logs.For("models")
+
+// must be called after vars.Init, which sets VoskModelPath
+func (p *Pod) initModelManager() {
+	catalogURL := voskmodels.DefaultCatalogURL
+	if os.Getenv("VOSK_CATALOG_URL") != "" {
+		catalogURL = os.Getenv("VOSK_CATALOG_URL")
+	}
+	offlineDir := filepath.Join(filepath.Dir(vars.ApiConfigPath), "vosk", "archives")
+	if os.Getenv("VOSK_ARCHIVE_DIR") != "" {
+		offlineDir = os.Getenv("VOSK_ARCHIVE_DIR")
+	}
+	p.VoskModels = &voskmodels.Manager{
+		ModelDir:   vars.VoskModelPath,
+		CatalogURL: catalogURL,
+		OfflineDir: offlineDir,
+		InUse: func() string {
+			if vars.APIConfig.STT.Service != "vosk" {
+				return ""
+			}
+			return vars.APIConfig.STT.Language
+		},
+	}
+	if vars.APIConfig.STT.Service == "vosk" && vars.APIConfig.PastInitialSetup {
+		err := p.VoskModels.Ensure(vars.APIConfig.STT.Language)
+		if err != nil {
+			modelsLog.Error("Unable to install a Vosk model for " + vars.APIConfig.STT.Language + ": " + err.Error())
+		}
+		p.refreshDownloadedModels()
+	}
+}
+
+// keeps config-ws's idea of which languages are downloaded in sync with the manager
+func (p *Pod) refreshDownloadedModels() {
+	models, _ := p.VoskModels.List()
+	vars.DownloadedVoskModels = []string{}
+	for _, model := range models {
+		vars.DownloadedVoskModels = append(vars.DownloadedVoskModels, model.Language)
+	}
+}
+
+func (p *Pod) modelsAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/get_vosk_models":
+		usage, err := p.VoskModels.Usage()
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(usage)
+	case r.URL.Path == "/api-chipper/install_vosk_model":
+		model, err := p.installVoskModel(r)
+		if err != nil {
+			modelsLog.Error(err)
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.refreshDownloadedModels()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model)
+	case r.URL.Path == "/api-chipper/delete_vosk_model":
+		err := p.VoskModels.Delete(r.FormValue("language"))
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.refreshDownloadedModels()
+		fmt.Fprint(w, "done")
+	case r.URL.Path == "/api-chipper/delete_unused_vosk_models":
+		freed, err := p.VoskModels.DeleteUnused()
+		p.refreshDownloadedModels()
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		fmt.Fprint(w, "freed "+fmt.Sprint(freed)+" bytes")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// either a multipart "archive" upload with a "language" (and optional "sha256"),
+// or just a "language" which is looked up in the catalog. "catalog" overrides the catalog location.
+func (p *Pod) installVoskModel(r *http.Request) (voskmodels.Model, error) {
+	language := r.FormValue("language")
+	if language == "" {
+		return voskmodels.Model{}, fmt.Errorf("must have language")
+	}
+	file, _, err := r.FormFile("archive")
+	if err == nil {
+		defer file.Close()
+		tmp, err := os.CreateTemp("", "vosk-upload-*.zip")
+		if err != nil {
+			return voskmodels.Model{}, err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = io.Copy(tmp, file)
+		tmp.Close()
+		if err != nil {
+			return voskmodels.Model{}, err
+		}
+		return p.VoskModels.InstallArchive(tmp.Name(), language, r.FormValue("sha256"))
+	}
+	if catalog := r.FormValue("catalog"); catalog != "" {
+		cat, err := voskmodels.LoadCatalog(catalog)
+		if err != nil {
+			return voskmodels.Model{}, err
+		}
+		entry, ok := cat.Find(language)
+		if !ok {
+			return voskmodels.Model{}, fmt.Errorf("catalog has no model for %s", language)
+		}
+		return p.VoskModels.InstallEntry(entry)
+	}
+	return p.VoskModels.Install(language)
+}
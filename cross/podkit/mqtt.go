@@ -0,0 +1,57 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/mqttbridge"
+)
+
+// publishes requests and robot events to an MQTT broker and takes commands from it, when it's set up.
+// must be called after initHistory, requests are published as they are recorded.
+func (p *Pod) initMQTT() {
+	conf, err := mqttbridge.ReadConfig(p.Dir)
+	if err != nil {
+		logs.For("mqtt").Warn("Error reading MQTT config, the bridge is off: " + err.Error())
+	}
+	p.History.Notify = p.MQTT.PublishRecord
+	go p.MQTT.WatchLogs(logs.Default())
+	p.MQTT.Start(conf)
+}
+
+// RunMQTTCLI is "mqtt", which checks the bridge against the configured broker
+func (p *Pod) RunMQTTCLI(args []string) int {
+	return mqttbridge.RunCLI(p.Dir, args)
+}
+
+func (p *Pod) mqttAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/mqtt_status":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.MQTT.Status())
+	case r.URL.Path == "/api-chipper/get_mqtt_config":
+		conf, err := mqttbridge.ReadConfig(p.Dir)
+		if err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conf)
+	case r.URL.Path == "/api-chipper/set_mqtt_config":
+		conf, _ := mqttbridge.ReadConfig(p.Dir)
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := mqttbridge.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		go p.MQTT.Start(conf)
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
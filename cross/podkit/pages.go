@@ -0,0 +1,33 @@
+package podkit
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// the pages write their embedded html in one go, so the first write has the whole <title>
+type titleWriter struct {
+	http.ResponseWriter
+	suffix  string
+	written bool
+}
+
+func (t *titleWriter) Write(b []byte) (int, error) {
+	if t.written {
+		return t.ResponseWriter.Write(b)
+	}
+	t.written = true
+	_, err := t.ResponseWriter.Write(bytes.Replace(b, []byte("</title>"), []byte(t.suffix+"</title>"), 1))
+	return len(b), err
+}
+
+// HandlePage registers one of the embedded pages, with the profile's name in its title
+func (p *Pod) HandlePage(pattern string, serve http.HandlerFunc) {
+	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if p.Profile == "" {
+			serve(w, r)
+			return
+		}
+		serve(&titleWriter{ResponseWriter: w, suffix: " (" + p.Profile + ")"}, r)
+	})
+}
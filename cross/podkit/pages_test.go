@@ -0,0 +1,30 @@
+package podkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlePageTitle(t *testing.T) {
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><head><title>wire-pod logs</title></head></html>"))
+	}
+	tests := []struct {
+		pattern string
+		profile string
+		want    string
+	}{
+		{"/test-default", "", "<html><head><title>wire-pod logs</title></head></html>"},
+		{"/test-profile", "test", "<html><head><title>wire-pod logs (test)</title></head></html>"},
+	}
+	for _, tt := range tests {
+		p := &Pod{Profile: tt.profile}
+		p.HandlePage(tt.pattern, serve)
+		w := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(w, httptest.NewRequest("GET", tt.pattern, nil))
+		if w.Body.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.pattern, w.Body.String(), tt.want)
+		}
+	}
+}
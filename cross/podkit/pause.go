@@ -0,0 +1,53 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/voicepause"
+)
+
+func (p *Pod) initVoicePause() {
+	conf, err := voicepause.ReadConfig(p.Dir)
+	if err != nil {
+		logs.For("voice").Warn("Error reading voice pause config, using defaults: " + err.Error())
+	}
+	p.Pause.SetConfig(conf)
+	go p.Pause.Watch()
+}
+
+func (p *Pod) pauseAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/pause_status":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Pause.Status())
+	case r.URL.Path == "/api-chipper/pause_set":
+		paused, err := strconv.ParseBool(r.FormValue("paused"))
+		if err != nil {
+			fmt.Fprint(w, "error: paused must be true or false")
+			return
+		}
+		p.Pause.Set(paused)
+		fmt.Fprint(w, "done")
+	case r.URL.Path == "/api-chipper/get_pause_config":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Pause.Config())
+	case r.URL.Path == "/api-chipper/set_pause_config":
+		conf := p.Pause.Config()
+		if err := json.NewDecoder(r.Body).Decode(&conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if err := voicepause.WriteConfig(p.Dir, conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.Pause.SetConfig(conf)
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
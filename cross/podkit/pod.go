@@ -0,0 +1,142 @@
+package podkit
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/kercre123/WirePod/cross/backup"
+	"github.com/kercre123/WirePod/cross/capture"
+	"github.com/kercre123/WirePod/cross/discovery"
+	"github.com/kercre123/WirePod/cross/dnsserver"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/mdns"
+	"github.com/kercre123/WirePod/cross/mqttbridge"
+	"github.com/kercre123/WirePod/cross/slots"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/WirePod/cross/voicepause"
+	"github.com/kercre123/WirePod/cross/voskmodels"
+	"github.com/kercre123/WirePod/cross/webhook"
+	wp "github.com/kercre123/wire-pod/chipper/pkg/wirepod/preqs"
+)
+
+// what the desktop app and the debian package share: the features which keep their config in the pod's
+// directory, their part of /api-chipper/ and their commands. each tree makes one Pod with its directory
+// and the hooks into its chipper server.
+
+type Pod struct {
+	// where the pod's configs and data go
+	Dir string
+	// the running profile's name, added to the page titles. empty for the default profile.
+	Profile string
+
+	// RestartServer, after a restore or an STT engine switch
+	Restart func()
+	// StartChipper, which blocks while serving, and what stops it. for selftest.
+	Start func()
+	Stop  func()
+	// readies chipper for use without the server (working directory, config, intents), for replay and
+	// intent-test
+	InitOffline func() error
+
+	// what chipper serves with, see BuildVoiceProcessor
+	VoiceProcessor *wp.Server
+
+	History    *history.Recorder
+	Slots      *slots.Slots
+	Webhooks   *webhook.Hooks
+	MQTT       *mqttbridge.Bridge
+	Pause      *voicepause.Controller
+	MDNS       *mdns.Announcer
+	DNS        *dnsserver.Server
+	Discovery  *discovery.Browser
+	Backups    *backup.Scheduler
+	VoskModels *voskmodels.Manager
+
+	historyConf struct {
+		sync.Mutex
+		conf history.Config
+	}
+	captureConf struct {
+		sync.Mutex
+		conf capture.Config
+	}
+	sttConfPath string
+	// what VoiceProcessor was built with
+	engine   sttengine.Engine
+	dnsMu    sync.Mutex
+	mdnsOnce sync.Once
+	// guards writes to vars.BotInfo from discovery
+	botInfoMu sync.Mutex
+}
+
+func New(dir string) *Pod {
+	p := &Pod{
+		Dir:       dir,
+		History:   &history.Recorder{},
+		Slots:     &slots.Slots{},
+		Webhooks:  &webhook.Hooks{},
+		MQTT:      &mqttbridge.Bridge{},
+		Pause:     voicepause.NewController(voicepause.DefaultConfig()),
+		MDNS:      mdns.NewAnnouncer(mdns.DefaultConfig()),
+		DNS:       dnsserver.NewServer(dnsserver.DefaultConfig()),
+		Discovery: discovery.NewBrowser(),
+	}
+	p.Backups = &backup.Scheduler{Components: p.backupComponents}
+	p.historyConf.conf = history.DefaultConfig()
+	p.captureConf.conf = capture.DefaultConfig()
+	return p
+}
+
+// Init starts the pod's features. it must be called after vars.Init and before the voice processor is
+// made, which gets the history's and slots' STT wrappers.
+func (p *Pod) Init(engine string) {
+	p.HandlePage("/stt", sttengine.ServePage)
+	p.initMDNS()
+	p.initDNS()
+	p.initDiscovery()
+	p.initModelManager()
+	p.initBackups()
+	p.initVoicePause()
+	p.initHistory(engine)
+	p.initCapture()
+	p.initSlots()
+	p.initWebhooks()
+	p.initMQTT()
+}
+
+// ServeAPI answers the /api-chipper/ requests for the pod's features. it returns false for the ones it
+// doesn't have, which are left to the tree's ChipperHTTPApi.
+func (p *Pod) ServeAPI(w http.ResponseWriter, r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/api-chipper/get_stt_engines", r.URL.Path == "/api-chipper/set_stt_engine":
+		p.sttAPI(w, r)
+	case strings.HasSuffix(r.URL.Path, "vosk_models"), r.URL.Path == "/api-chipper/install_vosk_model", r.URL.Path == "/api-chipper/delete_vosk_model":
+		p.modelsAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/backup_"), strings.HasSuffix(r.URL.Path, "_backup_schedule"):
+		p.backupAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/logs_"), strings.HasSuffix(r.URL.Path, "_log_config"):
+		p.logsAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/pause_"), strings.HasSuffix(r.URL.Path, "_pause_config"):
+		p.pauseAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/mdns_"), strings.HasSuffix(r.URL.Path, "_mdns_config"):
+		p.mdnsAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/dns_"), strings.HasSuffix(r.URL.Path, "_dns_config"):
+		p.dnsAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/discovery_"):
+		p.discoveryAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/slots_"):
+		p.slotsAPI(w, r)
+	case r.URL.Path == "/api-chipper/mqtt_status", strings.HasSuffix(r.URL.Path, "_mqtt_config"):
+		p.mqttAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/webhook"), strings.HasSuffix(r.URL.Path, "_webhook"):
+		p.webhookAPI(w, r)
+	case r.URL.Path == "/api-chipper/history_audio", strings.HasSuffix(r.URL.Path, "_capture_config"):
+		p.captureAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api-chipper/history_"), strings.HasSuffix(r.URL.Path, "_history_config"):
+		p.historyAPI(w, r)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package podkit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/intenttest"
+	"github.com/kercre123/WirePod/cross/replay"
+	"github.com/kercre123/WirePod/cross/slots"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// RunReplayCLI is "replay <files>", which runs recordings through the voice processor without the server
+// or a robot. requests go straight to the processor, so they don't end up in the history.
+func (p *Pod) RunReplayCLI(args []string) int {
+	return replay.RunCLI(p.newReplayProcessor, args)
+}
+
+// RunIntentTestCLI is "intent-test [suites]", which checks utterances still match the intents they
+// should. suites in intent-tests in the pod directory are run if none are given.
+func (p *Pod) RunIntentTestCLI(args []string) int {
+	return intenttest.RunCLI(filepath.Join(p.Dir, intenttest.DirName), p.newIntentTestRunner, args)
+}
+
+func (p *Pod) newReplayProcessor(name string) (history.VoiceProcessor, error) {
+	if err := p.InitOffline(); err != nil {
+		return nil, err
+	}
+	return p.offlineProcessor(name)
+}
+
+func (p *Pod) newIntentTestRunner() (*intenttest.Runner, error) {
+	if err := p.InitOffline(); err != nil {
+		return nil, err
+	}
+	return &intenttest.Runner{
+		Processor: func() (history.VoiceProcessor, error) { return p.offlineProcessor("") },
+	}, nil
+}
+
+// the voice processor for the named STT engine, or the configured one
+func (p *Pod) offlineProcessor(name string) (history.VoiceProcessor, error) {
+	engine := p.SelectSTTEngine()
+	if name != "" {
+		var ok bool
+		engine, ok = sttengine.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("%s isn't an STT engine in this build", name)
+		}
+	}
+	os.Setenv("STT_SERVICE", engine.Name)
+	vars.APIConfig.STT.Service = engine.Name
+	proc, err := p.BuildVoiceProcessor(engine)
+	if err != nil {
+		return nil, err
+	}
+	return slots.Processor{Next: proc, Slots: p.Slots}, nil
+}
@@ -0,0 +1,27 @@
+package podkit
+
+import (
+	"net/http"
+
+	"github.com/kercre123/WirePod/cross/podtest"
+	"github.com/kercre123/WirePod/cross/sttengine"
+)
+
+// RunSelfTestCLI is "selftest", which boots this build's chipper server in a temporary directory and
+// checks it end to end. api is the tree's ChipperHTTPApi.
+func (p *Pod) RunSelfTestCLI(api http.HandlerFunc, args []string) int {
+	return podtest.RunCLI(p.SelfTest(api), args)
+}
+
+func (p *Pod) SelfTest(api http.HandlerFunc) podtest.Pod {
+	return podtest.Pod{
+		Init: func(sttInit func() error, sttHandler interface{}, name string) error {
+			var err error
+			p.VoiceProcessor, err = p.BuildVoiceProcessor(sttengine.Engine{Name: name, Init: sttInit, STT: sttHandler})
+			return err
+		},
+		Start: p.Start,
+		Stop:  p.Stop,
+		API:   api,
+	}
+}
@@ -0,0 +1,77 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/slots"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// the slots answer custom intents with templated utterances, in front of the voice processor. problems
+// with the saved custom intents' slots are logged at start, the editor at /intents asks through the API.
+func (p *Pod) initSlots() {
+	for _, c := range vars.CustomIntents {
+		for _, problem := range slots.Validate(c) {
+			logs.For("slots").Warn("Custom intent " + c.Name + ": " + problem)
+		}
+	}
+	p.HandlePage("/intents", slots.ServeEditor)
+}
+
+type slotsCheck struct {
+	Problems  []string          `json:"problems"`
+	Templates []*slots.Template `json:"templates"`
+}
+
+func (p *Pod) slotsAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/slots_check":
+		// a custom intent as the editor sends it to add_custom_intent, before it is saved
+		var c vars.CustomIntent
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		check := slotsCheck{Problems: slots.Validate(c), Templates: []*slots.Template{}}
+		for _, u := range c.Utterances {
+			if t, err := slots.Parse(u); err == nil && slots.IsTemplate(u) {
+				check.Templates = append(check.Templates, t)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(check)
+	case r.URL.Path == "/api-chipper/slots_validate":
+		// numbered from 1, as edit_custom_intent has them
+		type intentProblems struct {
+			Number   int      `json:"number"`
+			Name     string   `json:"name"`
+			Problems []string `json:"problems"`
+		}
+		all := []intentProblems{}
+		for i, c := range vars.CustomIntents {
+			if problems := slots.Validate(c); len(problems) > 0 {
+				all = append(all, intentProblems{Number: i + 1, Name: c.Name, Problems: problems})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(all)
+	case r.URL.Path == "/api-chipper/slots_match":
+		text := r.FormValue("text")
+		if text == "" {
+			fmt.Fprint(w, "error: must have text")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		m, ok := p.Slots.Match(vars.CustomIntents, text)
+		if !ok {
+			fmt.Fprint(w, "null")
+			return
+		}
+		json.NewEncoder(w).Encode(m)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,122 @@
+package podkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	wpweb "github.com/kercre123/wire-pod/chipper/pkg/wirepod/config-ws"
+	wp "github.com/kercre123/wire-pod/chipper/pkg/wirepod/preqs"
+)
+
+var sttLog = logs.For("stt")
+
+// SelectSTTEngine loads the engine choice from the pod's dir. it falls back to vosk if the chosen one isn't
+// compiled in.
+func (p *Pod) SelectSTTEngine() sttengine.Engine {
+	p.sttConfPath = sttengine.ConfigPath(p.Dir)
+	engine, err := sttengine.Select(sttengine.ReadConfig(p.sttConfPath))
+	if err != nil {
+		sttLog.Error(err)
+		engine, _ = sttengine.Get(sttengine.DefaultEngine)
+	}
+	sttLog.Info("Selected STT engine: " + engine.Name)
+	return engine
+}
+
+// BuildVoiceProcessor makes the voice processor for an STT engine, with the slots' and the history's
+// wrappers around its handler. everything which makes one goes through here, so neither is left holding
+// an old engine's handler. the slots get the new handler only once wp.New has initialized the engine.
+func (p *Pod) BuildVoiceProcessor(engine sttengine.Engine) (*wp.Server, error) {
+	// wp.New sets en-US for engines other than vosk and whisper.cpp. that's for the engine it starts, the
+	// user's language stays for the saved config and for switching back.
+	language := vars.APIConfig.STT.Language
+	stt := p.History.WrapSTT(engine.STT)
+	proc, err := wp.New(engine.Init, p.Slots.WrapSTT(stt), engine.Name)
+	vars.APIConfig.STT.Language = language
+	if err != nil {
+		return nil, err
+	}
+	p.Slots.SetSTT(stt)
+	p.engine = engine
+	return proc, nil
+}
+
+// SwitchSTTEngine initializes the new engine, then saves the choice and restarts the chipper server with
+// it. if the engine doesn't initialize, the old one stays and nothing is saved.
+func (p *Pod) SwitchSTTEngine(conf sttengine.Config) error {
+	old := sttengine.ReadConfig(p.sttConfPath)
+	initFunc := vars.SttInitFunc
+	engine, err := sttengine.Select(conf)
+	if err != nil {
+		sttengine.SetRemoteConfig(old.Remote)
+		return err
+	}
+	sttLog.Info("Switching STT engine to " + engine.Name)
+	proc, err := p.BuildVoiceProcessor(engine)
+	if err != nil {
+		// chipper, the slots and p.engine still have the old handler, wp.New only swaps it in after the init.
+		// what was set up before the init failed goes back.
+		sttengine.SetRemoteConfig(old.Remote)
+		vars.SttInitFunc = initFunc
+		return fmt.Errorf("error initializing %s: %s", engine.Name, err)
+	}
+	p.VoiceProcessor = proc
+	p.History.SetEngine(engine.Name)
+	wpweb.SttInitFunc = engine.Init
+	os.Setenv("STT_SERVICE", engine.Name)
+	vars.APIConfig.STT.Service = engine.Name
+	vars.WriteConfigToDisk()
+	if vars.APIConfig.PastInitialSetup {
+		p.Restart()
+	}
+	if err := sttengine.WriteConfig(p.sttConfPath, conf); err != nil {
+		return fmt.Errorf("switched to %s, but the choice couldn't be saved: %s", engine.Name, err)
+	}
+	return nil
+}
+
+func (p *Pod) sttAPI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api-chipper/get_stt_engines":
+		type engineInfo struct {
+			sttengine.Engine
+			Active bool `json:"active"`
+		}
+		var engines []engineInfo
+		for _, e := range sttengine.List() {
+			engines = append(engines, engineInfo{Engine: e, Active: e.Name == vars.APIConfig.STT.Service})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(engines)
+	case r.URL.Path == "/api-chipper/set_stt_engine":
+		conf := sttengine.ReadConfig(p.sttConfPath)
+		conf.Engine = r.FormValue("engine")
+		if conf.Engine == "" {
+			fmt.Fprint(w, "error: must have engine")
+			return
+		}
+		if url := r.FormValue("url"); url != "" {
+			conf.Remote.URL = url
+		}
+		if key := r.FormValue("key"); key != "" {
+			conf.Remote.Key = key
+		}
+		if model := r.FormValue("model"); model != "" {
+			conf.Remote.Model = model
+		}
+		err := p.SwitchSTTEngine(conf)
+		if err != nil {
+			sttLog.Error(err)
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		fmt.Fprint(w, "done")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
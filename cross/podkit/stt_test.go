@@ -0,0 +1,93 @@
+package podkit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kercre123/WirePod/cross/podtest"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+var errTestInit = errors.New("no model")
+
+func testSTTPod(t *testing.T) (*Pod, *podtest.StubSTT) {
+	t.Helper()
+	p := New(t.TempDir())
+	p.sttConfPath = sttengine.ConfigPath(p.Dir)
+	apiConfigPath, conf := vars.ApiConfigPath, vars.APIConfig
+	t.Cleanup(func() { vars.ApiConfigPath, vars.APIConfig = apiConfigPath, conf })
+	vars.ApiConfigPath = filepath.Join(p.Dir, "apiConfig.json")
+	vars.APIConfig.PastInitialSetup = false
+	vars.APIConfig.STT.Service = "vosk"
+	vars.APIConfig.STT.Language = "de-DE"
+
+	stub := &podtest.StubSTT{Default: "old engine"}
+	var err error
+	p.VoiceProcessor, err = p.BuildVoiceProcessor(sttengine.Engine{Name: "vosk", Init: stub.Init, STT: stub.STT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, stub
+}
+
+func TestSwitchSTTEngineFails(t *testing.T) {
+	p, _ := testSTTPod(t)
+	proc := p.VoiceProcessor
+	sttengine.Register(sttengine.Engine{
+		Name: "test-broken",
+		Init: func() error { return errTestInit },
+		STT:  (&podtest.StubSTT{}).STT,
+	})
+
+	if err := p.SwitchSTTEngine(sttengine.Config{Engine: "test-broken"}); err == nil {
+		t.Fatal("switched to an engine which didn't initialize")
+	}
+	if p.engine.Name != "vosk" || p.VoiceProcessor != proc {
+		t.Errorf("engine = %s, want the old engine and processor", p.engine.Name)
+	}
+	if vars.APIConfig.STT.Service != "vosk" || vars.APIConfig.STT.Language != "de-DE" {
+		t.Errorf("STT config = %+v, want vosk in de-DE", vars.APIConfig.STT)
+	}
+	if _, err := os.Stat(p.sttConfPath); err == nil {
+		t.Error("the failed choice was saved")
+	}
+}
+
+// wp.New sets en-US for engines other than vosk, the user's language must survive going there and back
+func TestSwitchSTTEngineKeepsLanguage(t *testing.T) {
+	p, stub := testSTTPod(t)
+	sttengine.Register(sttengine.Engine{Name: "test-english", Init: stub.Init, STT: stub.STT})
+	// switching back initializes vosk, which needs a model
+	if vosk, ok := sttengine.Get("vosk"); ok {
+		t.Cleanup(func() { sttengine.Register(vosk) })
+	}
+	sttengine.Register(sttengine.Engine{Name: "vosk", Init: stub.Init, STT: stub.STT})
+
+	if err := p.SwitchSTTEngine(sttengine.Config{Engine: "test-english"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(vars.ApiConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		STT struct {
+			Service  string `json:"provider"`
+			Language string `json:"language"`
+		} `json:"STT"`
+	}
+	json.Unmarshal(data, &saved)
+	if saved.STT.Service != "test-english" || saved.STT.Language != "de-DE" {
+		t.Errorf("saved STT config = %+v, want test-english with de-DE", saved.STT)
+	}
+	if err := p.SwitchSTTEngine(sttengine.Config{Engine: "vosk"}); err != nil {
+		t.Fatal(err)
+	}
+	if vars.APIConfig.STT.Language != "de-DE" {
+		t.Errorf("language after switching back = %s, want de-DE", vars.APIConfig.STT.Language)
+	}
+}
@@ -0,0 +1,157 @@
+package podkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/robotactions"
+	"github.com/kercre123/WirePod/cross/webhook"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// custom intents' webhooks are called by the slots when their intent matches. must be called with
+// initSlots. hooks of intents which were renamed or deleted are logged.
+func (p *Pod) initWebhooks() {
+	conf, err := webhook.ReadConfig(webhooksDir())
+	if err != nil {
+		logs.For("webhook").Warn("Error reading webhooks, none will be called: " + err.Error())
+	}
+	p.Webhooks.Set(conf)
+	p.Slots.Webhooks = p.Webhooks
+	p.Slots.Say = robotactions.Robot{}.Say
+	for name := range conf.Hooks {
+		if !customIntentExists(name) {
+			logs.For("webhook").Warn("There is a webhook for " + name + ", but no custom intent by that name")
+		}
+	}
+}
+
+// beside the custom intents they belong to, which vars.Init puts in the pod's dir when packaged
+func webhooksDir() string {
+	return filepath.Dir(vars.CustomIntentsPath)
+}
+
+func customIntentExists(name string) bool {
+	for _, c := range vars.CustomIntents {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secrets aren't shown, so the editor sends them back blank unless they changed. the saved one only goes
+// to the saved URL, or anyone who can reach the API could have it sign requests to their own server.
+func keepSecret(hook *webhook.Hook, saved webhook.Hook) error {
+	if hook.Secret != "" || saved.Secret == "" {
+		return nil
+	}
+	if hook.URL != saved.URL {
+		return fmt.Errorf("the URL changed, enter the secret again or remove it")
+	}
+	hook.Secret = saved.Secret
+	return nil
+}
+
+// the payload webhook_test sends
+type webhookTest struct {
+	// a hook from the editor, before it's saved. nil uses the saved one.
+	Hook  *webhook.Hook     `json:"hook"`
+	ESN   string            `json:"esn"`
+	Text  string            `json:"text"`
+	Slots map[string]string `json:"slots"`
+}
+
+func (p *Pod) webhookAPI(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("intent")
+	if name == "" && r.URL.Path != "/api-chipper/webhooks" {
+		fmt.Fprint(w, "error: must have intent")
+		return
+	}
+	switch r.URL.Path {
+	case "/api-chipper/webhooks":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"hooks": p.Webhooks.Config().Redact()})
+	case "/api-chipper/get_webhook":
+		w.Header().Set("Content-Type", "application/json")
+		hook, ok := p.Webhooks.For(name)
+		if !ok {
+			fmt.Fprint(w, "null")
+			return
+		}
+		json.NewEncoder(w).Encode(hook.Redact())
+	case "/api-chipper/set_webhook", "/api-chipper/delete_webhook":
+		conf := p.Webhooks.Config()
+		if r.URL.Path == "/api-chipper/delete_webhook" {
+			delete(conf.Hooks, name)
+		} else {
+			var hook webhook.Hook
+			if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+				fmt.Fprint(w, "error: "+err.Error())
+				return
+			}
+			if old, ok := conf.Hooks[name]; ok && r.FormValue("clear_secret") != "true" {
+				if err := keepSecret(&hook, old); err != nil {
+					fmt.Fprint(w, "error: "+err.Error())
+					return
+				}
+			}
+			if err := hook.Validate(); err != nil {
+				fmt.Fprint(w, "error: "+err.Error())
+				return
+			}
+			conf.Hooks[name] = hook
+		}
+		if err := webhook.WriteConfig(webhooksDir(), conf); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		p.Webhooks.Set(conf)
+		fmt.Fprint(w, "done")
+	case "/api-chipper/webhook_test":
+		// sends what a match would, and says what came back. the robot doesn't say anything.
+		var test webhookTest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&test); err != nil {
+				fmt.Fprint(w, "error: "+err.Error())
+				return
+			}
+		}
+		hook, ok := p.Webhooks.For(name)
+		if test.Hook != nil {
+			if err := keepSecret(test.Hook, hook); err != nil {
+				fmt.Fprint(w, "error: "+err.Error())
+				return
+			}
+			hook, ok = *test.Hook, true
+		}
+		if !ok {
+			fmt.Fprint(w, "error: "+name+" has no webhook")
+			return
+		}
+		if err := hook.Validate(); err != nil {
+			fmt.Fprint(w, "error: "+err.Error())
+			return
+		}
+		if test.ESN == "" {
+			test.ESN = "00000000"
+		}
+		res := webhook.Send(context.Background(), hook, webhook.Payload{
+			Time:       time.Now(),
+			ESN:        test.ESN,
+			Intent:     name,
+			Transcript: test.Text,
+			Slots:      test.Slots,
+			Locale:     vars.APIConfig.STT.Language,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
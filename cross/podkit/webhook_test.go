@@ -0,0 +1,92 @@
+package podkit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kercre123/WirePod/cross/webhook"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// a pod with a signed hook for "lights" at <server>/saved. the server records the paths it was called on
+// and whether the signature was good.
+func testWebhookPod(t *testing.T) (*Pod, *httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, r.URL.Path)
+		mu.Unlock()
+		if !webhook.Verify("s3cret", body, r.Header.Get(webhook.SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	customIntentsPath := vars.CustomIntentsPath
+	t.Cleanup(func() { vars.CustomIntentsPath = customIntentsPath })
+	vars.CustomIntentsPath = filepath.Join(t.TempDir(), "customIntents.json")
+
+	p := New(t.TempDir())
+	p.Webhooks.Set(webhook.Config{Hooks: map[string]webhook.Hook{
+		"lights": {URL: srv.URL + "/saved", Secret: "s3cret"},
+	}})
+	return p, srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func callWebhookAPI(p *Pod, path string, body interface{}) string {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	p.webhookAPI(w, httptest.NewRequest("POST", path, strings.NewReader(string(data))))
+	return w.Body.String()
+}
+
+func TestWebhookTestSecret(t *testing.T) {
+	p, srv, calls := testWebhookPod(t)
+
+	resp := callWebhookAPI(p, "/api-chipper/webhook_test?intent=lights", webhookTest{Hook: &webhook.Hook{URL: srv.URL + "/saved"}})
+	var res webhook.Result
+	if err := json.Unmarshal([]byte(resp), &res); err != nil || res.Status != http.StatusOK {
+		t.Errorf("testing the saved URL = %s, want it signed with the saved secret", resp)
+	}
+
+	// the saved secret would sign whatever is sent to the other URL
+	resp = callWebhookAPI(p, "/api-chipper/webhook_test?intent=lights", webhookTest{Hook: &webhook.Hook{URL: srv.URL + "/other"}})
+	if !strings.HasPrefix(resp, "error") {
+		t.Errorf("testing another URL = %s, want an error", resp)
+	}
+	if got := calls(); len(got) != 1 || got[0] != "/saved" {
+		t.Errorf("the hook was called on %v, want just /saved", got)
+	}
+}
+
+func TestSetWebhookSecret(t *testing.T) {
+	p, srv, _ := testWebhookPod(t)
+
+	if resp := callWebhookAPI(p, "/api-chipper/set_webhook?intent=lights", webhook.Hook{URL: srv.URL + "/other"}); !strings.HasPrefix(resp, "error") {
+		t.Errorf("moving the hook without its secret = %s, want an error", resp)
+	}
+	if resp := callWebhookAPI(p, "/api-chipper/set_webhook?intent=lights", webhook.Hook{URL: srv.URL + "/saved", Retries: 2}); resp != "done" {
+		t.Fatal(resp)
+	}
+	if hook, _ := p.Webhooks.For("lights"); hook.Secret != "s3cret" || hook.Retries != 2 {
+		t.Errorf("hook = %+v, want the secret kept", hook)
+	}
+	if resp := callWebhookAPI(p, "/api-chipper/set_webhook?intent=lights&clear_secret=true", webhook.Hook{URL: srv.URL + "/other"}); resp != "done" {
+		t.Fatal(resp)
+	}
+	if hook, _ := p.Webhooks.For("lights"); hook.Secret != "" {
+		t.Errorf("hook = %+v, want the secret removed", hook)
+	}
+}
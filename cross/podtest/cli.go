@@ -0,0 +1,64 @@
+package podtest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+const usage = `usage: wire-pod selftest [options]
+
+boots this pod's chipper server in a temporary directory, on a free port with throwaway
+certificates and a stub STT engine, and checks it serves /ok, answers voice requests and jdocs,
+and restarts and moves ports through the API. the installed pod's config and data aren't touched.
+
+options:
+`
+
+// RunCLI runs the checks against pod and prints how they went
+func RunCLI(pod Pod, args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 10*time.Second, "how long the server gets to come up or go down")
+	keep := fs.Bool("keep", false, "keep the temporary directory")
+	ep := fs.Bool("ep", false, "also check escape pod mode, which needs port 443")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	report := Run(pod, Options{Timeout: *timeout, Keep: *keep, EP: *ep})
+	passed, failed, skipped := report.Counts()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, res := range report.Results {
+			switch {
+			case res.Skipped:
+				fmt.Printf("SKIP %s: %s\n", res.Check, res.Message)
+			case !res.Passed:
+				fmt.Printf("FAIL %s: %s\n", res.Check, res.Message)
+			default:
+				fmt.Printf("PASS %s (%d ms)\n", res.Check, res.Time.Milliseconds())
+			}
+		}
+		fmt.Printf("%d passed, %d failed, %d skipped in %s\n", passed, failed, skipped, report.Time.Round(time.Millisecond))
+		if *keep {
+			fmt.Println("kept " + report.Dir)
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,230 @@
+package podtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/kercre123/wire-pod/chipper/pkg/logger"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// a throwaway pod for exercising the packaging layer: StartChipper, RestartServer, ChipperHTTPApi
+// and /ok, against a stub STT engine. chipper keeps its state in package variables and the working
+// directory, so only one Env can be open at a time.
+
+// the intents the stub transcripts are matched against
+var Intents = []vars.JsonIntent{
+	{Name: "intent_greeting_hello", Keyphrases: []string{"hello"}},
+	{Name: "intent_clock_time", Keyphrases: []string{"what time is it", "the time"}},
+	{Name: "intent_system_noaudio", Keyphrases: []string{"[blank_audio]"}, RequireExactMatch: true},
+}
+
+// Env is a temporary directory laid out like an unpackaged install:
+//
+//	certs/        cert.crt, cert.key (also the CA, it is self-signed)
+//	chipper/      the working directory: apiConfig.json, jdocs, session-certs, epod, intent-data
+//	anki_vector/  where the SDK config for robots is written
+type Env struct {
+	Dir        string
+	ChipperDir string
+	// the chipper port the pod is configured with
+	Port string
+	// PEM certificate the pod serves until it makes its own, usable as a CA
+	CAFile string
+
+	prevDir    string
+	prevEnv    map[string]*string
+	prevTester string
+}
+
+// env vars set for the pod, so it stays off the network and out of port 8084
+var podEnv = map[string]string{
+	"DISABLE_MDNS":      "true",
+	"DISABLE_DNS":       "true",
+	"DISABLE_DISCOVERY": "true",
+	"NO8084":            "true",
+	"STT_SERVICE":       StubName,
+	"STT_LANGUAGE":      "en-US",
+	"DDL_RPC_PORT":      "",
+}
+
+// NewEnv makes the directory, changes into it and initializes chipper's variables from it
+func NewEnv() (*Env, error) {
+	dir, err := os.MkdirTemp("", "wire-pod-test-")
+	if err != nil {
+		return nil, err
+	}
+	e := &Env{Dir: dir, ChipperDir: filepath.Join(dir, "chipper"), prevEnv: map[string]*string{}}
+	if err := e.setup(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Env) setup() error {
+	for _, d := range []string{"certs", "anki_vector", "chipper/jdocs", "chipper/session-certs", "chipper/epod", "chipper/intent-data"} {
+		if err := os.MkdirAll(filepath.Join(e.Dir, d), 0755); err != nil {
+			return err
+		}
+	}
+	certPEM, keyPEM, err := Certificate("127.0.0.1", "localhost", "escapepod.local")
+	if err != nil {
+		return err
+	}
+	e.CAFile = filepath.Join(e.Dir, "certs", "cert.crt")
+	files := map[string][]byte{
+		"certs/cert.crt":      certPEM,
+		"certs/cert.key":      keyPEM,
+		"chipper/epod/ep.crt": certPEM,
+		"chipper/epod/ep.key": keyPEM,
+	}
+	intents, _ := json.Marshal(Intents)
+	files["chipper/intent-data/en-US.json"] = intents
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(e.Dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	if e.Port, err = FreePort(); err != nil {
+		return err
+	}
+
+	for k, v := range podEnv {
+		if prev, ok := os.LookupEnv(k); ok {
+			e.prevEnv[k] = &prev
+		} else {
+			e.prevEnv[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+	if e.prevDir, err = os.Getwd(); err != nil {
+		return err
+	}
+	if err := os.Chdir(e.ChipperDir); err != nil {
+		return err
+	}
+	// the unpackaged defaults, relative to chipper/
+	vars.Packaged = false
+	vars.JdocsDir = "./jdocs"
+	vars.JdocsPath = "./jdocs/jdocs.json"
+	vars.BotInfoPath = "./jdocs/" + vars.BotInfoName
+	vars.CustomIntentsPath = "./customIntents.json"
+	vars.BotConfigsPath = "./botConfig.json"
+	vars.SessionCertPath = "./session-certs/"
+	vars.ApiConfigPath = "./apiConfig.json"
+	vars.CertPath = "../certs/cert.crt"
+	vars.KeyPath = "../certs/cert.key"
+	vars.ServerConfigPath = "../certs/server_config.json"
+	vars.Certs = "../certs"
+	// the desktop app serves certificates it already has in memory
+	vars.ChipperKeysLoaded = false
+	// certificates the pod makes itself are for this address
+	e.prevTester = vars.OutboundIPTester
+	vars.OutboundIPTester = "127.0.0.1:80"
+	vars.BotJdocs = nil
+	vars.BotInfo = vars.RobotInfoStore{}
+	vars.CustomIntents = nil
+	vars.CustomIntentsExist = false
+
+	logger.Init()
+	vars.VarsInited = false
+	vars.Init()
+	vars.SDKIniPath = filepath.Join(e.Dir, "anki_vector") + "/"
+	vars.APIConfig.STT.Service = StubName
+	vars.APIConfig.STT.Language = "en-US"
+	vars.APIConfig.Server.EPConfig = false
+	vars.APIConfig.Server.Port = e.Port
+	vars.APIConfig.PastInitialSetup = true
+	vars.WriteConfigToDisk()
+	return nil
+}
+
+// Close changes back to the previous directory and removes the env
+func (e *Env) Close() error {
+	e.restore()
+	return os.RemoveAll(e.Dir)
+}
+
+// puts back the working directory and environment, leaving the files
+func (e *Env) restore() {
+	for k, v := range e.prevEnv {
+		if v == nil {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, *v)
+		}
+	}
+	if e.prevTester != "" {
+		vars.OutboundIPTester = e.prevTester
+	}
+	if e.prevDir != "" {
+		os.Chdir(e.prevDir)
+	}
+	vars.VarsInited = false
+}
+
+// Addr is where the pod's chipper server is on this machine
+func (e *Env) Addr() string {
+	return "127.0.0.1:" + vars.APIConfig.Server.Port
+}
+
+// FreePort asks the kernel for a port nothing is listening on
+func FreePort() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// Certificate makes a self-signed certificate and key for hosts, which are IPs or names
+func Certificate(hosts ...string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "wire-pod test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		// so clients can trust it directly
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
@@ -0,0 +1,27 @@
+package podtest
+
+import (
+	"testing"
+)
+
+// Test runs the checks as subtests, e.g. in a tree's main package:
+//
+//	func TestPod(t *testing.T) {
+//		usePod()
+//		podtest.Test(t, pod.SelfTest(ChipperHTTPApi), podtest.Options{})
+//	}
+func Test(t *testing.T, pod Pod, opts Options) {
+	t.Helper()
+	report := Run(pod, opts)
+	for _, res := range report.Results {
+		res := res
+		t.Run(res.Check, func(t *testing.T) {
+			switch {
+			case res.Skipped:
+				t.Skip(res.Message)
+			case !res.Passed:
+				t.Error(res.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package podtest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/robotsim"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// Pod is the packaging layer under test, filled in by each tree with its own functions
+type Pod struct {
+	// sets up the voice processor with an STT engine, as BeginWirepodSpecific does
+	Init func(sttInit func() error, sttHandler interface{}, name string) error
+	// StartChipper, which blocks while serving
+	Start func()
+	Stop  func()
+	// ChipperHTTPApi, which restarts the server through RestartServer
+	API http.HandlerFunc
+}
+
+type Options struct {
+	// how long the pod gets to come up or go down. defaults to 10 seconds.
+	Timeout time.Duration
+	// leave the env's directory behind, to look at after
+	Keep bool
+	// also switch to escape pod mode, which serves on port 443
+	EP bool
+}
+
+type Result struct {
+	Check   string        `json:"check"`
+	Passed  bool          `json:"passed"`
+	Skipped bool          `json:"skipped,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Time    time.Duration `json:"time"`
+}
+
+type Report struct {
+	// the env's directory, gone unless Keep was set
+	Dir     string        `json:"dir"`
+	Results []Result      `json:"results"`
+	Time    time.Duration `json:"time"`
+}
+
+func (r Report) Counts() (passed, failed, skipped int) {
+	for _, res := range r.Results {
+		switch {
+		case res.Skipped:
+			skipped++
+		case res.Passed:
+			passed++
+		default:
+			failed++
+		}
+	}
+	return
+}
+
+// a check returns errSkip wrapped to be skipped
+var errSkip = errors.New("skipped")
+
+func skip(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{errSkip}, a...)...)
+}
+
+type harness struct {
+	pod     Pod
+	opts    Options
+	env     *Env
+	stt     *StubSTT
+	robot   robotsim.Robot
+	started bool
+}
+
+type check struct {
+	name string
+	run  func(h *harness) error
+	// needs the pod serving
+	serving bool
+}
+
+// the checks, in the order they run. later ones build on the state earlier ones leave.
+var checks = []check{
+	{"init", (*harness).init, false},
+	{"start", (*harness).start, false},
+	{"ok", (*harness).ok, true},
+	{"voice", (*harness).voice, true},
+	{"jdocs", (*harness).jdocs, true},
+	{"restart", (*harness).restart, true},
+	{"use_ip", (*harness).useIP, true},
+	{"use_ip_invalid", (*harness).useIPInvalid, true},
+	{"use_ep", (*harness).useEP, true},
+	{"stop", (*harness).stop, true},
+}
+
+// Run boots the pod in a new Env and goes through the checks. the pod's servers are stopped after,
+// but anything else it started (like its HTTP handlers) stays for the rest of the process.
+func Run(pod Pod, opts Options) Report {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	start := time.Now()
+	var report Report
+	env, err := NewEnv()
+	if err != nil {
+		report.Results = append(report.Results, Result{Check: "env", Message: err.Error()})
+		return report
+	}
+	report.Dir = env.Dir
+	h := &harness{pod: pod, opts: opts, env: env, stt: &StubSTT{}, robot: robotsim.NewRobot("00e20100")}
+	failed := ""
+	for _, c := range checks {
+		checkStart := time.Now()
+		res := Result{Check: c.name}
+		switch {
+		case failed != "" && (c.name == "start" || c.serving):
+			res.Skipped = true
+			res.Message = failed + " failed"
+		case c.serving && !h.started:
+			res.Skipped = true
+			res.Message = "the pod isn't running"
+		default:
+			err := h.safely(c.run)
+			switch {
+			case errors.Is(err, errSkip):
+				res.Skipped = true
+				res.Message = strings.TrimPrefix(err.Error(), errSkip.Error()+": ")
+			case err != nil:
+				res.Message = err.Error()
+				if c.name == "init" || c.name == "start" {
+					failed = c.name
+				}
+			default:
+				res.Passed = true
+			}
+		}
+		res.Time = time.Since(checkStart)
+		report.Results = append(report.Results, res)
+	}
+	if h.started {
+		h.safely((*harness).stop)
+	}
+	if opts.Keep {
+		env.restore()
+	} else {
+		env.Close()
+	}
+	report.Time = time.Since(start)
+	return report
+}
+
+// a panicking pod fails the check instead of the run
+func (h *harness) safely(run func(h *harness) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(h)
+}
+
+func (h *harness) init() error {
+	return h.pod.Init(h.stt.Init, h.stt.STT, StubName)
+}
+
+func (h *harness) start() error {
+	go h.pod.Start()
+	if err := WaitOK(h.env.Addr(), h.env.CAFile, h.opts.Timeout); err != nil {
+		return err
+	}
+	h.started = true
+	return nil
+}
+
+// the pod serves the certificate it was set up with, and answers both of the robot's /ok paths
+func (h *harness) ok() error {
+	client, err := httpsClient(h.env.CAFile)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{"/ok", "/ok:80"} {
+		body, err := get(client, "https://"+h.env.Addr()+path)
+		if err != nil {
+			return err
+		}
+		if body != "ok" {
+			return fmt.Errorf("%s answered %q", path, body)
+		}
+	}
+	return nil
+}
+
+// a voice request goes through the stub engine to the intent its transcript matches
+func (h *harness) voice() error {
+	return h.say("hello", "intent_greeting_hello", h.env.CAFile)
+}
+
+func (h *harness) say(text, intent, ca string) error {
+	ctx := context.Background()
+	c, err := robotsim.Dial(ctx, h.robot, robotsim.Options{Addr: h.env.Addr(), CAFile: ca, DialTimeout: h.opts.Timeout})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	h.stt.Say(text)
+	// a second of silence, what the stub hears is scripted
+	res, err := c.Voice(ctx, robotsim.VoiceRequest{PCM: make([]byte, 32000), Codec: robotsim.CodecPCM})
+	if err != nil {
+		return err
+	}
+	if res.Transcript != text || res.Intent != intent {
+		return fmt.Errorf("said %q, wanted %s, got %s (heard %q)", text, intent, res.Intent, res.Transcript)
+	}
+	return nil
+}
+
+// a robot reading its tokens is noted in the env's bot info
+func (h *harness) jdocs() error {
+	ctx := context.Background()
+	c, err := robotsim.Dial(ctx, h.robot, robotsim.Options{Addr: h.env.Addr(), CAFile: h.env.CAFile, DialTimeout: h.opts.Timeout})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.ReadDocs(ctx, robotsim.StartupDocs...); err != nil {
+		return err
+	}
+	info, err := os.ReadFile(vars.BotInfoPath)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(info), h.robot.ESN) {
+		return fmt.Errorf("%s isn't in %s", h.robot.ESN, vars.BotInfoPath)
+	}
+	return nil
+}
+
+func (h *harness) restart() error {
+	if body := h.api("/api-chipper/restart", nil); body != "done" {
+		return fmt.Errorf("restart answered %q", body)
+	}
+	if err := WaitOK(h.env.Addr(), h.env.CAFile, h.opts.Timeout); err != nil {
+		return err
+	}
+	return h.say("what time is it", "intent_clock_time", h.env.CAFile)
+}
+
+// the pod moves to another port with a certificate it makes itself, and saves where it went
+func (h *harness) useIP() error {
+	oldAddr := h.env.Addr()
+	port, err := FreePort()
+	if err != nil {
+		return err
+	}
+	if body := h.api("/api-chipper/use_ip", url.Values{"port": {port}}); body != "done" {
+		return fmt.Errorf("use_ip answered %q", body)
+	}
+	if vars.APIConfig.Server.Port != port {
+		return fmt.Errorf("port is %s, not %s", vars.APIConfig.Server.Port, port)
+	}
+	if err := WaitOK(h.env.Addr(), "", h.opts.Timeout); err != nil {
+		return err
+	}
+	if err := waitClosed(oldAddr, h.opts.Timeout); err != nil {
+		return err
+	}
+	if err := fileContains(vars.ApiConfigPath, `"port":"`+port+`"`); err != nil {
+		return err
+	}
+	if err := fileContains(vars.ServerConfigPath, ":"+port+`"`); err != nil {
+		return err
+	}
+	return h.say("hello", "intent_greeting_hello", "")
+}
+
+// a bad port is refused, and the pod carries on where it was
+func (h *harness) useIPInvalid() error {
+	port := vars.APIConfig.Server.Port
+	if body := h.api("/api-chipper/use_ip", url.Values{"port": {"eighty"}}); !strings.HasPrefix(body, "error: ") {
+		return fmt.Errorf("use_ip with a bad port answered %q", body)
+	}
+	if body := h.api("/api-chipper/use_ip", nil); !strings.HasPrefix(body, "error: ") {
+		return fmt.Errorf("use_ip without a port answered %q", body)
+	}
+	if vars.APIConfig.Server.Port != port {
+		return fmt.Errorf("port changed to %s", vars.APIConfig.Server.Port)
+	}
+	return WaitOK(h.env.Addr(), "", h.opts.Timeout)
+}
+
+// escape pod mode serves the escape pod certificate on 443
+func (h *harness) useEP() error {
+	if !h.opts.EP {
+		return skip("escape pod mode wasn't asked for")
+	}
+	l, err := net.Listen("tcp", ":443")
+	if err != nil {
+		return skip("can't listen on port 443: %s", err)
+	}
+	l.Close()
+	if body := h.api("/api-chipper/use_ep", nil); body != "done" {
+		return fmt.Errorf("use_ep answered %q", body)
+	}
+	if !vars.APIConfig.Server.EPConfig || vars.APIConfig.Server.Port != "443" {
+		return errors.New("the config isn't in escape pod mode")
+	}
+	if err := WaitOK(h.env.Addr(), h.env.CAFile, h.opts.Timeout); err != nil {
+		return err
+	}
+	if err := fileContains(vars.ServerConfigPath, "escapepod.local:443"); err != nil {
+		return err
+	}
+	return h.say("hello", "intent_greeting_hello", h.env.CAFile)
+}
+
+func (h *harness) stop() error {
+	addr := h.env.Addr()
+	h.pod.Stop()
+	h.started = false
+	return waitClosed(addr, h.opts.Timeout)
+}
+
+func (h *harness) api(path string, form url.Values) string {
+	if form != nil {
+		path += "?" + form.Encode()
+	}
+	rec := httptest.NewRecorder()
+	h.pod.API(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Body.String()
+}
+
+func httpsClient(ca string) (*http.Client, error) {
+	conf := &tls.Config{InsecureSkipVerify: true}
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in " + ca)
+		}
+		conf = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Timeout: 2 * time.Second, Transport: &http.Transport{TLSClientConfig: conf}}, nil
+}
+
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+// WaitOK waits for the pod at addr to answer /ok over TLS, with a certificate ca vouches for
+// unless ca is empty
+func WaitOK(addr, ca string, timeout time.Duration) error {
+	client, err := httpsClient(ca)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		body, err := get(client, "https://"+addr+"/ok")
+		if err == nil && body == "ok" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("answered %q", body)
+			}
+			return fmt.Errorf("%s isn't serving /ok: %s", addr, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func waitClosed(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return nil
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is still listening", addr)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func fileContains(path, s string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), s) {
+		return fmt.Errorf("%s doesn't have %s", path, s)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package podtest
+
+import (
+	"sync"
+
+	sr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/speechrequest"
+)
+
+// StubName is what the stub engine is called in the pod's config
+const StubName = "stub"
+
+// StubSTT is an STT engine that hears scripted transcripts instead of the audio it is sent
+type StubSTT struct {
+	// heard once the script runs out
+	Default string
+
+	mu     sync.Mutex
+	script []string
+	heard  []string
+}
+
+// Say queues transcripts, one per voice request
+func (s *StubSTT) Say(texts ...string) {
+	s.mu.Lock()
+	s.script = append(s.script, texts...)
+	s.mu.Unlock()
+}
+
+// Heard is the transcripts given so far, oldest first
+func (s *StubSTT) Heard() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.heard...)
+}
+
+func (s *StubSTT) Init() error {
+	return nil
+}
+
+// STT has the handler signature wp.New takes
+func (s *StubSTT) STT(req sr.SpeechRequest) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text := s.Default
+	if len(s.script) > 0 {
+		text, s.script = s.script[0], s.script[1:]
+	}
+	s.heard = append(s.heard, text)
+	return text, nil
+}
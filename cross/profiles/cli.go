@@ -0,0 +1,93 @@
+package profiles
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var cliUsage = `usage: %s profile <command> [flags]
+
+commands:
+  list                                  list profiles
+  add -webport N [-chipperport N] [-mdns] <name>
+                                        add a profile. without -chipperport it gets the
+                                        first free port from 8085.
+  remove <name>                         remove a profile (its data directory is kept)
+
+start a profile with --profile <name>, or WIREPOD_PROFILE=<name>.
+`
+
+// RunCLI handles "profile ..." arguments and returns an exit code
+func RunCLI(configDir string, args []string) int {
+	usage := func() int {
+		fmt.Fprintf(os.Stderr, cliUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	fs := flag.NewFlagSet("profile "+args[0], flag.ContinueOnError)
+	webPort := fs.String("webport", "", "web interface port")
+	chipperPort := fs.String("chipperport", "", "chipper port, replacing the one chosen during the profile's setup")
+	mdns := fs.Bool("mdns", false, "announce escapepod.local over mDNS")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	ps, err := Read(configDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading profiles:", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		fmt.Printf("%-16s %-8s %-8s %s\n", "NAME", "WEB", "CHIPPER", "MDNS")
+		fmt.Printf("%-16s %-8s %-8s %s\n", Default, "-", "-", "-")
+		for _, p := range ps.Profiles {
+			fmt.Printf("%-16s %-8s %-8s %t\n", p.Name, p.WebPort, p.ChipperPort, p.MDNS)
+		}
+		return 0
+	case "add":
+		if fs.NArg() != 1 {
+			return usage()
+		}
+		if _, ok := ps.Get(fs.Arg(0)); ok {
+			fmt.Fprintln(os.Stderr, "profile", fs.Arg(0), "already exists")
+			return 1
+		}
+		if *chipperPort == "" {
+			*chipperPort = ps.FreePort(DefaultPorts(configDir))
+		}
+		ps.Profiles = append(ps.Profiles, Profile{Name: fs.Arg(0), WebPort: *webPort, ChipperPort: *chipperPort, MDNS: *mdns})
+		if err := Write(configDir, ps); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("Added profile " + fs.Arg(0) + ", chipper port " + *chipperPort)
+	case "remove":
+		if fs.NArg() != 1 {
+			return usage()
+		}
+		var kept []Profile
+		for _, p := range ps.Profiles {
+			if p.Name != fs.Arg(0) {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == len(ps.Profiles) {
+			fmt.Fprintln(os.Stderr, "there is no profile named", fs.Arg(0))
+			return 1
+		}
+		ps.Profiles = kept
+		if err := Write(configDir, ps); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("Removed profile " + fs.Arg(0) + ". Its data is still in " + filepath.Join(configDir, Profile{Name: fs.Arg(0)}.PodName()))
+	default:
+		return usage()
+	}
+	return 0
+}
@@ -0,0 +1,269 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// a profile is a separate pod on the same machine: its own data directory (<config dir>/wire-pod-<name>),
+// web and chipper ports, mDNS setting and tray icon. the default profile is the pod which existed before
+// profiles, in <config dir>/wire-pod. profiles are listed in that directory's profiles.json.
+
+const Default = "default"
+
+var ConfigName = "profiles.json"
+
+// the default profile's web port. podapp sets it to the one chosen during install.
+var DefaultWebPort = "8080"
+
+type Profile struct {
+	Name    string `json:"name"`
+	WebPort string `json:"webport"`
+	// the profile's own, replacing the port chosen during its setup
+	ChipperPort string `json:"chipperport"`
+	MDNS        bool   `json:"mdns"`
+}
+
+type Profiles struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// PodName is the name of the profile's directory in the user config dir, what vars.PodName must be set to
+func (p Profile) PodName() string {
+	if p.Name == Default || p.Name == "" {
+		return "wire-pod"
+	}
+	return "wire-pod-" + p.Name
+}
+
+func (p Profile) IsDefault() bool {
+	return p.Name == Default || p.Name == ""
+}
+
+// Title is base with the profile's name, for window and tray titles. the default profile adds nothing.
+func (p Profile) Title(base string) string {
+	if p.IsDefault() {
+		return base
+	}
+	return base + " (" + p.Name + ")"
+}
+
+func validPort(port string) bool {
+	i, err := strconv.Atoi(port)
+	return err == nil && i > 0 && i <= 65535
+}
+
+func (p Profile) Validate() error {
+	if !namePattern.MatchString(p.Name) {
+		return fmt.Errorf("profile name %q must be lowercase letters, numbers and dashes", p.Name)
+	}
+	if p.Name == Default {
+		return errors.New("the default profile can't be configured here")
+	}
+	if !validPort(p.WebPort) {
+		return fmt.Errorf("profile %s: web port %q is invalid", p.Name, p.WebPort)
+	}
+	if !validPort(p.ChipperPort) {
+		return fmt.Errorf("profile %s: chipper port %q is invalid", p.Name, p.ChipperPort)
+	}
+	if p.ChipperPort == p.WebPort {
+		return fmt.Errorf("profile %s: the web and chipper ports are the same", p.Name)
+	}
+	return nil
+}
+
+// DefaultPorts are the ports the default profile listens on: its web port and the chipper port from its
+// apiConfig.json, which is 443 and 8084 in escape pod mode. before setup there is no chipper port yet.
+func DefaultPorts(configDir string) []string {
+	ports := []string{DefaultWebPort}
+	epConfig, port, ok := defaultServer(configDir)
+	if !ok {
+		return ports
+	}
+	if epConfig {
+		return append(ports, "443", "8084")
+	}
+	if port != "" {
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// ChipperPort is the port p's chipper server listens on. the default profile's is the one chosen during
+// setup, 443 before that, same as chipper's own default.
+func ChipperPort(configDir string, p Profile) string {
+	if !p.IsDefault() {
+		return p.ChipperPort
+	}
+	if _, port, ok := defaultServer(configDir); ok && port != "" {
+		return port
+	}
+	return "443"
+}
+
+// the server section of the default profile's apiConfig.json. ok is false before setup.
+func defaultServer(configDir string) (epConfig bool, port string, ok bool) {
+	var conf struct {
+		Server struct {
+			EPConfig bool   `json:"epconfig"`
+			Port     string `json:"port"`
+		} `json:"server"`
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, Profile{Name: Default}.PodName(), "apiConfig.json"))
+	if err != nil || json.Unmarshal(data, &conf) != nil {
+		return false, "", false
+	}
+	return conf.Server.EPConfig, conf.Server.Port, true
+}
+
+// Validate also checks that no two profiles share a name or a port, and that none takes one of defaultPorts
+func (ps Profiles) Validate(defaultPorts []string) error {
+	names := map[string]bool{}
+	ports := map[string]string{}
+	for _, port := range defaultPorts {
+		ports[port] = Default
+	}
+	for _, p := range ps.Profiles {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if names[p.Name] {
+			return fmt.Errorf("there are two profiles named %s", p.Name)
+		}
+		names[p.Name] = true
+		for _, port := range []string{p.WebPort, p.ChipperPort} {
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("profiles %s and %s both use port %s", other, p.Name, port)
+			}
+			ports[port] = p.Name
+		}
+	}
+	return nil
+}
+
+// FreePort is the first port from 8085 which no profile and none of defaultPorts use
+func (ps Profiles) FreePort(defaultPorts []string) string {
+	used := map[string]bool{}
+	for _, port := range defaultPorts {
+		used[port] = true
+	}
+	for _, p := range ps.Profiles {
+		used[p.WebPort] = true
+		used[p.ChipperPort] = true
+	}
+	port := 8085
+	for used[strconv.Itoa(port)] {
+		port++
+	}
+	return strconv.Itoa(port)
+}
+
+func (ps Profiles) Get(name string) (Profile, bool) {
+	for _, p := range ps.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+func (ps Profiles) Names() []string {
+	var names []string
+	for _, p := range ps.Profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ConfigPath is in the default profile's directory
+func ConfigPath(configDir string) string {
+	return filepath.Join(configDir, "wire-pod", ConfigName)
+}
+
+// Read returns no profiles if the file doesn't exist
+func Read(configDir string) (Profiles, error) {
+	var ps Profiles
+	data, err := os.ReadFile(ConfigPath(configDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ps, nil
+		}
+		return ps, err
+	}
+	if err := json.Unmarshal(data, &ps); err != nil {
+		return Profiles{}, err
+	}
+	// profiles from before every profile had its own chipper port get a free one
+	for i := range ps.Profiles {
+		if ps.Profiles[i].ChipperPort == "" {
+			ps.Profiles[i].ChipperPort = ps.FreePort(DefaultPorts(configDir))
+		}
+	}
+	// without the default profile's ports, so a profile whose port it took later can still be listed and removed
+	return ps, ps.Validate(nil)
+}
+
+func Write(configDir string, ps Profiles) error {
+	if err := ps.Validate(DefaultPorts(configDir)); err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(ConfigPath(configDir)), 0777)
+	data, _ := json.MarshalIndent(ps, "", "  ")
+	return os.WriteFile(ConfigPath(configDir), data, 0644)
+}
+
+// Select finds the profile to run. "" and "default" are the default profile.
+func Select(configDir, name string) (Profile, error) {
+	if name == "" || name == Default {
+		return Profile{Name: Default}, nil
+	}
+	ps, err := Read(configDir)
+	if err != nil {
+		return Profile{}, fmt.Errorf("error reading profiles: %s", err)
+	}
+	p, ok := ps.Get(name)
+	if !ok {
+		known := ps.Names()
+		if len(known) == 0 {
+			return Profile{}, fmt.Errorf("there is no profile named %s. create one with \"profile add\"", name)
+		}
+		return Profile{}, fmt.Errorf("there is no profile named %s. known profiles: %s", name, strings.Join(known, ", "))
+	}
+	// the default profile's ports can change after the profile was added
+	if err := (Profiles{Profiles: []Profile{p}}).Validate(DefaultPorts(configDir)); err != nil {
+		return Profile{}, err
+	}
+	return p, nil
+}
+
+// ParseFlag takes --profile name (or -profile, or =name) out of args. it falls back to WIREPOD_PROFILE.
+// taking it out keeps the positions of the other arguments the same as without profiles.
+func ParseFlag(args []string) (string, []string, error) {
+	name := os.Getenv("WIREPOD_PROFILE")
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || flagName != "profile" {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", args, errors.New("--profile needs a name")
+			}
+			i++
+			value = args[i]
+		}
+		name = value
+	}
+	return name, rest, nil
+}
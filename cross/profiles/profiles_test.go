@@ -0,0 +1,155 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeAPIConfig(t *testing.T, configDir, data string) {
+	t.Helper()
+	dir := filepath.Join(configDir, "wire-pod")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "apiConfig.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultPorts(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiConfig string
+		want      []string
+	}{
+		{"not set up", "", []string{"8080"}},
+		{"ip", `{"server":{"epconfig":false,"port":"8084"}}`, []string{"8080", "8084"}},
+		{"escape pod", `{"server":{"epconfig":true,"port":"443"}}`, []string{"8080", "443", "8084"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.apiConfig != "" {
+				writeAPIConfig(t, dir, tt.apiConfig)
+			}
+			if got := DefaultPorts(dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DefaultPorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChipperPort(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiConfig string
+		profile   Profile
+		want      string
+	}{
+		{"not set up", "", Profile{Name: Default}, "443"},
+		{"ip", `{"server":{"epconfig":false,"port":"8084"}}`, Profile{Name: Default}, "8084"},
+		{"escape pod", `{"server":{"epconfig":true,"port":"443"}}`, Profile{}, "443"},
+		{"profile", `{"server":{"epconfig":false,"port":"8084"}}`, Profile{Name: "test", ChipperPort: "8085"}, "8085"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.apiConfig != "" {
+				writeAPIConfig(t, dir, tt.apiConfig)
+			}
+			if got := ChipperPort(dir, tt.profile); got != tt.want {
+				t.Errorf("ChipperPort() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defaults := []string{"8080", "443", "8084"}
+	tests := []struct {
+		name    string
+		ps      []Profile
+		wantErr bool
+	}{
+		{"ok", []Profile{{Name: "test", WebPort: "8081", ChipperPort: "8085"}}, false},
+		{"no chipper port", []Profile{{Name: "test", WebPort: "8081"}}, true},
+		{"same web and chipper port", []Profile{{Name: "test", WebPort: "8081", ChipperPort: "8081"}}, true},
+		{"default web port", []Profile{{Name: "test", WebPort: "8080", ChipperPort: "8085"}}, true},
+		{"default chipper port", []Profile{{Name: "test", WebPort: "8081", ChipperPort: "443"}}, true},
+		{"escape pod's 8084", []Profile{{Name: "test", WebPort: "8084", ChipperPort: "8085"}}, true},
+		{"shared port", []Profile{{Name: "a", WebPort: "8081", ChipperPort: "8085"}, {Name: "b", WebPort: "9000", ChipperPort: "8081"}}, true},
+		{"same name", []Profile{{Name: "a", WebPort: "8081", ChipperPort: "8085"}, {Name: "a", WebPort: "8082", ChipperPort: "8086"}}, true},
+		{"default name", []Profile{{Name: Default, WebPort: "8081", ChipperPort: "8085"}}, true},
+		{"bad name", []Profile{{Name: "Test", WebPort: "8081", ChipperPort: "8085"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Profiles{Profiles: tt.ps}.Validate(defaults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteAndSelect(t *testing.T) {
+	dir := t.TempDir()
+	writeAPIConfig(t, dir, `{"server":{"epconfig":false,"port":"8084"}}`)
+	if err := Write(dir, Profiles{Profiles: []Profile{{Name: "test", WebPort: "8084", ChipperPort: "8085"}}}); err == nil {
+		t.Fatal("Write allowed the default profile's chipper port")
+	}
+	if err := Write(dir, Profiles{Profiles: []Profile{{Name: "test", WebPort: "8081", ChipperPort: "443"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := Select(dir, "test"); err != nil || p.WebPort != "8081" {
+		t.Fatalf("Select() = %+v, %v", p, err)
+	}
+
+	// the default profile moved to escape pod mode after the profile was added
+	writeAPIConfig(t, dir, `{"server":{"epconfig":true,"port":"443"}}`)
+	if _, err := Select(dir, "test"); err == nil {
+		t.Error("Select started a profile on the default profile's port")
+	}
+	ps, err := Read(dir)
+	if err != nil || len(ps.Profiles) != 1 {
+		t.Errorf("Read() = %+v, %v, the profile must still be listed so it can be removed", ps, err)
+	}
+}
+
+func TestFreePort(t *testing.T) {
+	ps := Profiles{Profiles: []Profile{{Name: "a", WebPort: "8085", ChipperPort: "8087"}}}
+	if got := ps.FreePort([]string{"8080", "8086"}); got != "8088" {
+		t.Errorf("FreePort() = %s, want 8088", got)
+	}
+}
+
+// profiles.json from before chipper ports were required
+func TestReadAllocatesChipperPorts(t *testing.T) {
+	dir := t.TempDir()
+	writeAPIConfig(t, dir, `{"server":{"epconfig":false,"port":"8085"}}`)
+	data := `{"profiles":[{"name":"a","webport":"8081"},{"name":"b","webport":"8082"}]}`
+	if err := os.WriteFile(ConfigPath(dir), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := ps.Profiles[0].ChipperPort, ps.Profiles[1].ChipperPort; a != "8086" || b != "8087" {
+		t.Errorf("chipper ports = %s, %s, want 8086, 8087", a, b)
+	}
+}
+
+func TestAddAllocatesChipperPort(t *testing.T) {
+	dir := t.TempDir()
+	writeAPIConfig(t, dir, `{"server":{"epconfig":true,"port":"443"}}`)
+	if code := RunCLI(dir, []string{"add", "-webport", "8081", "test"}); code != 0 {
+		t.Fatalf("add = %d", code)
+	}
+	p, err := Select(dir, "test")
+	if err != nil || p.ChipperPort != "8085" {
+		t.Errorf("Select() = %+v, %v, want chipper port 8085", p, err)
+	}
+}
@@ -0,0 +1,108 @@
+package replay
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kercre123/WirePod/cross/history"
+)
+
+const usage = `usage: wire-pod replay [options] <file or directory>...
+
+runs recordings through the voice processor, without a robot or network, and prints what it
+heard and matched. .wav (16 kHz mono 16-bit), .ogg/.opus (as robots send it) and raw .pcm are
+read, and directories are searched for them (like the audio capture directory).
+
+options:
+`
+
+// RunCLI replays the files in args. newProcessor sets up the voice processor for the named
+// STT engine, or the configured one if the name is empty.
+func RunCLI(newProcessor func(engine string) (history.VoiceProcessor, error), args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	engine := fs.String("engine", "", "STT engine to use instead of the configured one")
+	mode := fs.String("mode", ModeIntent, "request type: intent, intent_graph or knowledge_graph")
+	esn := fs.String("esn", DefaultESN, "ESN the requests come from")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	realtime := fs.Bool("realtime", false, "play audio in at the speed a robot would")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	files, err := Files(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no recordings found")
+		return 1
+	}
+	proc, err := newProcessor(*engine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error starting the voice processor: "+err.Error())
+		return 1
+	}
+	failed := 0
+	var results []Result
+	for _, file := range files {
+		var res Result
+		pcm, err := ReadPCM(file)
+		if err != nil {
+			res = Result{Mode: *mode, Error: err.Error()}
+		} else {
+			res = Replay(proc, *mode, *esn, pcm, *realtime)
+		}
+		res.File = file
+		if res.Error != "" {
+			failed++
+		}
+		results = append(results, res)
+		if !*asJSON {
+			printResult(res)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		fmt.Printf("%d replayed, %d with errors\n", len(results), failed)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printResult(res Result) {
+	fmt.Println(res.File)
+	fmt.Printf("  heard:    %q\n", res.Transcript)
+	fmt.Printf("  intent:   %s\n", res.Intent)
+	if len(res.Params) > 0 {
+		var pairs []string
+		for k, v := range res.Params {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		fmt.Printf("  params:   %s\n", strings.Join(pairs, ", "))
+	}
+	if res.Response != "" {
+		fmt.Printf("  response: %s\n", res.Response)
+	}
+	if res.Error != "" {
+		fmt.Printf("  error:    %s\n", res.Error)
+	}
+	fmt.Printf("  timing:   %d ms of audio, answered after %d ms, done after %d ms\n", res.AudioMs, res.LatencyMs, res.DurationMs)
+}
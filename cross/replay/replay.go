@@ -0,0 +1,193 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/wav"
+	"github.com/kercre123/wire-pod/chipper/pkg/vtt"
+)
+
+// the kinds of request a recording can be replayed as, the same as the gRPC calls robots make
+const (
+	ModeIntent         = "intent"
+	ModeIntentGraph    = "intent_graph"
+	ModeKnowledgeGraph = "knowledge_graph"
+)
+
+// DefaultESN is the device replayed requests come from. it isn't a real robot, so
+// anything which needs one (KGSim, LLM responses) fails and shows up in the result.
+var DefaultESN = "00000000"
+
+type Result struct {
+	File       string            `json:"file"`
+	Mode       string            `json:"mode"`
+	Transcript string            `json:"transcript"`
+	Intent     string            `json:"intent"`
+	Params     map[string]string `json:"params,omitempty"`
+	Response   string            `json:"response,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	// length of the recording
+	AudioMs int64 `json:"audioms"`
+	// from the start of the request until the first response
+	LatencyMs int64 `json:"latencyms"`
+	// from the start of the request until the processor returned
+	DurationMs int64 `json:"durationms"`
+}
+
+// Files expands directories (like the audio capture directory) to the recordings in them
+func Files(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".wav", ".ogg", ".opus", ".pcm":
+				found = append(found, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// ReadPCM reads a recording as 16 kHz mono 16-bit PCM. WAV, Ogg Opus (as robots send it) and raw PCM are understood.
+func ReadPCM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		return wav.Decode(data)
+	case ".ogg", ".opus":
+		if !history.IsOpus(data) {
+			return nil, errors.New("not an Ogg stream")
+		}
+		return history.PCM(data)
+	case ".pcm":
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s isn't a .wav, .ogg, .opus or .pcm file", filepath.Base(path))
+}
+
+// Replay sends pcm through proc as a request from esn, the way chipper's gRPC server would
+func Replay(proc history.VoiceProcessor, mode, esn string, pcm []byte, realtime bool) Result {
+	res := Result{Mode: mode, AudioMs: int64(len(pcm) / 32)}
+	session := fmt.Sprintf("replay-%d", time.Now().UnixNano())
+	audio := NewAudio(esn, session, pcm)
+	audio.Realtime = realtime
+	lang := pb.LanguageCode_ENGLISH_US
+	start := time.Now()
+	var first time.Time
+	var err error
+	switch mode {
+	case ModeIntent:
+		stream := &IntentStream{Audio: audio, Language: lang}
+		_, err = proc.ProcessIntent(&vtt.IntentRequest{
+			Time:       start,
+			Stream:     stream,
+			Device:     esn,
+			Session:    session,
+			LangString: lang.String(),
+			FirstReq:   &pb.StreamingIntentRequest{DeviceId: esn, Session: session, InputAudio: audio.First(), LanguageCode: lang, AudioEncoding: pb.AudioEncoding_LINEAR_PCM},
+			AudioCodec: pb.AudioEncoding_LINEAR_PCM,
+		})
+		first = stream.FirstResponse()
+		for _, resp := range stream.Responses {
+			res.intent(resp.GetIntentResult())
+		}
+	case ModeIntentGraph:
+		stream := &IntentGraphStream{Audio: audio, Language: lang}
+		_, err = proc.ProcessIntentGraph(&vtt.IntentGraphRequest{
+			Time:       start,
+			Stream:     stream,
+			Device:     esn,
+			Session:    session,
+			LangString: lang.String(),
+			FirstReq:   &pb.StreamingIntentGraphRequest{DeviceId: esn, Session: session, InputAudio: audio.First(), LanguageCode: lang, AudioEncoding: pb.AudioEncoding_LINEAR_PCM},
+			AudioCodec: pb.AudioEncoding_LINEAR_PCM,
+			Mode:       pb.RobotMode_VOICE_COMMAND,
+		})
+		first = stream.FirstResponse()
+		for _, resp := range stream.Responses {
+			res.intent(resp.GetIntentResult())
+			if resp.QueryText != "" {
+				res.Transcript = resp.QueryText
+			}
+			res.Response += resp.SpokenText
+		}
+	case ModeKnowledgeGraph:
+		stream := &KnowledgeGraphStream{Audio: audio, Language: lang}
+		_, err = proc.ProcessKnowledgeGraph(&vtt.KnowledgeGraphRequest{
+			Time:       start,
+			Stream:     stream,
+			Device:     esn,
+			Session:    session,
+			LangString: lang.String(),
+			FirstReq:   &pb.StreamingKnowledgeGraphRequest{DeviceId: esn, Session: session, InputAudio: audio.First(), LanguageCode: lang, AudioEncoding: pb.AudioEncoding_LINEAR_PCM},
+			AudioCodec: pb.AudioEncoding_LINEAR_PCM,
+		})
+		first = stream.FirstResponse()
+		res.Intent = "knowledge_graph"
+		for _, resp := range stream.Responses {
+			if resp.QueryText != "" {
+				res.Transcript = resp.QueryText
+			}
+			res.Response += resp.SpokenText
+		}
+	default:
+		err = fmt.Errorf("mode must be %s, %s or %s", ModeIntent, ModeIntentGraph, ModeKnowledgeGraph)
+	}
+	end := time.Now()
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if first.IsZero() {
+		first = end
+	}
+	res.LatencyMs = first.Sub(start).Milliseconds()
+	res.DurationMs = end.Sub(start).Milliseconds()
+	return res
+}
+
+func (r *Result) intent(res *pb.IntentResult) {
+	if res == nil {
+		return
+	}
+	r.Transcript = res.QueryText
+	r.Intent = res.Action
+	r.Params = map[string]string{}
+	for k, v := range res.Parameters {
+		if k != "" || v != "" {
+			r.Params[k] = v
+		}
+	}
+	if len(r.Params) == 0 {
+		r.Params = nil
+	}
+	// errors from the voice processor come back as a parameter
+	if e, ok := res.Parameters["error"]; ok && r.Error == "" {
+		r.Error = e
+	}
+}
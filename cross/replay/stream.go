@@ -0,0 +1,182 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	pb "github.com/digital-dream-labs/api/go/chipperpb"
+	"google.golang.org/grpc/metadata"
+)
+
+// stand-ins for the gRPC streams chipper hands the voice processor. they play audio in as a robot
+// would and keep what is sent back, so requests can be processed without a robot or network.
+
+// ChunkSize is how much PCM each request carries, 32 ms at 16 kHz
+var ChunkSize = 1024
+
+// TrailingSilence is played after the audio, so the VAD sees the end of speech
+var TrailingSilence = 2 * time.Second
+
+// Audio is the robot side of a stream
+type Audio struct {
+	Device  string
+	Session string
+	// sleep for each chunk's length, for timing streaming engines as a robot would
+	Realtime bool
+
+	mu     sync.Mutex
+	chunks [][]byte
+	next   int
+}
+
+// NewAudio splits 16 kHz mono 16-bit PCM into chunks, with trailing silence
+func NewAudio(device, session string, pcm []byte) *Audio {
+	silence := make([]byte, int(TrailingSilence.Seconds()*16000)*2)
+	all := append(append([]byte{}, pcm...), silence...)
+	// chipper treats a first byte of 0x4f as the start of an Ogg stream
+	if len(all) > 0 && all[0] == 0x4f {
+		all[0] = 0x4e
+	}
+	a := &Audio{Device: device, Session: session}
+	for len(all) > 0 {
+		n := ChunkSize
+		if n > len(all) {
+			n = len(all)
+		}
+		a.chunks = append(a.chunks, all[:n])
+		all = all[n:]
+	}
+	return a
+}
+
+// First is the first chunk, which chipper reads before handing the request over
+func (a *Audio) First() []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.next >= len(a.chunks) {
+		return nil
+	}
+	a.next++
+	return a.chunks[a.next-1]
+}
+
+// Next is the next chunk, or io.EOF once all audio has been played
+func (a *Audio) Next() ([]byte, error) {
+	a.mu.Lock()
+	if a.next >= len(a.chunks) {
+		a.mu.Unlock()
+		return nil, io.EOF
+	}
+	chunk := a.chunks[a.next]
+	a.next++
+	a.mu.Unlock()
+	if a.Realtime {
+		time.Sleep(time.Duration(len(chunk)/2) * time.Second / 16000)
+	}
+	return chunk, nil
+}
+
+// the grpc.ServerStream part, which the voice processor doesn't use
+type serverStream struct{}
+
+func (serverStream) SetHeader(metadata.MD) error  { return nil }
+func (serverStream) SendHeader(metadata.MD) error { return nil }
+func (serverStream) SetTrailer(metadata.MD)       {}
+func (serverStream) Context() context.Context     { return context.Background() }
+func (serverStream) SendMsg(interface{}) error    { return nil }
+func (serverStream) RecvMsg(interface{}) error    { return nil }
+
+// responses keeps what the processor sent and when the first one came
+type responses struct {
+	mu    sync.Mutex
+	first time.Time
+}
+
+func (r *responses) sent() {
+	r.mu.Lock()
+	if r.first.IsZero() {
+		r.first = time.Now()
+	}
+	r.mu.Unlock()
+}
+
+// FirstResponse is when the processor first answered, zero if it didn't
+func (r *responses) FirstResponse() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.first
+}
+
+type IntentStream struct {
+	serverStream
+	responses
+	Audio     *Audio
+	Language  pb.LanguageCode
+	Responses []*pb.IntentResponse
+}
+
+func (s *IntentStream) Recv() (*pb.StreamingIntentRequest, error) {
+	chunk, err := s.Audio.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StreamingIntentRequest{DeviceId: s.Audio.Device, Session: s.Audio.Session, InputAudio: chunk, LanguageCode: s.Language, AudioEncoding: pb.AudioEncoding_LINEAR_PCM}, nil
+}
+
+func (s *IntentStream) Send(resp *pb.IntentResponse) error {
+	s.sent()
+	s.mu.Lock()
+	s.Responses = append(s.Responses, resp)
+	s.mu.Unlock()
+	return nil
+}
+
+type IntentGraphStream struct {
+	serverStream
+	responses
+	Audio     *Audio
+	Language  pb.LanguageCode
+	Responses []*pb.IntentGraphResponse
+}
+
+func (s *IntentGraphStream) Recv() (*pb.StreamingIntentGraphRequest, error) {
+	chunk, err := s.Audio.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StreamingIntentGraphRequest{DeviceId: s.Audio.Device, Session: s.Audio.Session, InputAudio: chunk, LanguageCode: s.Language, AudioEncoding: pb.AudioEncoding_LINEAR_PCM}, nil
+}
+
+func (s *IntentGraphStream) Send(resp *pb.IntentGraphResponse) error {
+	s.sent()
+	s.mu.Lock()
+	s.Responses = append(s.Responses, resp)
+	s.mu.Unlock()
+	return nil
+}
+
+type KnowledgeGraphStream struct {
+	serverStream
+	responses
+	Audio     *Audio
+	Language  pb.LanguageCode
+	Responses []*pb.KnowledgeGraphResponse
+}
+
+func (s *KnowledgeGraphStream) Recv() (*pb.StreamingKnowledgeGraphRequest, error) {
+	chunk, err := s.Audio.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StreamingKnowledgeGraphRequest{DeviceId: s.Audio.Device, Session: s.Audio.Session, InputAudio: chunk, LanguageCode: s.Language, AudioEncoding: pb.AudioEncoding_LINEAR_PCM}, nil
+}
+
+func (s *KnowledgeGraphStream) Send(resp *pb.KnowledgeGraphResponse) error {
+	s.sent()
+	s.mu.Lock()
+	s.Responses = append(s.Responses, resp)
+	s.mu.Unlock()
+	return nil
+}
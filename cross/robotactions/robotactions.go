@@ -0,0 +1,96 @@
+package robotactions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fforchino/vector-go-sdk/pkg/vector"
+	"github.com/fforchino/vector-go-sdk/pkg/vectorpb"
+	"github.com/kercre123/wire-pod/chipper/pkg/scripting"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	ttr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/ttr"
+)
+
+// what the pod's features do on robots: the MQTT bridge's commands and the webhooks' responses. they take
+// an Actions so they can be tried without robots.
+
+type Actions interface {
+	Say(esn, text string) error
+	PlayAnimation(esn, name string) error
+	RunLua(esn, script string) error
+}
+
+// Robot does actions on robots through chipper's helpers
+type Robot struct{}
+
+// how long a robot gets to hand over behavior control
+const controlTimeout = 10 * time.Second
+
+func (Robot) Say(esn, text string) error {
+	return withControl(esn, func(robot *vector.Vector) error {
+		return ttr.DoSayText(text, robot)
+	})
+}
+
+func (Robot) PlayAnimation(esn, name string) error {
+	return withControl(esn, func(robot *vector.Vector) error {
+		return ttr.DoPlayAnimation(name, robot)
+	})
+}
+
+// scripts take behavior control themselves
+func (Robot) RunLua(esn, script string) error {
+	return scripting.RunLuaScript(esn, script)
+}
+
+// the robot only speaks and animates for whoever has behavior control, so it's taken for f and given back
+func withControl(esn string, f func(*vector.Vector) error) error {
+	robot, err := vars.GetRobot(esn)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := robot.Conn.BehaviorControl(ctx)
+	if err != nil {
+		return err
+	}
+	err = r.Send(&vectorpb.BehaviorControlRequest{
+		RequestType: &vectorpb.BehaviorControlRequest_ControlRequest{
+			ControlRequest: &vectorpb.ControlRequest{Priority: vectorpb.ControlRequest_OVERRIDE_BEHAVIORS},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	granted := make(chan error, 1)
+	go func() {
+		for {
+			resp, err := r.Recv()
+			if err != nil {
+				granted <- err
+				return
+			}
+			if resp.GetControlGrantedResponse() != nil {
+				granted <- nil
+				return
+			}
+		}
+	}()
+	select {
+	case err := <-granted:
+		if err != nil {
+			return err
+		}
+	case <-time.After(controlTimeout):
+		return errors.New("the robot didn't give behavior control")
+	}
+	err = f(robot)
+	r.Send(&vectorpb.BehaviorControlRequest{
+		RequestType: &vectorpb.BehaviorControlRequest_ControlRelease{
+			ControlRelease: &vectorpb.ControlRelease{},
+		},
+	})
+	return err
+}
@@ -0,0 +1,93 @@
+package robotsim
+
+import (
+	"errors"
+	"time"
+
+	chipperpb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/digital-dream-labs/opus-go/opus"
+)
+
+const (
+	// Ogg Opus, as robots on 1.8 and later send it
+	CodecOpus = "opus"
+	// raw 16 kHz PCM, as older robots send it
+	CodecPCM = "pcm"
+)
+
+// ChunkDuration is how much audio each request carries
+var ChunkDuration = 100 * time.Millisecond
+
+// the encoder settings for Opus
+var (
+	OpusFrameSize  float32 = 60
+	OpusBitrate    uint    = 32000
+	OpusComplexity uint    = 5
+)
+
+// TrailingSilence is streamed after the audio, so the pod's VAD sees the end of speech
+var TrailingSilence = 1500 * time.Millisecond
+
+func encoding(codec string) chipperpb.AudioEncoding {
+	if codec == CodecPCM {
+		return chipperpb.AudioEncoding_LINEAR_PCM
+	}
+	return chipperpb.AudioEncoding_OGG_OPUS
+}
+
+// Chunk is what one request carries, and how much audio that is
+type Chunk struct {
+	Audio    []byte
+	Duration time.Duration
+}
+
+// Chunks splits 16 kHz mono 16-bit PCM, followed by trailing silence, into what is sent in each request
+func Chunks(pcm []byte, codec string) ([]Chunk, error) {
+	all := append(append([]byte{}, pcm...), make([]byte, int(TrailingSilence.Seconds()*16000)*2)...)
+	// whole 2.5 ms opus frames
+	size := int(ChunkDuration.Seconds()*400) * 80
+	if size == 0 {
+		return nil, errors.New("chunk duration too short")
+	}
+	var stream *opus.OggStream
+	switch codec {
+	case CodecOpus:
+		stream = &opus.OggStream{SampleRate: 16000, Channels: 1, FrameSize: OpusFrameSize, Bitrate: OpusBitrate, Complexity: OpusComplexity}
+		// the smallest opus frame is 2.5 ms, 40 samples
+		if rem := len(all) % 80; rem != 0 {
+			all = append(all, make([]byte, 80-rem)...)
+		}
+	case CodecPCM:
+		// a first byte of 0x4f would be taken for the start of an Ogg stream
+		if len(all) > 0 && all[0] == 0x4f {
+			all[0] = 0x4e
+		}
+	default:
+		return nil, errors.New("codec must be opus or pcm")
+	}
+	var chunks []Chunk
+	// audio the encoder held on to, which goes out with the next page
+	var held time.Duration
+	for len(all) > 0 {
+		n := size
+		if n > len(all) {
+			n = len(all)
+		}
+		chunk := Chunk{Audio: all[:n], Duration: held + time.Duration(n/2)*time.Second/16000}
+		all = all[n:]
+		if stream != nil {
+			data, err := stream.EncodeBytes(chunk.Audio)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Audio = append(data, stream.Flush()...)
+			if len(chunk.Audio) == 0 {
+				held = chunk.Duration
+				continue
+			}
+		}
+		held = 0
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,227 @@
+package robotsim
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	chipperpb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/kercre123/WirePod/cross/replay"
+)
+
+const usage = `usage: wire-pod simulate [options] <command> [args]
+
+acts as a robot against a pod, over the same TLS gRPC connection a Vector uses. the pod treats
+it like a real robot, so it shows up in the bot list and gets jdocs and tokens.
+
+commands:
+  voice <file>...          stream recordings as voice requests and print what the pod answered
+  connect                  read the jdocs a robot reads at boot
+  associate                associate with the pod as the primary user, as at setup
+  refresh                  refresh the robot's token
+  jdocs read <name>...     print jdocs
+  jdocs write <name> <json>
+  load <file>              stream a recording from many robots at once and print latencies
+
+recordings are .wav (16 kHz mono 16-bit), .ogg/.opus or raw .pcm.
+
+options:
+`
+
+// RunCLI runs a simulator command against the pod at defaultAddr, unless -addr is given
+func RunCLI(defaultAddr string, args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr, "the pod's chipper server, host:port")
+	ca := fs.String("ca", "", "PEM CA to verify the pod with. without it the certificate isn't checked.")
+	serverName := fs.String("servername", "", "name to verify the pod's certificate against, e.g. escapepod.local")
+	esn := fs.String("esn", "00e20100", "the robot's ESN. for load, the first robot's.")
+	name := fs.String("name", "", "the robot's name, Vector-XXXX from the ESN by default")
+	kind := fs.String("kind", KindIntent, "voice request type: intent, intent_graph or knowledge_graph")
+	codec := fs.String("codec", CodecOpus, "audio encoding: opus (1.8 and later) or pcm (older firmware)")
+	realtime := fs.Bool("realtime", true, "stream audio at the speed a robot would")
+	robots := fs.Int("robots", 10, "load: how many robots")
+	requests := fs.Int("requests", 1, "load: voice requests per robot")
+	connect := fs.Bool("connect", false, "load: read each robot's jdocs first, as at boot")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	robot := NewRobot(*esn)
+	if *name != "" {
+		robot.Name = *name
+	}
+	opts := Options{Addr: *addr, CAFile: *ca, ServerName: *serverName}
+	voice := VoiceRequest{Kind: *kind, Codec: *codec, Realtime: *realtime, Language: chipperpb.LanguageCode_ENGLISH_US}
+	ctx := context.Background()
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	// jdocs and tokens only print as JSON
+	printJSON := func(v interface{}) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+	}
+	fail := func(err error) int {
+		fmt.Fprintln(os.Stderr, "error: "+err.Error())
+		return 1
+	}
+
+	if cmd == "load" {
+		if len(rest) != 1 {
+			fs.Usage()
+			return 2
+		}
+		pcm, err := replay.ReadPCM(rest[0])
+		if err != nil {
+			return fail(err)
+		}
+		voice.PCM = pcm
+		stats := Load(ctx, opts, LoadOptions{Robots: *robots, BaseESN: robot.ESN, Requests: *requests, Voice: voice, Connect: *connect})
+		if *asJSON {
+			printJSON(stats)
+		} else {
+			printLoad(stats)
+		}
+		if stats.Errors > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	c, err := Dial(ctx, robot, opts)
+	if err != nil {
+		return fail(err)
+	}
+	defer c.Close()
+	switch cmd {
+	case "voice":
+		if len(rest) == 0 {
+			fs.Usage()
+			return 2
+		}
+		files, err := replay.Files(rest)
+		if err != nil {
+			return fail(err)
+		}
+		failed := 0
+		for _, file := range files {
+			pcm, err := replay.ReadPCM(file)
+			if err == nil {
+				voice.PCM = pcm
+				var res VoiceResult
+				res, err = c.Voice(ctx, voice)
+				if err == nil {
+					if *asJSON {
+						printJSON(res)
+					} else {
+						printVoice(file, res)
+					}
+					continue
+				}
+			}
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+		}
+		if failed > 0 {
+			return 1
+		}
+	case "connect":
+		resp, err := c.ReadDocs(ctx, StartupDocs...)
+		if err != nil {
+			return fail(err)
+		}
+		printJSON(resp)
+	case "associate":
+		bundle, err := c.Associate(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		// the robot reads its tokens next, which is when the pod stores the session certificate
+		if _, err := c.ReadDocs(ctx, "vic.AppTokens"); err != nil {
+			return fail(err)
+		}
+		printJSON(bundle)
+	case "refresh":
+		bundle, err := c.RefreshToken(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		printJSON(bundle)
+	case "jdocs":
+		switch {
+		case len(rest) > 1 && rest[0] == "read":
+			resp, err := c.ReadDocs(ctx, rest[1:]...)
+			if err != nil {
+				return fail(err)
+			}
+			printJSON(resp)
+		case len(rest) == 3 && rest[0] == "write":
+			if !json.Valid([]byte(rest[2])) {
+				return fail(fmt.Errorf("%q isn't JSON", rest[2]))
+			}
+			version := uint64(0)
+			if read, err := c.ReadDocs(ctx, rest[1]); err == nil && len(read.Items) > 0 {
+				version = read.Items[0].GetDoc().GetDocVersion()
+			}
+			resp, err := c.WriteDoc(ctx, rest[1], rest[2], version)
+			if err != nil {
+				return fail(err)
+			}
+			printJSON(resp)
+		default:
+			fs.Usage()
+			return 2
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
+
+func printVoice(file string, res VoiceResult) {
+	fmt.Println(file)
+	fmt.Printf("  heard:    %q\n", res.Transcript)
+	fmt.Printf("  intent:   %s\n", res.Intent)
+	if len(res.Params) > 0 {
+		var pairs []string
+		for k, v := range res.Params {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		fmt.Printf("  params:   %s\n", strings.Join(pairs, ", "))
+	}
+	if res.Spoken != "" {
+		fmt.Printf("  spoken:   %s\n", res.Spoken)
+	}
+	fmt.Printf("  timing:   %d chunks, answered after %d ms, done after %d ms\n", res.Chunks, res.FirstResponse.Milliseconds(), res.Total.Milliseconds())
+}
+
+func printLoad(s LoadStats) {
+	fmt.Printf("%d requests, %d errors in %s\n", s.Requests, s.Errors, s.Time.Round(time.Millisecond))
+	fmt.Printf("first answer: min %d ms, median %d ms, p95 %d ms, max %d ms\n", s.Min.Milliseconds(), s.Median.Milliseconds(), s.P95.Milliseconds(), s.Max.Milliseconds())
+	var intents []string
+	for intent := range s.Intents {
+		intents = append(intents, intent)
+	}
+	sort.Strings(intents)
+	for _, intent := range intents {
+		fmt.Printf("  %s: %d\n", intent, s.Intents[intent])
+	}
+	for _, e := range s.ErrorSamples {
+		fmt.Println("  error: " + e)
+	}
+}
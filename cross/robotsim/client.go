@@ -0,0 +1,119 @@
+package robotsim
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	chipperpb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/digital-dream-labs/api/go/jdocspb"
+	"github.com/digital-dream-labs/api/go/tokenpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// the robot side of the pod's gRPC services (chipper, jdocs, token), for exercising a pod without a Vector.
+// a simulated robot is treated like a real one: it shows up in the bot list and gets jdocs and tokens.
+
+// Robot is who a simulated robot claims to be
+type Robot struct {
+	// 8 hex digits, e.g. 00e20145
+	ESN string
+	// e.g. Vector-R2D2, used for the session certificate
+	Name string
+	// the client token the robot was given, if any
+	GUID string
+}
+
+// NewRobot makes a robot with the ESN, and a name derived from it
+func NewRobot(esn string) Robot {
+	esn = strings.ToLower(esn)
+	name := esn
+	if len(name) > 4 {
+		name = name[len(name)-4:]
+	}
+	return Robot{ESN: esn, Name: "Vector-" + strings.ToUpper(name)}
+}
+
+// Thing is how jdocs refers to the robot
+func (r Robot) Thing() string {
+	return "vic:" + r.ESN
+}
+
+type Options struct {
+	// host:port of the pod's chipper server
+	Addr string
+	// PEM CA to verify the pod with. empty skips verification, as pods mostly have self-signed certificates.
+	CAFile string
+	// the name to verify the certificate against. defaults to the host in Addr.
+	ServerName  string
+	DialTimeout time.Duration
+}
+
+// Client is one robot's connection to a pod
+type Client struct {
+	Robot   Robot
+	conn    *grpc.ClientConn
+	chipper chipperpb.ChipperGrpcClient
+	jdocs   jdocspb.JdocsClient
+	token   tokenpb.TokenClient
+}
+
+func tlsConfig(opts Options) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: opts.ServerName}
+	if conf.ServerName == "" {
+		host, _, err := net.SplitHostPort(opts.Addr)
+		if err != nil {
+			return nil, err
+		}
+		conf.ServerName = host
+	}
+	if opts.CAFile == "" {
+		conf.InsecureSkipVerify = true
+		return conf, nil
+	}
+	pem, err := os.ReadFile(opts.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates in " + opts.CAFile)
+	}
+	conf.RootCAs = pool
+	return conf, nil
+}
+
+// Dial connects to the pod as robot
+func Dial(ctx context.Context, robot Robot, opts Options) (*Client, error) {
+	conf, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, opts.DialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, opts.Addr, grpc.WithTransportCredentials(credentials.NewTLS(conf)), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %s", opts.Addr, err)
+	}
+	return &Client{
+		Robot:   robot,
+		conn:    conn,
+		chipper: chipperpb.NewChipperGrpcClient(conn),
+		jdocs:   jdocspb.NewJdocsClient(conn),
+		token:   tokenpb.NewTokenClient(conn),
+	}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
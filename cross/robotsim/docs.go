@@ -0,0 +1,96 @@
+package robotsim
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/digital-dream-labs/api/go/jdocspb"
+	"github.com/digital-dream-labs/api/go/tokenpb"
+)
+
+// the jdocs a robot reads when it connects
+var StartupDocs = []string{"vic.AppTokens", "vic.RobotSettings", "vic.RobotLifetimeStats", "vic.AccountSettings", "vic.UserEntitlements"}
+
+// ReadDocs reads the robot's jdocs. reading vic.AppTokens is what makes the pod note a new robot.
+func (c *Client) ReadDocs(ctx context.Context, names ...string) (*jdocspb.ReadDocsResp, error) {
+	req := &jdocspb.ReadDocsReq{Thing: c.Robot.Thing()}
+	for _, name := range names {
+		req.Items = append(req.Items, &jdocspb.ReadDocsReq_Item{DocName: name})
+	}
+	return c.jdocs.ReadDocs(ctx, req)
+}
+
+// WriteDoc writes one of the robot's jdocs, e.g. vic.RobotSettings
+func (c *Client) WriteDoc(ctx context.Context, name, jsonDoc string, version uint64) (*jdocspb.WriteDocResp, error) {
+	return c.jdocs.WriteDoc(ctx, &jdocspb.WriteDocReq{
+		Thing:   c.Robot.Thing(),
+		DocName: name,
+		Doc:     &jdocspb.Jdoc{DocVersion: version, FmtVersion: 1, JsonDoc: jsonDoc},
+	})
+}
+
+// SessionCertificate makes a throwaway certificate like the one robots hand over when they are
+// associated with a user. the pod names the robot after its common name.
+func (r Robot) SessionCertificate() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: r.Name},
+		DNSNames:     []string{r.Name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// Associate does what a robot does when set up with the pod as its primary user, and keeps the client token it is given
+func (c *Client) Associate(ctx context.Context) (*tokenpb.TokenBundle, error) {
+	cert, err := c.Robot.SessionCertificate()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.token.AssociatePrimaryUser(ctx, &tokenpb.AssociatePrimaryUserRequest{SessionCertificate: cert})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.GetClientToken() != "" {
+		c.Robot.GUID = resp.Data.ClientToken
+	}
+	return resp.Data, nil
+}
+
+// AssociateSecondary is what happens when an SDK or app client asks the robot for a token
+func (c *Client) AssociateSecondary(ctx context.Context, clientName string) (*tokenpb.TokenBundle, error) {
+	resp, err := c.token.AssociateSecondaryClient(ctx, &tokenpb.AssociateSecondaryClientRequest{ClientName: clientName})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// RefreshToken is what robots do before their token expires
+func (c *Client) RefreshToken(ctx context.Context) (*tokenpb.TokenBundle, error) {
+	resp, err := c.token.RefreshToken(ctx, &tokenpb.RefreshTokenRequest{RefreshJwtTokens: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
@@ -0,0 +1,114 @@
+package robotsim
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type LoadOptions struct {
+	// how many robots, each with its own connection. their ESNs count up from BaseESN.
+	Robots  int
+	BaseESN string
+	// voice requests per robot
+	Requests int
+	Voice    VoiceRequest
+	// connect each robot the way it does at boot (reading its jdocs) before the voice requests
+	Connect bool
+}
+
+type LoadStats struct {
+	Requests int `json:"requests"`
+	// failed requests, and failed jdocs reads when connecting
+	Errors int `json:"errors"`
+	// how many answers had each intent
+	Intents map[string]int `json:"intents"`
+	// time to the first answer
+	Min    time.Duration `json:"min"`
+	Median time.Duration `json:"median"`
+	P95    time.Duration `json:"p95"`
+	Max    time.Duration `json:"max"`
+	Time   time.Duration `json:"time"`
+	// the first few errors, to say what went wrong
+	ErrorSamples []string `json:"errorsamples,omitempty"`
+}
+
+// RobotESN is the ESN of the nth robot in a load test
+func RobotESN(base string, n int) string {
+	b, err := strconv.ParseUint(base, 16, 32)
+	if err != nil {
+		b = 0
+	}
+	return fmt.Sprintf("%08x", uint32(b)+uint32(n))
+}
+
+// Load runs voice requests from many robots at once
+func Load(ctx context.Context, opts Options, load LoadOptions) LoadStats {
+	if load.Robots < 1 {
+		load.Robots = 1
+	}
+	if load.Requests < 1 {
+		load.Requests = 1
+	}
+	var mu sync.Mutex
+	stats := LoadStats{Intents: map[string]int{}}
+	var latencies []time.Duration
+	fail := func(err error) {
+		mu.Lock()
+		stats.Errors++
+		if len(stats.ErrorSamples) < 5 {
+			stats.ErrorSamples = append(stats.ErrorSamples, err.Error())
+		}
+		mu.Unlock()
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < load.Robots; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := Dial(ctx, NewRobot(RobotESN(load.BaseESN, i)), opts)
+			if err != nil {
+				mu.Lock()
+				stats.Requests += load.Requests
+				mu.Unlock()
+				for j := 0; j < load.Requests; j++ {
+					fail(err)
+				}
+				return
+			}
+			defer c.Close()
+			if load.Connect {
+				if _, err := c.ReadDocs(ctx, StartupDocs...); err != nil {
+					fail(fmt.Errorf("reading jdocs: %s", err))
+				}
+			}
+			for j := 0; j < load.Requests; j++ {
+				res, err := c.Voice(ctx, load.Voice)
+				mu.Lock()
+				stats.Requests++
+				if err == nil {
+					stats.Intents[res.Intent]++
+					latencies = append(latencies, res.FirstResponse)
+				}
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats.Time = time.Since(start)
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.Min = latencies[0]
+		stats.Median = latencies[len(latencies)/2]
+		stats.P95 = latencies[(len(latencies)*95-1)/100]
+		stats.Max = latencies[len(latencies)-1]
+	}
+	return stats
+}
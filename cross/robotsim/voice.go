@@ -0,0 +1,267 @@
+package robotsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	chipperpb "github.com/digital-dream-labs/api/go/chipperpb"
+)
+
+// the kinds of voice request, one per streaming call
+const (
+	KindIntent         = "intent"
+	KindIntentGraph    = "intent_graph"
+	KindKnowledgeGraph = "knowledge_graph"
+)
+
+// FirmwareVersion is sent with voice requests
+var FirmwareVersion = "2.0.1.6091"
+
+type VoiceRequest struct {
+	Kind string
+	// 16 kHz mono 16-bit PCM
+	PCM   []byte
+	Codec string
+	// sleep for each chunk's length, as a robot streaming its microphone would
+	Realtime bool
+	Language chipperpb.LanguageCode
+	// how long to wait for an answer after the last chunk. defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+type VoiceResult struct {
+	Kind       string            `json:"kind"`
+	Session    string            `json:"session"`
+	Transcript string            `json:"transcript"`
+	Intent     string            `json:"intent"`
+	Params     map[string]string `json:"params,omitempty"`
+	Spoken     string            `json:"spoken,omitempty"`
+	Chunks     int               `json:"chunks"`
+	// from the first chunk until the pod's first answer
+	FirstResponse time.Duration `json:"firstresponse"`
+	// from the first chunk until the stream ended
+	Total time.Duration `json:"total"`
+}
+
+// the robot side of one streaming call
+type voiceStream interface {
+	send(chunk []byte) error
+	recv(res *VoiceResult) (final bool, err error)
+	CloseSend() error
+}
+
+type intentStream struct {
+	chipperpb.ChipperGrpc_StreamingIntentClient
+	req *chipperpb.StreamingIntentRequest
+}
+
+func (s intentStream) send(chunk []byte) error {
+	s.req.InputAudio = chunk
+	return s.Send(s.req)
+}
+
+func (s intentStream) recv(res *VoiceResult) (bool, error) {
+	resp, err := s.Recv()
+	if err != nil {
+		return false, err
+	}
+	res.intent(resp.GetIntentResult())
+	return resp.IsFinal, nil
+}
+
+type intentGraphStream struct {
+	chipperpb.ChipperGrpc_StreamingIntentGraphClient
+	req *chipperpb.StreamingIntentGraphRequest
+}
+
+func (s intentGraphStream) send(chunk []byte) error {
+	s.req.InputAudio = chunk
+	return s.Send(s.req)
+}
+
+func (s intentGraphStream) recv(res *VoiceResult) (bool, error) {
+	resp, err := s.Recv()
+	if err != nil {
+		return false, err
+	}
+	res.intent(resp.GetIntentResult())
+	if resp.QueryText != "" {
+		res.Transcript = resp.QueryText
+	}
+	res.Spoken += resp.SpokenText
+	return resp.IsFinal, nil
+}
+
+type knowledgeGraphStream struct {
+	chipperpb.ChipperGrpc_StreamingKnowledgeGraphClient
+	req *chipperpb.StreamingKnowledgeGraphRequest
+}
+
+func (s knowledgeGraphStream) send(chunk []byte) error {
+	s.req.InputAudio = chunk
+	return s.Send(s.req)
+}
+
+// knowledge graph answers have no final flag, so the first one ends the request
+func (s knowledgeGraphStream) recv(res *VoiceResult) (bool, error) {
+	resp, err := s.Recv()
+	if err != nil {
+		return false, err
+	}
+	res.Intent = "knowledge_graph"
+	res.Transcript = resp.QueryText
+	res.Spoken += resp.SpokenText
+	return true, nil
+}
+
+func (r *VoiceResult) intent(res *chipperpb.IntentResult) {
+	if res == nil {
+		return
+	}
+	r.Transcript = res.QueryText
+	r.Intent = res.Action
+	r.Params = nil
+	for k, v := range res.Parameters {
+		if k == "" && v == "" {
+			continue
+		}
+		if r.Params == nil {
+			r.Params = map[string]string{}
+		}
+		r.Params[k] = v
+	}
+}
+
+func (c *Client) openVoice(ctx context.Context, req VoiceRequest, session string) (voiceStream, error) {
+	esn := c.Robot.ESN
+	enc := encoding(req.Codec)
+	switch req.Kind {
+	case KindIntent, "":
+		stream, err := c.chipper.StreamingIntent(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return intentStream{stream, &chipperpb.StreamingIntentRequest{
+			Session: session, DeviceId: esn, LanguageCode: req.Language, FirmwareVersion: FirmwareVersion,
+			Mode: chipperpb.RobotMode_VOICE_COMMAND, AudioEncoding: enc, SingleUtterance: true,
+		}}, nil
+	case KindIntentGraph:
+		stream, err := c.chipper.StreamingIntentGraph(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return intentGraphStream{stream, &chipperpb.StreamingIntentGraphRequest{
+			Session: session, DeviceId: esn, LanguageCode: req.Language, FirmwareVersion: FirmwareVersion,
+			Mode: chipperpb.RobotMode_VOICE_COMMAND, AudioEncoding: enc, SingleUtterance: true,
+		}}, nil
+	case KindKnowledgeGraph:
+		stream, err := c.chipper.StreamingKnowledgeGraph(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return knowledgeGraphStream{stream, &chipperpb.StreamingKnowledgeGraphRequest{
+			Session: session, DeviceId: esn, LanguageCode: req.Language, FirmwareVersion: FirmwareVersion,
+			AudioEncoding: enc,
+		}}, nil
+	}
+	return nil, fmt.Errorf("kind must be %s, %s or %s", KindIntent, KindIntentGraph, KindKnowledgeGraph)
+}
+
+// Voice streams audio to the pod the way a robot does after its wake word, until the pod answers
+func (c *Client) Voice(ctx context.Context, req VoiceRequest) (VoiceResult, error) {
+	if req.Codec == "" {
+		req.Codec = CodecOpus
+	}
+	if req.Timeout == 0 {
+		req.Timeout = 10 * time.Second
+	}
+	res := VoiceResult{Kind: req.Kind, Session: strconv.FormatInt(time.Now().UnixNano(), 36)}
+	if res.Kind == "" {
+		res.Kind = KindIntent
+	}
+	chunks, err := Chunks(req.PCM, req.Codec)
+	if err != nil {
+		return res, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.openVoice(ctx, req, res.Session)
+	if err != nil {
+		return res, err
+	}
+	start := time.Now()
+	// the robot stops streaming once it has an answer
+	answered := make(chan struct{})
+	sent := make(chan error, 1)
+	go func() {
+		defer close(sent)
+		for _, chunk := range chunks {
+			select {
+			case <-answered:
+				return
+			default:
+			}
+			if err := stream.send(chunk.Audio); err != nil {
+				// the pod ended the stream, the answer says why
+				if err != io.EOF {
+					sent <- err
+				}
+				return
+			}
+			res.Chunks++
+			if req.Realtime {
+				select {
+				case <-answered:
+					return
+				case <-time.After(chunk.Duration):
+				}
+			}
+		}
+		stream.CloseSend()
+	}()
+	timeout := time.AfterFunc(req.Timeout+audioLength(chunks), cancel)
+	defer timeout.Stop()
+	var recvErr error
+	for {
+		final, err := stream.recv(&res)
+		if err != nil {
+			if err != io.EOF {
+				recvErr = err
+			}
+			break
+		}
+		if res.FirstResponse == 0 {
+			res.FirstResponse = time.Since(start)
+		}
+		if final {
+			break
+		}
+	}
+	close(answered)
+	res.Total = time.Since(start)
+	sendErr := <-sent
+	stream.CloseSend()
+	switch {
+	case recvErr != nil && ctx.Err() != nil:
+		return res, errors.New("no answer from the pod in time")
+	case recvErr != nil:
+		return res, recvErr
+	case res.FirstResponse == 0 && sendErr != nil:
+		return res, sendErr
+	case res.FirstResponse == 0:
+		return res, errors.New("the pod ended the request without an answer")
+	}
+	return res, nil
+}
+
+func audioLength(chunks []Chunk) time.Duration {
+	var d time.Duration
+	for _, c := range chunks {
+		d += c.Duration
+	}
+	return d
+}
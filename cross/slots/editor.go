@@ -0,0 +1,16 @@
+package slots
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed editor.html
+var editorPage []byte
+
+// ServeEditor is a custom intent editor which checks templated utterances as they are typed. intents are
+// saved through chipper's /api/ custom intent endpoints, their webhooks through /api-chipper/set_webhook.
+func ServeEditor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(editorPage)
+}
@@ -0,0 +1,240 @@
+package slots
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kercre123/WirePod/cross/webhook"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// {name} in exec arguments and the fixed parameter value is replaced with the slot's value
+var placeholder = regexp.MustCompile(`\{([\pL\pN_]+)\}`)
+
+// Match is a custom intent one of whose templated utterances fit what was heard
+type Match struct {
+	Intent    vars.CustomIntent `json:"intent"`
+	Utterance string            `json:"utterance"`
+	Values    map[string]string `json:"values"`
+}
+
+// Matcher keeps parsed templates, as custom intents can change while the pod runs
+type Matcher struct {
+	// custom intents with a webhook are matched here on their plain utterances too, the way chipper
+	// would, as chipper can't call the webhook. nil has none.
+	Webhooks *webhook.Hooks
+	// says what a webhook answered. nil doesn't.
+	Say func(esn, text string) error
+
+	mu     sync.Mutex
+	parsed map[string]parsed
+}
+
+type parsed struct {
+	t   *Template
+	err error
+}
+
+func (m *Matcher) template(utterance string) (*Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.parsed[utterance]; ok {
+		return p.t, p.err
+	}
+	t, err := Parse(utterance)
+	if m.parsed == nil {
+		m.parsed = make(map[string]parsed)
+	}
+	m.parsed[utterance] = parsed{t, err}
+	return t, err
+}
+
+// HasTemplates says whether any custom intent has a templated utterance
+func HasTemplates(intents []vars.CustomIntent) bool {
+	for _, c := range intents {
+		for _, u := range c.Utterances {
+			if IsTemplate(u) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// whether any custom intent is for the matcher rather than chipper
+func (m *Matcher) wants(intents []vars.CustomIntent) bool {
+	if HasTemplates(intents) {
+		return true
+	}
+	for _, c := range intents {
+		if _, ok := m.Webhooks.For(c.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match finds the first custom intent with a templated utterance that fits text, or with a webhook and
+// a plain utterance in text, in the order the intents are in. utterances with problems are passed over.
+func (m *Matcher) Match(intents []vars.CustomIntent, text string) (Match, bool) {
+	for _, c := range intents {
+		_, hooked := m.Webhooks.For(c.Name)
+		for _, u := range c.Utterances {
+			if !IsTemplate(u) {
+				if hooked && plainMatch(c, u, text) {
+					return Match{Intent: c, Utterance: u, Values: map[string]string{}}, true
+				}
+				continue
+			}
+			t, err := m.template(u)
+			if err != nil {
+				continue
+			}
+			if values, ok := t.Match(text); ok {
+				return Match{Intent: c, Utterance: u, Values: values}, true
+			}
+		}
+	}
+	return Match{}, false
+}
+
+// as chipper matches: anywhere in the text, and * matches anything for system intents
+func plainMatch(c vars.CustomIntent, utterance, text string) bool {
+	u := strings.ToLower(strings.TrimSpace(utterance))
+	if u == "" {
+		return false
+	}
+	return (c.IsSystemIntent && strings.HasPrefix(u, "*")) || strings.Contains(strings.ToLower(text), u)
+}
+
+// Validate lists what's wrong with a custom intent's templated utterances and the slots it refers to
+func Validate(c vars.CustomIntent) []string {
+	var problems []string
+	names := map[string]bool{}
+	templated, broken := false, false
+	for _, u := range c.Utterances {
+		if !IsTemplate(u) {
+			continue
+		}
+		templated = true
+		t, err := Parse(u)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("utterance %q: %s", strings.TrimSpace(u), err))
+			broken = true
+			continue
+		}
+		for _, s := range t.Slots {
+			names[s.Name] = true
+		}
+	}
+	// slots in a broken utterance aren't known, so what refers to them can't be checked
+	if !templated || broken {
+		return problems
+	}
+	unknown := func(where, s string) {
+		for _, ref := range placeholder.FindAllStringSubmatch(s, -1) {
+			if !names[ref[1]] {
+				problems = append(problems, fmt.Sprintf("%s uses {%s}, which no utterance has", where, ref[1]))
+			}
+		}
+	}
+	for _, arg := range c.ExecArgs {
+		unknown(fmt.Sprintf("exec argument %q", arg), arg)
+	}
+	unknown("the parameter value", c.Params.ParamValue)
+	return problems
+}
+
+// Params is the intent's parameters: each slot's value by its name, then the fixed parameter with its
+// slots filled in
+func (m Match) Params() map[string]string {
+	params := map[string]string{}
+	for k, v := range m.Values {
+		params[k] = v
+	}
+	if m.Intent.Params.ParamName != "" {
+		params[m.Intent.Params.ParamName] = m.fill(m.Intent.Params.ParamValue)
+	}
+	return params
+}
+
+// ExecArgs is the intent's exec arguments with slots filled in. the ones chipper fills in (!botSerial,
+// !speechText, !intentName, !locale) are as chipper does them, and !slots is every value as JSON.
+func (m Match) ExecArgs(esn, text, locale string) []string {
+	var args []string
+	for _, arg := range m.Intent.ExecArgs {
+		switch arg {
+		case "!botSerial":
+			arg = esn
+		case "!speechText":
+			arg = "\"" + text + "\""
+		case "!intentName":
+			arg = m.Intent.Name
+		case "!locale":
+			arg = locale
+		case "!slots":
+			values, _ := json.Marshal(m.Values)
+			arg = string(values)
+		default:
+			arg = m.fill(arg)
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// LuaScript is the intent's script with a slots table before it, so it can read slots.room
+func (m Match) LuaScript() string {
+	var names []string
+	for k := range m.Values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("slots = {")
+	for i, k := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "[%s] = %s", luaQuote(k), luaQuote(m.Values[k]))
+	}
+	b.WriteString("}\n")
+	b.WriteString(m.Intent.LuaScript)
+	return b.String()
+}
+
+// slots this utterance didn't have are left empty
+func (m Match) fill(s string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(ref string) string {
+		return m.Values[ref[1:len(ref)-1]]
+	})
+}
+
+// a Lua string literal. UTF-8 goes through as it is, Lua strings are bytes.
+func luaQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 {
+				fmt.Fprintf(&b, `\%03d`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package slots
+
+import (
+	"strconv"
+)
+
+// numbers as English STT engines give them, in digits or words
+
+var units = map[string]float64{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+	"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16,
+	"seventeen": 17, "eighteen": 18, "nineteen": 19,
+}
+
+var tens = map[string]float64{
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+}
+
+// ParseNumber reads a number that takes up all of words: "42", "2.5", "twenty five", "a hundred and ten"
+func ParseNumber(words []string) (float64, bool) {
+	if len(words) == 0 {
+		return 0, false
+	}
+	if len(words) == 1 {
+		if n, err := strconv.ParseFloat(words[0], 64); err == nil {
+			return n, true
+		}
+	}
+	var total, current float64
+	// what the last word was, to catch "five twenty" and a dangling "and"
+	last := ""
+	for i, w := range words {
+		switch {
+		case units[w] != 0 || w == "zero":
+			if last == "unit" || last == "teen" || (last == "tens" && units[w] >= 10) {
+				return 0, false
+			}
+			current += units[w]
+			last = "unit"
+			if units[w] >= 10 {
+				last = "teen"
+			}
+		case tens[w] != 0:
+			if last == "unit" || last == "teen" || last == "tens" {
+				return 0, false
+			}
+			current += tens[w]
+			last = "tens"
+		case w == "hundred":
+			if last == "" || last == "and" || last == "hundred" {
+				return 0, false
+			}
+			current *= 100
+			last = "hundred"
+		case w == "thousand":
+			if last == "" || last == "and" || last == "thousand" {
+				return 0, false
+			}
+			total += current * 1000
+			current = 0
+			last = "thousand"
+		case w == "a" || w == "an":
+			// "a hundred"
+			if i != 0 || len(words) == 1 || (words[1] != "hundred" && words[1] != "thousand") {
+				return 0, false
+			}
+			current = 1
+			last = "unit"
+		case w == "and":
+			if last != "hundred" && last != "thousand" {
+				return 0, false
+			}
+			last = "and"
+		default:
+			return 0, false
+		}
+	}
+	if last == "and" {
+		return 0, false
+	}
+	return total + current, true
+}
+
+var durationUnits = map[string]float64{
+	"second": 1, "seconds": 1, "sec": 1, "secs": 1,
+	"minute": 60, "minutes": 60, "min": 60, "mins": 60,
+	"hour": 3600, "hours": 3600, "hr": 3600, "hrs": 3600,
+}
+
+// ParseDuration reads a duration in seconds that takes up all of words: "10 minutes", "an hour and a half",
+// "half an hour", "1 hour 30 minutes"
+func ParseDuration(words []string) (float64, bool) {
+	var secs float64
+	parts := 0
+	for len(words) > 0 {
+		if parts > 0 && words[0] == "and" {
+			words = words[1:]
+		}
+		// "half an hour"
+		if len(words) >= 3 && words[0] == "half" && (words[1] == "a" || words[1] == "an") && durationUnits[words[2]] != 0 {
+			secs += durationUnits[words[2]] / 2
+			words = words[3:]
+			parts++
+			continue
+		}
+		unit := -1
+		for i, w := range words {
+			if durationUnits[w] != 0 {
+				unit = i
+				break
+			}
+		}
+		if unit < 1 {
+			return 0, false
+		}
+		var n float64
+		if unit == 1 && (words[0] == "a" || words[0] == "an") {
+			n = 1
+		} else {
+			var ok bool
+			if n, ok = ParseNumber(words[:unit]); !ok {
+				return 0, false
+			}
+		}
+		per := durationUnits[words[unit]]
+		secs += n * per
+		words = words[unit+1:]
+		parts++
+		// "an hour and a half"
+		if len(words) >= 3 && words[0] == "and" && words[1] == "a" && words[2] == "half" {
+			secs += per / 2
+			words = words[3:]
+		}
+	}
+	return secs, parts > 0 && secs > 0
+}
+
+// FormatNumber is how numbers are passed on: digits, with no decimals for whole numbers
+func FormatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
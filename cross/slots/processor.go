@@ -0,0 +1,198 @@
+package slots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/webhook"
+	"github.com/kercre123/wire-pod/chipper/pkg/scripting"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	"github.com/kercre123/wire-pod/chipper/pkg/vtt"
+	sr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/speechrequest"
+	ttr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/ttr"
+)
+
+var slotsLog = logs.For("slots")
+
+// Slots answers voice requests whose transcript fits a templated custom intent, or one with a webhook,
+// before chipper's own matching. it runs speech-to-text itself, so the STT handler the voice processor has must be wrapped
+// with WrapSTT to hand the transcript on when there is no match, and given to SetSTT once its engine is initialized.
+type Slots struct {
+	Matcher
+
+	mu    sync.Mutex
+	stt   func(sr.SpeechRequest) (string, error)
+	heard map[string]heard
+}
+
+type heard struct {
+	text string
+	err  error
+}
+
+// WrapSTT gives the voice processor the transcripts Processor already got. handlers of the intent-only
+// kind (Rhino) are returned as they are. it doesn't change what Processor listens with, see SetSTT.
+func (s *Slots) WrapSTT(handler interface{}) interface{} {
+	stt, ok := handler.(func(sr.SpeechRequest) (string, error))
+	if !ok {
+		return handler
+	}
+	return func(req sr.SpeechRequest) (string, error) {
+		if h, ok := s.take(req.Session); ok {
+			return h.text, h.err
+		}
+		return stt(req)
+	}
+}
+
+// SetSTT is what Processor listens with, the handler of an initialized engine. slots aren't matched with
+// handlers of the intent-only kind.
+func (s *Slots) SetSTT(handler interface{}) {
+	stt, _ := handler.(func(sr.SpeechRequest) (string, error))
+	s.mu.Lock()
+	s.stt = stt
+	s.mu.Unlock()
+}
+
+func (s *Slots) currentSTT() func(sr.SpeechRequest) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stt
+}
+
+func (s *Slots) keep(session string, h heard) {
+	s.mu.Lock()
+	if s.heard == nil {
+		s.heard = make(map[string]heard)
+	}
+	s.heard[session] = h
+	s.mu.Unlock()
+}
+
+func (s *Slots) take(session string) (heard, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.heard[session]
+	delete(s.heard, session)
+	return h, ok
+}
+
+// listens to the request and answers it if a template fits, otherwise keeps the transcript for the
+// voice processor
+func (s *Slots) intercept(req interface{}) bool {
+	stt := s.currentSTT()
+	if stt == nil || !s.wants(vars.CustomIntents) {
+		return false
+	}
+	speechReq := sr.ReqToSpeechRequest(req)
+	text, err := stt(speechReq)
+	if err == nil {
+		if m, ok := s.Match(vars.CustomIntents, text); ok && s.Answer(req, m, text, speechReq.Device) {
+			return true
+		}
+	}
+	s.keep(speechReq.Session, heard{text, err})
+	return false
+}
+
+// Answer does what chipper does for a matched custom intent, with the slots filled in: runs its Lua script
+// and command, and sends the robot the intent with the slots as parameters. then its webhook is called, if
+// it has one. a system intent whose command doesn't say ok isn't answered, and false is returned.
+func (m *Matcher) Answer(req interface{}, match Match, text, esn string) bool {
+	c := match.Intent
+	text = strings.ToLower(text)
+	log := slotsLog.Robot(esn)
+	log.Info(fmt.Sprintf("Custom intent %s matched %q: %v", c.Name, match.Utterance, match.Values))
+	if c.LuaScript != "" {
+		go func() {
+			if err := scripting.RunLuaScript(esn, match.LuaScript()); err != nil {
+				log.Warn("Error running Lua script of " + c.Name + ": " + err.Error())
+			}
+		}()
+	}
+	var out bytes.Buffer
+	if c.Exec != "" {
+		var stderr bytes.Buffer
+		cmd := exec.Command(c.Exec, match.ExecArgs(esn, text, vars.APIConfig.STT.Language)...)
+		cmd.Stdout, cmd.Stderr = &out, &stderr
+		if err := cmd.Run(); err != nil {
+			log.Warn("Error running " + c.Exec + ": " + err.Error() + ": " + strings.TrimSpace(stderr.String()))
+		}
+		log.Debug("Exec output: " + strings.TrimSpace(out.String()))
+	}
+	intent := c.Intent
+	if c.IsSystemIntent {
+		var resp struct {
+			Status       string `json:"status"`
+			ReturnIntent string `json:"returnIntent"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &resp); err != nil || resp.Status != "ok" {
+			log.Warn("System intent " + c.Name + " didn't return ok")
+			return false
+		}
+		intent = resp.ReturnIntent
+	}
+	params := match.Params()
+	ttr.IntentPass(req, intent, text, params, len(params) > 0)
+	if hook, ok := m.Webhooks.For(c.Name); ok {
+		// the robot has its answer already, retries don't hold it up
+		go m.callWebhook(hook, match, intent, text, esn)
+	}
+	return true
+}
+
+func (m *Matcher) callWebhook(hook webhook.Hook, match Match, intent, text, esn string) {
+	log := slotsLog.Robot(esn)
+	res := webhook.Send(context.Background(), hook, webhook.Payload{
+		Time:        time.Now(),
+		ESN:         esn,
+		Intent:      match.Intent.Name,
+		RobotIntent: intent,
+		Transcript:  text,
+		Slots:       match.Values,
+		Locale:      vars.APIConfig.STT.Language,
+	})
+	if res.Error != "" {
+		log.Warn(fmt.Sprintf("Webhook of %s: %s (tried %d times)", match.Intent.Name, res.Error, res.Attempts))
+	} else {
+		log.Debug(fmt.Sprintf("Webhook of %s answered %d", match.Intent.Name, res.Status))
+	}
+	if res.Say != "" && m.Say != nil {
+		if err := m.Say(esn, res.Say); err != nil {
+			log.Warn("Error saying what the webhook of " + match.Intent.Name + " answered: " + err.Error())
+		}
+	}
+}
+
+// Processor goes in front of the voice processor, see Slots
+type Processor struct {
+	Next  history.VoiceProcessor
+	Slots *Slots
+}
+
+func (p Processor) ProcessIntent(req *vtt.IntentRequest) (*vtt.IntentResponse, error) {
+	if p.Slots.intercept(req) {
+		return nil, nil
+	}
+	return p.Next.ProcessIntent(req)
+}
+
+func (p Processor) ProcessIntentGraph(req *vtt.IntentGraphRequest) (*vtt.IntentGraphResponse, error) {
+	if p.Slots.intercept(req) {
+		return nil, nil
+	}
+	return p.Next.ProcessIntentGraph(req)
+}
+
+// knowledge graph requests are questions, not commands
+func (p Processor) ProcessKnowledgeGraph(req *vtt.KnowledgeGraphRequest) (*vtt.KnowledgeGraphResponse, error) {
+	return p.Next.ProcessKnowledgeGraph(req)
+}
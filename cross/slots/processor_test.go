@@ -0,0 +1,67 @@
+package slots
+
+import (
+	"sync"
+	"testing"
+
+	sr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/speechrequest"
+)
+
+func sttSaying(text string) func(sr.SpeechRequest) (string, error) {
+	return func(sr.SpeechRequest) (string, error) { return text, nil }
+}
+
+func heardWith(s *Slots) string {
+	stt := s.currentSTT()
+	if stt == nil {
+		return ""
+	}
+	text, _ := stt(sr.SpeechRequest{})
+	return text
+}
+
+// a new engine's handler is wrapped before its init runs, matching keeps the old one until SetSTT
+func TestWrapSTTKeepsListening(t *testing.T) {
+	s := &Slots{}
+	s.SetSTT(sttSaying("old"))
+	wrapped, ok := s.WrapSTT(sttSaying("new")).(func(sr.SpeechRequest) (string, error))
+	if !ok {
+		t.Fatal("WrapSTT didn't return an STT handler")
+	}
+	if got := heardWith(s); got != "old" {
+		t.Errorf("listening with %q before SetSTT, want old", got)
+	}
+	if text, _ := wrapped(sr.SpeechRequest{}); text != "new" {
+		t.Errorf("wrapped handler heard %q, want new", text)
+	}
+	s.SetSTT(sttSaying("new"))
+	if got := heardWith(s); got != "new" {
+		t.Errorf("listening with %q after SetSTT, want new", got)
+	}
+
+	// intent-only engines aren't listened with
+	s.SetSTT(func(sr.SpeechRequest) (string, map[string]string, error) { return "", nil, nil })
+	if s.currentSTT() != nil {
+		t.Error("listening with an intent-only handler")
+	}
+}
+
+// engine switches happen while requests come in, go test -race checks this
+func TestSetSTTWhileListening(t *testing.T) {
+	s := &Slots{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			s.SetSTT(s.WrapSTT(sttSaying("new")))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			heardWith(s)
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,246 @@
+package slots
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// custom intent utterances with slots, like "turn {state:on|off} the {room} lights". chipper only
+// matches utterances as fixed phrases, so templated ones never match there and are left to this package.
+
+// slot types. a slot is free text unless it says otherwise, and a list of options like on|off is an enum.
+const (
+	TypeText     = "text"
+	TypeNumber   = "number"
+	TypeDuration = "duration"
+	TypeEnum     = "enum"
+)
+
+// a number or duration slot is tried with up to this many words
+const maxSlotWords = 8
+
+type Slot struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// for enums, each normalized, longest first
+	Options []string `json:"options,omitempty"`
+}
+
+// Template is a parsed utterance
+type Template struct {
+	Utterance string `json:"utterance"`
+	Slots     []Slot `json:"slots"`
+	tokens    []token
+}
+
+// a word to match exactly, or a slot
+type token struct {
+	word string
+	slot *Slot
+}
+
+// IsTemplate says whether an utterance has slots. chipper would take it as a plain phrase otherwise.
+func IsTemplate(utterance string) bool {
+	return strings.ContainsAny(utterance, "{}")
+}
+
+// Parse reads an utterance's slots. the errors say what to fix, for the custom intent editor.
+func Parse(utterance string) (*Template, error) {
+	t := &Template{Utterance: utterance}
+	rest := utterance
+	words := 0
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			words += t.addWords(rest)
+			break
+		}
+		if rest[open] == '}' {
+			return nil, errors.New("} without a {")
+		}
+		words += t.addWords(rest[:open])
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] == '{' {
+			return nil, errors.New("{ without a }")
+		}
+		spec := rest[open+1 : open+1+end]
+		slot, err := parseSlot(spec)
+		if err != nil {
+			return nil, fmt.Errorf("{%s}: %s", spec, err)
+		}
+		for _, s := range t.Slots {
+			if s.Name == slot.Name {
+				return nil, fmt.Errorf("there are two slots called %s", slot.Name)
+			}
+		}
+		if n := len(t.tokens); n > 0 {
+			if prev := t.tokens[n-1].slot; prev != nil && prev.Type == TypeText && slot.Type == TypeText {
+				return nil, fmt.Errorf("{%s} and {%s} need a word between them, or it can't be told where one ends", prev.Name, slot.Name)
+			}
+		}
+		t.Slots = append(t.Slots, *slot)
+		t.tokens = append(t.tokens, token{slot: slot})
+		rest = rest[open+1+end+1:]
+	}
+	if words == 0 {
+		return nil, errors.New("needs at least one word besides its slots, or it would match anything")
+	}
+	return t, nil
+}
+
+func (t *Template) addWords(s string) int {
+	words := normalize(s)
+	for _, w := range words {
+		t.tokens = append(t.tokens, token{word: w})
+	}
+	return len(words)
+}
+
+// name, name:type or name:option|option
+func parseSlot(spec string) (*Slot, error) {
+	name, kind, typed := strings.Cut(strings.TrimSpace(spec), ":")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("the slot needs a name")
+	}
+	for _, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return nil, errors.New("slot names can only have letters, digits and _")
+		}
+	}
+	slot := &Slot{Name: name, Type: TypeText}
+	if !typed {
+		return slot, nil
+	}
+	kind = strings.TrimSpace(kind)
+	switch strings.ToLower(kind) {
+	case TypeText, TypeNumber, TypeDuration:
+		slot.Type = strings.ToLower(kind)
+		return slot, nil
+	case "":
+		return nil, errors.New("nothing after the :, give a type (number, duration, text) or options like on|off")
+	}
+	if !strings.Contains(kind, "|") {
+		return nil, fmt.Errorf("unknown type %s, use number, duration, text or options like on|off", kind)
+	}
+	slot.Type = TypeEnum
+	for _, opt := range strings.Split(kind, "|") {
+		words := normalize(opt)
+		if len(words) == 0 {
+			return nil, errors.New("one of the options is empty")
+		}
+		slot.Options = append(slot.Options, strings.Join(words, " "))
+	}
+	// so "living room" is tried before "living"
+	sort.SliceStable(slot.Options, func(i, j int) bool {
+		return len(strings.Fields(slot.Options[i])) > len(strings.Fields(slot.Options[j]))
+	})
+	return slot, nil
+}
+
+// Match fills in the slots from text. like chipper's phrases, the template can be anywhere in the text,
+// except a free text slot at the end, which takes the rest of it.
+func (t *Template) Match(text string) (map[string]string, bool) {
+	words := normalize(text)
+	for start := range words {
+		values := map[string]string{}
+		if t.match(0, words[start:], values) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Template) match(ti int, words []string, values map[string]string) bool {
+	if ti == len(t.tokens) {
+		return true
+	}
+	tok := t.tokens[ti]
+	if tok.slot == nil {
+		return len(words) > 0 && words[0] == tok.word && t.match(ti+1, words[1:], values)
+	}
+	slot := tok.slot
+	try := func(n int) bool {
+		value, ok := slot.value(words[:n])
+		if !ok {
+			return false
+		}
+		values[slot.Name] = value
+		if t.match(ti+1, words[n:], values) {
+			return true
+		}
+		delete(values, slot.Name)
+		return false
+	}
+	switch slot.Type {
+	case TypeEnum:
+		for _, opt := range slot.Options {
+			n := len(strings.Fields(opt))
+			if n <= len(words) && strings.Join(words[:n], " ") == opt && try(n) {
+				return true
+			}
+		}
+	case TypeText:
+		if ti == len(t.tokens)-1 {
+			return len(words) > 0 && try(len(words))
+		}
+		// as few words as will do, so the words after the slot can match
+		for n := 1; n <= len(words); n++ {
+			if try(n) {
+				return true
+			}
+		}
+	default:
+		// as many words as make a number, so "twenty five" isn't taken as twenty
+		n := len(words)
+		if n > maxSlotWords {
+			n = maxSlotWords
+		}
+		for ; n > 0; n-- {
+			if try(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// the value a slot takes from words, in the form it is passed on in
+func (s *Slot) value(words []string) (string, bool) {
+	switch s.Type {
+	case TypeNumber:
+		n, ok := ParseNumber(words)
+		if !ok {
+			return "", false
+		}
+		return FormatNumber(n), true
+	case TypeDuration:
+		secs, ok := ParseDuration(words)
+		if !ok {
+			return "", false
+		}
+		return FormatNumber(secs), true
+	}
+	return strings.Join(words, " "), true
+}
+
+// lowercase words without punctuation, as STT engines differ in what they add. decimal points stay.
+func normalize(s string) []string {
+	s = strings.ToLower(s)
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'':
+			b.WriteRune(r)
+		case r == '.' && i > 0 && i < len(runes)-1 && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
@@ -0,0 +1,15 @@
+package sttengine
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed engines.html
+var page []byte
+
+// ServePage lists the engines and switches between them through /api-chipper/set_stt_engine
+func ServePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
@@ -0,0 +1,136 @@
+package sttengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+	"github.com/kercre123/WirePod/cross/wav"
+	sr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/speechrequest"
+)
+
+var sttLog = logs.For("stt")
+
+// remote engine: any server which speaks OpenAI's /v1/audio/transcriptions.
+// that covers OpenAI Whisper itself, faster-whisper-server, LocalAI, or a tiny local stub.
+
+var RemoteName = "whisper-remote"
+
+var RemoteTimeout = time.Second * 20
+
+var (
+	remoteMu   sync.Mutex
+	remoteConf RemoteConfig
+)
+
+type transcriptionResp struct {
+	Text string `json:"text"`
+}
+
+func init() {
+	Register(Engine{
+		Name:        RemoteName,
+		Description: "Sends each request to an OpenAI-compatible Whisper endpoint.",
+		Capabilities: Capabilities{
+			// wire-pod's intent matching is English-only for engines other than vosk/whisper.cpp
+			Languages: []string{"en-US"},
+			Streaming: false,
+			Offline:   false,
+		},
+		Init: remoteInit,
+		STT:  remoteSTT,
+	})
+}
+
+func SetRemoteConfig(conf RemoteConfig) {
+	remoteMu.Lock()
+	defer remoteMu.Unlock()
+	remoteConf = conf
+}
+
+func getRemoteConfig() RemoteConfig {
+	remoteMu.Lock()
+	defer remoteMu.Unlock()
+	return remoteConf
+}
+
+func remoteInit() error {
+	conf := getRemoteConfig()
+	if strings.TrimSpace(conf.URL) == "" {
+		return errors.New("remote stt endpoint URL is not set")
+	}
+	sttLog.Info("Using remote STT endpoint " + conf.URL)
+	return nil
+}
+
+func remoteSTT(req sr.SpeechRequest) (string, error) {
+	sttLog.Robot(req.Device).Debug("(" + RemoteName + ") Processing...")
+	for {
+		_, err := req.GetNextStreamChunk()
+		if err != nil {
+			return "", err
+		}
+		speechIsDone, _ := req.DetectEndOfSpeech()
+		if speechIsDone {
+			break
+		}
+	}
+	transcribedText, err := Transcribe(getRemoteConfig(), wav.Encode(req.DecodedMicData))
+	if err != nil {
+		return "", err
+	}
+	sttLog.Robot(req.Device).Info("Transcribed text: " + transcribedText)
+	return transcribedText, nil
+}
+
+// Transcribe posts a WAV file to the endpoint in conf and returns the lowercased text
+func Transcribe(conf RemoteConfig, wavFile []byte) (string, error) {
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	model := conf.Model
+	if model == "" {
+		model = "whisper-1"
+	}
+	w.WriteField("model", model)
+	sendFile, err := w.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", err
+	}
+	sendFile.Write(wavFile)
+	w.Close()
+
+	httpReq, err := http.NewRequest("POST", conf.URL, buf)
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", w.FormDataContentType())
+	if conf.Key != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+conf.Key)
+	}
+	client := &http.Client{Timeout: RemoteTimeout}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("remote stt returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var tResp transcriptionResp
+	if err := json.Unmarshal(body, &tResp); err != nil {
+		return "", fmt.Errorf("remote stt returned invalid json: %s", err)
+	}
+	return strings.ToLower(strings.TrimSpace(tResp.Text)), nil
+}
@@ -0,0 +1,126 @@
+package sttengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// registry of speech-to-text engines the packaged builds can run.
+// each engine file registers itself in init(), so which engines exist depends on build tags.
+
+var DefaultEngine = "vosk"
+
+var ConfigName = "stt-engine.json"
+
+type Capabilities struct {
+	Languages []string `json:"languages"`
+	// true if the engine transcribes while audio is still streaming in
+	Streaming bool `json:"streaming"`
+	// false if the engine needs network access to transcribe
+	Offline bool `json:"offline"`
+}
+
+type Engine struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Capabilities Capabilities `json:"capabilities"`
+	// same shapes that wp.New takes
+	Init func() error `json:"-"`
+	STT  interface{}  `json:"-"`
+}
+
+type RemoteConfig struct {
+	// full URL of an OpenAI-compatible /v1/audio/transcriptions endpoint
+	URL   string `json:"url"`
+	Key   string `json:"key"`
+	Model string `json:"model"`
+}
+
+type Config struct {
+	Engine string       `json:"engine"`
+	Remote RemoteConfig `json:"remote"`
+}
+
+var (
+	enginesMu sync.Mutex
+	engines   = map[string]Engine{}
+)
+
+func Register(e Engine) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	engines[e.Name] = e
+}
+
+func Get(name string) (Engine, bool) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	e, ok := engines[name]
+	return e, ok
+}
+
+// List returns all registered engines sorted by name
+func List() []Engine {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	var list []Engine
+	for _, e := range engines {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Engine: DefaultEngine,
+		Remote: RemoteConfig{
+			URL:   "https://api.openai.com/v1/audio/transcriptions",
+			Model: "whisper-1",
+		},
+	}
+}
+
+// ConfigPath returns the engine config location inside a pod data directory
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig never fails hard. a missing or broken file gives the default config.
+func ReadConfig(path string) Config {
+	conf := DefaultConfig()
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return conf
+	}
+	json.Unmarshal(file, &conf)
+	if _, ok := Get(conf.Engine); !ok {
+		conf.Engine = DefaultEngine
+	}
+	return conf
+}
+
+func WriteConfig(path string, conf Config) error {
+	os.MkdirAll(filepath.Dir(path), 0777)
+	marshalled, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, marshalled, 0644)
+}
+
+// Select applies engine-specific settings from conf and returns the engine to run
+func Select(conf Config) (Engine, error) {
+	SetRemoteConfig(conf.Remote)
+	e, ok := Get(conf.Engine)
+	if !ok {
+		return Engine{}, fmt.Errorf("stt engine %q is not available in this build", conf.Engine)
+	}
+	return e, nil
+}
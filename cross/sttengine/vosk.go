@@ -0,0 +1,20 @@
+package sttengine
+
+import (
+	"github.com/kercre123/wire-pod/chipper/pkg/wirepod/localization"
+	wirepod_vosk "github.com/kercre123/wire-pod/chipper/pkg/wirepod/stt/vosk"
+)
+
+func init() {
+	Register(Engine{
+		Name:        wirepod_vosk.Name,
+		Description: "Vosk, runs locally. Models are downloaded per language.",
+		Capabilities: Capabilities{
+			Languages: localization.ValidVoskModels,
+			Streaming: true,
+			Offline:   true,
+		},
+		Init: wirepod_vosk.Init,
+		STT:  wirepod_vosk.STT,
+	})
+}
@@ -0,0 +1,25 @@
+//go:build whispercpp
+// +build whispercpp
+
+package sttengine
+
+// whisper.cpp needs libwhisper at link time, so it's only compiled in with -tags whispercpp
+
+import (
+	"github.com/kercre123/wire-pod/chipper/pkg/wirepod/localization"
+	wirepod_whispercpp "github.com/kercre123/wire-pod/chipper/pkg/wirepod/stt/whisper.cpp"
+)
+
+func init() {
+	Register(Engine{
+		Name:        wirepod_whispercpp.Name,
+		Description: "whisper.cpp, runs locally. Slower than Vosk but more accurate.",
+		Capabilities: Capabilities{
+			Languages: localization.ValidVoskModels,
+			Streaming: false,
+			Offline:   true,
+		},
+		Init: wirepod_whispercpp.Init,
+		STT:  wirepod_whispercpp.STT,
+	})
+}
@@ -0,0 +1,185 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+A release manifest is a JSON file, for example:
+
+	{
+	  "version": "v1.2.0",
+	  "released": "2024-09-01T00:00:00Z",
+	  "notes": "...",
+	  "artifacts": [
+	    {"platform": "windows-amd64", "url": "wire-pod-win-amd64.zip", "sha256": "...", "size": 123}
+	  ]
+	}
+
+Next to it, at <manifest url>.sig, is the base64 ed25519 signature of the exact manifest bytes.
+Artifact URLs may be relative to the manifest.
+*/
+
+type Artifact struct {
+	Platform string `json:"platform"`
+	URL      string `json:"url"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+}
+
+type Manifest struct {
+	Version   string     `json:"version"`
+	Released  time.Time  `json:"released"`
+	Notes     string     `json:"notes"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Platform is this build's artifact platform, e.g. windows-amd64
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// ParsePublicKey accepts a base64 or hex ed25519 public key
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.New("no update public key configured")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != ed25519.PublicKeySize {
+		decoded, err = hex.DecodeString(key)
+	}
+	if err != nil || len(decoded) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid update public key")
+	}
+	return ed25519.PublicKey(decoded), nil
+}
+
+// VerifyManifest checks the signature and parses the manifest
+func VerifyManifest(data, sig []byte, pub ed25519.PublicKey) (Manifest, error) {
+	var m Manifest
+	if len(pub) != ed25519.PublicKeySize {
+		return m, errors.New("invalid update public key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return m, fmt.Errorf("invalid manifest signature: %s", err)
+	}
+	if !ed25519.Verify(pub, data, decoded) {
+		return m, errors.New("manifest signature does not match")
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest reads a manifest without checking a signature. only trust it as far as its source.
+func ParseManifest(data []byte) (Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("invalid manifest: %s", err)
+	}
+	if m.Version == "" {
+		return m, errors.New("invalid manifest: no version")
+	}
+	return m, nil
+}
+
+// FetchManifest downloads a manifest and its signature and verifies them
+func FetchManifest(url string, pub ed25519.PublicKey) (Manifest, error) {
+	data, err := get(url)
+	if err != nil {
+		return Manifest{}, err
+	}
+	sig, err := get(url + ".sig")
+	if err != nil {
+		return Manifest{}, err
+	}
+	m, err := VerifyManifest(data, sig, pub)
+	if err != nil {
+		return m, err
+	}
+	return m.resolve(url), nil
+}
+
+// FetchUnsignedManifest downloads a manifest without a signature, for checksums from a source that is already trusted.
+// the updater must use FetchManifest.
+func FetchUnsignedManifest(url string) (Manifest, error) {
+	data, err := get(url)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return m, err
+	}
+	return m.resolve(url), nil
+}
+
+// makes artifact URLs relative to the manifest absolute
+func (m Manifest) resolve(manifestURL string) Manifest {
+	base := manifestURL[:strings.LastIndex(manifestURL, "/")+1]
+	for i, a := range m.Artifacts {
+		if !strings.HasPrefix(a.URL, "http://") && !strings.HasPrefix(a.URL, "https://") {
+			m.Artifacts[i].URL = base + strings.TrimPrefix(a.URL, "/")
+		}
+	}
+	return m
+}
+
+// Artifact returns the artifact for a platform
+func (m Manifest) Artifact(platform string) (Artifact, bool) {
+	for _, a := range m.Artifacts {
+		if a.Platform == platform {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// Newer reports whether candidate is a higher version than current.
+// versions look like v1.2.3; anything after a - or + is ignored.
+func Newer(candidate, current string) bool {
+	c, cur := versionParts(candidate), versionParts(current)
+	for i := 0; i < 3; i++ {
+		if c[i] != cur[i] {
+			return c[i] > cur[i]
+		}
+	}
+	return false
+}
+
+func versionParts(version string) [3]int {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i != -1 {
+		version = version[:i]
+	}
+	for i, p := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(p)
+	}
+	return parts
+}
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+func get(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testManifest = `{"version":"v1.2.0","artifacts":[{"platform":"linux-amd64","url":"wire-pod.zip","sha256":"00"}]}`
+
+func testKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func sign(priv ed25519.PrivateKey, data string) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(data))))
+}
+
+func TestVerifyManifest(t *testing.T) {
+	pub, priv := testKey(t)
+	otherPub, otherPriv := testKey(t)
+	tests := []struct {
+		name    string
+		data    string
+		sig     []byte
+		pub     ed25519.PublicKey
+		wantErr bool
+	}{
+		{"good", testManifest, sign(priv, testManifest), pub, false},
+		{"trailing newline on the sig", testManifest, append(sign(priv, testManifest), '\n'), pub, false},
+		{"other key", testManifest, sign(otherPriv, testManifest), pub, true},
+		{"wrong public key", testManifest, sign(priv, testManifest), otherPub, true},
+		{"changed manifest", `{"version":"v9.9.9"}`, sign(priv, testManifest), pub, true},
+		{"not base64", testManifest, []byte("not a signature!"), pub, true},
+		{"no key", testManifest, sign(priv, testManifest), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := VerifyManifest([]byte(tt.data), tt.sig, tt.pub)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyManifest() = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && m.Version != "v1.2.0" {
+				t.Errorf("version = %s, want v1.2.0", m.Version)
+			}
+		})
+	}
+}
+
+func TestFetchManifest(t *testing.T) {
+	pub, priv := testKey(t)
+	_, otherPriv := testKey(t)
+	sig := sign(priv, testManifest)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/good/manifest.json", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(testManifest)) })
+	mux.HandleFunc("/good/manifest.json.sig", func(w http.ResponseWriter, r *http.Request) { w.Write(sig) })
+	mux.HandleFunc("/bad/manifest.json", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(testManifest)) })
+	mux.HandleFunc("/bad/manifest.json.sig", func(w http.ResponseWriter, r *http.Request) { w.Write(sign(otherPriv, testManifest)) })
+	mux.HandleFunc("/unsigned/manifest.json", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(testManifest)) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	m, err := FetchManifest(srv.URL+"/good/manifest.json", pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := m.Artifact("linux-amd64"); !ok || a.URL != srv.URL+"/good/wire-pod.zip" {
+		t.Errorf("artifact = %+v, want its URL next to the manifest", a)
+	}
+	if _, err := FetchManifest(srv.URL+"/bad/manifest.json", pub); err == nil {
+		t.Error("FetchManifest accepted a manifest signed with another key")
+	}
+	if _, err := FetchManifest(srv.URL+"/unsigned/manifest.json", pub); err == nil {
+		t.Error("FetchManifest accepted a manifest without a signature")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		candidate, current string
+		want               bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0-beta", "v1.2.0", false},
+		{"v1.1.0", "v1.2.0", false},
+		{"1.2.1", "v1.2.0", true},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.candidate, tt.current); got != tt.want {
+			t.Errorf("Newer(%s, %s) = %t, want %t", tt.candidate, tt.current, got, tt.want)
+		}
+	}
+}
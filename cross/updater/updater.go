@@ -0,0 +1,390 @@
+package updater
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/download"
+	"github.com/kercre123/WirePod/cross/installengine"
+)
+
+/*
+Updates happen in three steps:
+
+1. Stage: the artifact is downloaded, checked against the manifest's sha256 and unpacked into <StateDir>/staged.
+2. Apply (on the next start, before wire-pod binds any ports): every staged file is moved over its
+   counterpart in Root. The file it replaces is renamed to <name>.wpupdate-old first, which works even
+   for a running executable on Windows. The list of swapped files is journaled in the state file.
+3. Health check: the new version is started and has HealthTimeout to call MarkHealthy. If it exits or
+   times out, the swap is undone using the journal and the old version is started again.
+   A version which was rolled back is not offered again.
+*/
+
+const oldSuffix = ".wpupdate-old"
+
+var stateName = "update-state.json"
+
+type Updater struct {
+	ManifestURL    string
+	PublicKey      ed25519.PublicKey
+	CurrentVersion string
+	// directory the artifact is unpacked onto, e.g. the install path
+	Root string
+	// where the staged update, download and state file live. must be outside Root.
+	StateDir      string
+	HealthTimeout time.Duration
+
+	mu sync.Mutex
+}
+
+type SwappedFile struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+}
+
+type Applying struct {
+	Version         string        `json:"version"`
+	PreviousVersion string        `json:"previousversion"`
+	Files           []SwappedFile `json:"files"`
+	StartedAt       time.Time     `json:"startedat"`
+}
+
+type State struct {
+	LastCheck time.Time `json:"lastcheck"`
+	// newest version the last check found, if newer than the current one
+	Available string `json:"available"`
+	Notes     string `json:"notes"`
+	Staged    string `json:"staged"`
+	// set while a swapped-in version hasn't passed its health check
+	Applying    *Applying `json:"applying,omitempty"`
+	SkipVersion string    `json:"skipversion"`
+	LastResult  string    `json:"lastresult"`
+}
+
+func (u *Updater) statePath() string {
+	return filepath.Join(u.StateDir, stateName)
+}
+
+func (u *Updater) stagedDir() string {
+	return filepath.Join(u.StateDir, "staged")
+}
+
+func (u *Updater) State() State {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.readState()
+}
+
+func (u *Updater) readState() State {
+	var s State
+	data, err := os.ReadFile(u.statePath())
+	if err == nil {
+		json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+func (u *Updater) writeState(s State) error {
+	os.MkdirAll(u.StateDir, 0777)
+	data, _ := json.MarshalIndent(s, "", "  ")
+	tmp := u.statePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.statePath())
+}
+
+func (u *Updater) update(f func(s *State)) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	s := u.readState()
+	f(&s)
+	return u.writeState(s)
+}
+
+// Check fetches the manifest. the artifact is only valid if available is true.
+func (u *Updater) Check() (m Manifest, a Artifact, available bool, err error) {
+	m, err = FetchManifest(u.ManifestURL, u.PublicKey)
+	if err != nil {
+		return
+	}
+	a, ok := m.Artifact(Platform())
+	state := u.State()
+	available = ok && Newer(m.Version, u.CurrentVersion) && m.Version != state.SkipVersion
+	u.update(func(s *State) {
+		s.LastCheck = time.Now()
+		s.Available = ""
+		s.Notes = ""
+		if available {
+			s.Available = m.Version
+			s.Notes = m.Notes
+		}
+	})
+	return
+}
+
+// Stage downloads, verifies and unpacks an artifact so it is applied on the next start
+func (u *Updater) Stage(m Manifest, a Artifact) error {
+	if a.SHA256 == "" {
+		return errors.New("artifact has no checksum")
+	}
+	os.MkdirAll(u.StateDir, 0777)
+	archive := filepath.Join(u.StateDir, "download.zip")
+	defer os.Remove(archive)
+	if err := download.File(a.URL, archive, download.Options{SHA256: a.SHA256}); err != nil {
+		return err
+	}
+	os.RemoveAll(u.stagedDir())
+	if err := unzip(archive, u.stagedDir()); err != nil {
+		os.RemoveAll(u.stagedDir())
+		return err
+	}
+	return u.update(func(s *State) {
+		s.Staged = m.Version
+	})
+}
+
+// Unstage drops a staged update
+func (u *Updater) Unstage() error {
+	os.RemoveAll(u.stagedDir())
+	return u.update(func(s *State) {
+		s.Staged = ""
+	})
+}
+
+// Apply swaps the staged files into Root and journals them
+func (u *Updater) Apply() (Applying, error) {
+	state := u.State()
+	if state.Staged == "" {
+		return Applying{}, errors.New("no update is staged")
+	}
+	if state.Applying != nil {
+		return Applying{}, errors.New("an update is already being applied")
+	}
+	staged := u.stagedDir()
+	applying := Applying{
+		Version:         state.Staged,
+		PreviousVersion: u.CurrentVersion,
+		StartedAt:       time.Now(),
+	}
+	err := filepath.Walk(staged, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staged, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(u.Root, rel)
+		_, statErr := os.Stat(target)
+		applying.Files = append(applying.Files, SwappedFile{Path: target, Existed: statErr == nil})
+		return nil
+	})
+	if err != nil {
+		return applying, err
+	}
+	// journal first, so a crash halfway through can still be rolled back
+	if err := u.update(func(s *State) { s.Applying = &applying }); err != nil {
+		return applying, err
+	}
+	for _, f := range applying.Files {
+		rel, _ := filepath.Rel(u.Root, f.Path)
+		if err := swapIn(filepath.Join(staged, rel), f); err != nil {
+			u.Rollback()
+			return applying, fmt.Errorf("error swapping in %s: %s", rel, err)
+		}
+	}
+	os.RemoveAll(staged)
+	u.update(func(s *State) { s.Staged = "" })
+	return applying, nil
+}
+
+func swapIn(src string, f SwappedFile) error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0777); err != nil {
+		return err
+	}
+	if f.Existed {
+		os.Remove(f.Path + oldSuffix)
+		if err := os.Rename(f.Path, f.Path+oldSuffix); err != nil {
+			return err
+		}
+	}
+	return os.Rename(src, f.Path)
+}
+
+// Rollback undoes an applied update using the journal. the rolled back version is skipped from now on.
+func (u *Updater) Rollback() error {
+	state := u.State()
+	if state.Applying == nil {
+		return errors.New("no update to roll back")
+	}
+	var lastErr error
+	for _, f := range state.Applying.Files {
+		if _, err := os.Stat(f.Path + oldSuffix); err == nil {
+			os.Remove(f.Path)
+			if err := os.Rename(f.Path+oldSuffix, f.Path); err != nil {
+				lastErr = err
+			}
+		} else if !f.Existed {
+			os.Remove(f.Path)
+		}
+	}
+	version := state.Applying.Version
+	err := u.update(func(s *State) {
+		s.Applying = nil
+		s.SkipVersion = version
+		s.Available = ""
+		s.LastResult = "update to " + version + " failed its health check and was rolled back"
+	})
+	if lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// MarkHealthy is called by a started wire-pod once it's serving. it confirms a pending update and removes leftovers.
+func (u *Updater) MarkHealthy() error {
+	state := u.State()
+	if state.Applying != nil {
+		version := state.Applying.Version
+		// files the update added belong in the install manifest, so the uninstaller removes them too.
+		// a failure here isn't worth rolling back a working update for.
+		var added []string
+		for _, f := range state.Applying.Files {
+			if !f.Existed {
+				added = append(added, f.Path)
+			}
+		}
+		installengine.AddFiles(installengine.ManifestPath(u.Root), added)
+		err := u.update(func(s *State) {
+			s.Applying = nil
+			s.Available = ""
+			s.LastResult = "updated to " + version
+		})
+		if err != nil {
+			return err
+		}
+	}
+	u.Cleanup()
+	return nil
+}
+
+// Cleanup removes replaced files left by the last update. files still in use are left for next time.
+func (u *Updater) Cleanup() {
+	if u.State().Applying != nil {
+		return
+	}
+	filepath.Walk(u.Root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, oldSuffix) {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// WaitHealthy waits for MarkHealthy. exited is closed by the caller if the new process quits early.
+func (u *Updater) WaitHealthy(exited <-chan struct{}) error {
+	timeout := u.HealthTimeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+	deadline := time.After(timeout)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-exited:
+			if u.State().Applying == nil {
+				return nil
+			}
+			return errors.New("new version exited before passing its health check")
+		case <-deadline:
+			return errors.New("new version did not pass its health check in time")
+		case <-tick.C:
+			if u.State().Applying == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// release zips have everything under wire-pod/, same as the installer expects
+func unzip(archive, dest string) error {
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("error reading zip file: %s", err)
+	}
+	defer zipReader.Close()
+	for _, f := range zipReader.File {
+		name := strings.TrimPrefix(f.Name, "wire-pod/")
+		if name == "" || f.FileInfo().IsDir() {
+			continue
+		}
+		fpath := filepath.Join(dest, name)
+		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path: %s", f.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			return err
+		}
+		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		if err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			outFile.Close()
+			return err
+		}
+		_, err = io.Copy(outFile, rc)
+		outFile.Close()
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyAndWatch applies the staged update and starts the new version with start.
+// if it doesn't pass its health check it is killed, the update is rolled back and start is called again for the old version.
+func (u *Updater) ApplyAndWatch(start func() (*exec.Cmd, error)) error {
+	if _, err := u.Apply(); err != nil {
+		return err
+	}
+	cmd, err := start()
+	if err != nil {
+		u.Rollback()
+		return err
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	if err := u.WaitHealthy(exited); err != nil {
+		cmd.Process.Kill()
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+		}
+		if rerr := u.Rollback(); rerr != nil {
+			return fmt.Errorf("%s, and rolling back failed: %s", err, rerr)
+		}
+		if _, serr := start(); serr != nil {
+			return fmt.Errorf("%s, rolled back but the old version couldn't be started: %s", err, serr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package updater
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/download"
+)
+
+func testUpdater(t *testing.T) *Updater {
+	t.Helper()
+	dir := t.TempDir()
+	return &Updater{CurrentVersion: "v1.1.0", Root: filepath.Join(dir, "wire-pod"), StateDir: filepath.Join(dir, "state")}
+}
+
+// a release zip, with everything under wire-pod/
+func testRelease(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create("wire-pod/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// stages v1.2.0 which replaces chipper and adds a new file
+func stageTestRelease(t *testing.T, u *Updater) {
+	t.Helper()
+	release := testRelease(t, map[string]string{"chipper/chipper": "new", "chipper/added.txt": "added"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(release) }))
+	defer srv.Close()
+	sum := sha256.Sum256(release)
+	a := Artifact{Platform: Platform(), URL: srv.URL + "/wire-pod.zip", SHA256: hex.EncodeToString(sum[:])}
+	if err := u.Stage(Manifest{Version: "v1.2.0"}, a); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStageChecksumMismatch(t *testing.T) {
+	u := testUpdater(t)
+	release := testRelease(t, map[string]string{"chipper/chipper": "new"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(release) }))
+	defer srv.Close()
+
+	a := Artifact{Platform: Platform(), URL: srv.URL + "/wire-pod.zip", SHA256: strings.Repeat("0", 64)}
+	err := u.Stage(Manifest{Version: "v1.2.0"}, a)
+	var sumErr *download.ChecksumError
+	if !errors.As(err, &sumErr) {
+		t.Fatalf("Stage() = %v, want a checksum error", err)
+	}
+	if s := u.State(); s.Staged != "" {
+		t.Errorf("staged = %s after a bad download", s.Staged)
+	}
+	if _, err := os.Stat(u.stagedDir()); !os.IsNotExist(err) {
+		t.Error("a bad download was unpacked")
+	}
+	if _, err := u.Apply(); err == nil {
+		t.Error("Apply() applied a download which failed its checksum")
+	}
+}
+
+func TestApplyAndRollback(t *testing.T) {
+	u := testUpdater(t)
+	chipper := filepath.Join(u.Root, "chipper", "chipper")
+	added := filepath.Join(u.Root, "chipper", "added.txt")
+	writeFile(t, chipper, "old")
+	stageTestRelease(t, u)
+
+	applying, err := u.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, chipper); got != "new" {
+		t.Errorf("chipper = %q after Apply, want new", got)
+	}
+	if got := readFile(t, chipper+oldSuffix); got != "old" {
+		t.Errorf("%s = %q, want the old chipper", oldSuffix, got)
+	}
+	// the journal has to be on disk before anything is swapped, a fresh Updater only has that to go on
+	u = &Updater{CurrentVersion: u.CurrentVersion, Root: u.Root, StateDir: u.StateDir}
+	s := u.State()
+	if s.Applying == nil || len(s.Applying.Files) != len(applying.Files) || s.Staged != "" {
+		t.Fatalf("state = %+v, want the swapped files journaled", s)
+	}
+
+	if err := u.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, chipper); got != "old" {
+		t.Errorf("chipper = %q after Rollback, want old", got)
+	}
+	if _, err := os.Stat(chipper + oldSuffix); !os.IsNotExist(err) {
+		t.Errorf("%s is left after Rollback", oldSuffix)
+	}
+	if _, err := os.Stat(added); !os.IsNotExist(err) {
+		t.Error("a file the update added is left after Rollback")
+	}
+	s = u.State()
+	if s.Applying != nil || s.SkipVersion != "v1.2.0" || !strings.Contains(s.LastResult, "rolled back") {
+		t.Errorf("state = %+v, want v1.2.0 skipped", s)
+	}
+	if err := u.Rollback(); err == nil {
+		t.Error("Rollback() rolled back twice")
+	}
+}
+
+func TestMarkHealthy(t *testing.T) {
+	u := testUpdater(t)
+	chipper := filepath.Join(u.Root, "chipper", "chipper")
+	writeFile(t, chipper, "old")
+	stageTestRelease(t, u)
+	if _, err := u.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.MarkHealthy(); err != nil {
+		t.Fatal(err)
+	}
+	if s := u.State(); s.Applying != nil || s.LastResult != "updated to v1.2.0" {
+		t.Errorf("state = %+v, want the update confirmed", s)
+	}
+	if _, err := os.Stat(chipper + oldSuffix); !os.IsNotExist(err) {
+		t.Errorf("%s is left after MarkHealthy", oldSuffix)
+	}
+	if err := u.Rollback(); err == nil {
+		t.Error("Rollback() rolled back a confirmed update")
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	u := testUpdater(t)
+	u.HealthTimeout = 50 * time.Millisecond
+	writeFile(t, filepath.Join(u.Root, "chipper", "chipper"), "old")
+	stageTestRelease(t, u)
+	if _, err := u.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := u.WaitHealthy(make(chan struct{})); err == nil {
+		t.Fatal("WaitHealthy() = nil without MarkHealthy")
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("WaitHealthy() took %s with a %s timeout", took, u.HealthTimeout)
+	}
+
+	exited := make(chan struct{})
+	close(exited)
+	if err := u.WaitHealthy(exited); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Errorf("WaitHealthy() = %v after the new version exited", err)
+	}
+
+	u.MarkHealthy()
+	if err := u.WaitHealthy(exited); err != nil {
+		t.Errorf("WaitHealthy() = %v after MarkHealthy", err)
+	}
+}
@@ -0,0 +1,74 @@
+package voicepause
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var cliUsage = `usage: %s pause <command>
+
+commands:
+  on        pause voice processing until the schedule next changes
+  off       resume voice processing until the schedule next changes
+  status    print whether voice processing is paused
+
+talks to the running wire-pod's web server.
+`
+
+// RunCLI handles "pause ..." arguments against the web server at baseURL and returns an exit code
+func RunCLI(baseURL string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, cliUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	var path string
+	switch args[0] {
+	case "on":
+		path = "/api-chipper/pause_set?paused=true"
+	case "off":
+		path = "/api-chipper/pause_set?paused=false"
+	case "status":
+		path = "/api-chipper/pause_status"
+	default:
+		fmt.Fprintf(os.Stderr, cliUsage, filepath.Base(os.Args[0]))
+		return 2
+	}
+	resp, err := client.Get(baseURL + path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "is wire-pod running?", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if strings.HasPrefix(string(body), "error") {
+		fmt.Fprintln(os.Stderr, string(body))
+		return 1
+	}
+	if args[0] != "status" {
+		fmt.Println(string(body))
+		return 0
+	}
+	var st Status
+	if err := json.Unmarshal(body, &st); err != nil {
+		fmt.Fprintln(os.Stderr, "unexpected response:", string(body))
+		return 1
+	}
+	switch {
+	case st.Paused && st.Manual:
+		fmt.Println("paused by hand")
+	case st.Paused:
+		fmt.Println("paused by schedule")
+	case st.Manual && st.Scheduled:
+		fmt.Println("running, resumed by hand during a scheduled pause")
+	default:
+		fmt.Println("running")
+	}
+	return 0
+}
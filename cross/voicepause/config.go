@@ -0,0 +1,155 @@
+package voicepause
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ConfigName = "voice-pause.json"
+
+// what a paused pod answers with
+const (
+	// the robot acts as if it didn't understand
+	ResponseUnmatched = "unmatched"
+	// the robot says Message. needs the robot's SDK to be reachable, otherwise it's the same as unmatched.
+	ResponseSpeak = "speak"
+)
+
+// Window is a time of day voice processing is paused. an End before Start runs past midnight,
+// counting as the day it started. no Days means every day.
+type Window struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+type Config struct {
+	Response string   `json:"response"`
+	Message  string   `json:"message"`
+	Schedule []Window `json:"schedule"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Response: ResponseUnmatched,
+		Message:  "I'm on a break right now. Ask me again later.",
+	}
+}
+
+func ConfigPath(podDir string) string {
+	return filepath.Join(podDir, ConfigName)
+}
+
+// ReadConfig returns the defaults if the file doesn't exist
+func ReadConfig(podDir string) (Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(ConfigPath(podDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := conf.Validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	return conf, nil
+}
+
+func WriteConfig(podDir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(podDir), data, 0644)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (c Config) Validate() error {
+	if c.Response != ResponseUnmatched && c.Response != ResponseSpeak {
+		return fmt.Errorf("response must be %s or %s", ResponseUnmatched, ResponseSpeak)
+	}
+	if c.Response == ResponseSpeak && strings.TrimSpace(c.Message) == "" {
+		return errors.New("message is empty")
+	}
+	for i, w := range c.Schedule {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return fmt.Errorf("schedule %d: start: %s", i+1, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return fmt.Errorf("schedule %d: end: %s", i+1, err)
+		}
+		if start == end {
+			return fmt.Errorf("schedule %d: start and end are the same", i+1)
+		}
+		for _, day := range w.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("schedule %d: unknown day %q, use mon, tue, ...", i+1, day)
+			}
+		}
+	}
+	return nil
+}
+
+// "15:04" -> minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a time like 13:30", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w Window) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether t falls in the window, in t's time zone
+func (w Window) Contains(t time.Time) bool {
+	start, err1 := parseClock(w.Start)
+	end, err2 := parseClock(w.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end && w.onDay(t.Weekday())
+	}
+	// past midnight: the evening part is on the window's day, the morning part on the day after
+	if now >= start {
+		return w.onDay(t.Weekday())
+	}
+	return now < end && w.onDay(t.AddDate(0, 0, -1).Weekday())
+}
+
+// Scheduled reports whether any window contains t
+func (c Config) Scheduled(t time.Time) bool {
+	for _, w := range c.Schedule {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package voicepause
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+)
+
+// while voice processing is paused, chipper keeps running (so jdocs, token and the web UI keep working)
+// but voice requests get the configured response instead of being processed.
+// pausing or resuming by hand holds until the schedule next starts or ends a window.
+
+var pauseLog = logs.For("voice")
+
+type Status struct {
+	Paused bool `json:"paused"`
+	// paused or resumed by hand, against the schedule
+	Manual bool `json:"manual"`
+	// a schedule window is active
+	Scheduled bool   `json:"scheduled"`
+	Response  string `json:"response"`
+}
+
+type Controller struct {
+	// called when the pod is paused or resumed, by hand or by the schedule. set it before Watch.
+	OnChange func(paused bool)
+
+	mu     sync.Mutex
+	conf   Config
+	now    func() time.Time
+	last   bool
+	manual *override
+}
+
+type override struct {
+	paused bool
+	// what the schedule said when it was set
+	scheduled bool
+}
+
+func NewController(conf Config) *Controller {
+	return &Controller{conf: conf, now: time.Now}
+}
+
+func (c *Controller) SetConfig(conf Config) {
+	c.mu.Lock()
+	c.conf = conf
+	c.mu.Unlock()
+	c.check()
+}
+
+func (c *Controller) Config() Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conf
+}
+
+func (c *Controller) status() Status {
+	scheduled := c.conf.Scheduled(c.now())
+	if c.manual != nil && c.manual.scheduled != scheduled {
+		c.manual = nil
+	}
+	st := Status{Paused: scheduled, Scheduled: scheduled, Response: c.conf.Response}
+	if c.manual != nil {
+		st.Paused = c.manual.paused
+		st.Manual = true
+	}
+	return st
+}
+
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status()
+}
+
+func (c *Controller) Paused() bool {
+	return c.Status().Paused
+}
+
+// Set pauses or resumes by hand
+func (c *Controller) Set(paused bool) {
+	c.mu.Lock()
+	c.manual = &override{paused: paused, scheduled: c.conf.Scheduled(c.now())}
+	c.mu.Unlock()
+	c.check()
+}
+
+// check logs and reports a change since the last check
+func (c *Controller) check() {
+	c.mu.Lock()
+	st := c.status()
+	changed := st.Paused != c.last
+	c.last = st.Paused
+	onChange := c.OnChange
+	c.mu.Unlock()
+	if !changed {
+		return
+	}
+	how := "by hand"
+	if !st.Manual {
+		how = "by schedule"
+	}
+	if st.Paused {
+		pauseLog.Info("Voice processing paused " + how)
+	} else {
+		pauseLog.Info("Voice processing resumed " + how)
+	}
+	if onChange != nil {
+		onChange(st.Paused)
+	}
+}
+
+// Watch follows the schedule. it doesn't return.
+func (c *Controller) Watch() {
+	for {
+		c.check()
+		time.Sleep(30 * time.Second)
+	}
+}
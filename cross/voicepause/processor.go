@@ -0,0 +1,67 @@
+package voicepause
+
+import (
+	pb "github.com/digital-dream-labs/api/go/chipperpb"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+	"github.com/kercre123/wire-pod/chipper/pkg/vtt"
+	wp "github.com/kercre123/wire-pod/chipper/pkg/wirepod/preqs"
+	ttr "github.com/kercre123/wire-pod/chipper/pkg/wirepod/ttr"
+)
+
+// Processor is handed to chipper instead of the voice processor itself
+type Processor struct {
+	// the voice processor, or what is in front of it
+	Server history.VoiceProcessor
+	Pause  *Controller
+}
+
+// the response for a request to a paused pod
+func (p Processor) answer(req interface{}, esn string) {
+	conf := p.Pause.Config()
+	pauseLog.Robot(esn).Info("Voice processing is paused, not processing request")
+	ttr.IntentPass(req, "intent_system_unmatched", "", map[string]string{}, false)
+	if conf.Response != ResponseSpeak {
+		return
+	}
+	// KGSim needs the robot's SDK details
+	for _, robot := range vars.BotInfo.Robots {
+		if robot.Esn == esn {
+			go ttr.KGSim(esn, conf.Message)
+			return
+		}
+	}
+}
+
+func (p Processor) ProcessIntent(req *vtt.IntentRequest) (*vtt.IntentResponse, error) {
+	if p.Pause.Paused() {
+		p.answer(req, req.Device)
+		return nil, nil
+	}
+	return p.Server.ProcessIntent(req)
+}
+
+func (p Processor) ProcessIntentGraph(req *vtt.IntentGraphRequest) (*vtt.IntentGraphResponse, error) {
+	if p.Pause.Paused() {
+		p.answer(req, req.Device)
+		return nil, nil
+	}
+	return p.Server.ProcessIntentGraph(req)
+}
+
+// knowledge graph responses carry spoken text themselves
+func (p Processor) ProcessKnowledgeGraph(req *vtt.KnowledgeGraphRequest) (*vtt.KnowledgeGraphResponse, error) {
+	if !p.Pause.Paused() {
+		return p.Server.ProcessKnowledgeGraph(req)
+	}
+	pauseLog.Robot(req.Device).Info("Voice processing is paused, not processing knowledge graph request")
+	kg := pb.KnowledgeGraphResponse{
+		Session:     req.Session,
+		DeviceId:    req.Device,
+		CommandType: wp.NoResult,
+	}
+	if conf := p.Pause.Config(); conf.Response == ResponseSpeak {
+		kg.SpokenText = conf.Message
+	}
+	return nil, req.Stream.Send(&kg)
+}
@@ -0,0 +1,141 @@
+package voskmodels
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var DefaultCatalogURL = "https://github.com/kercre123/vosk-models/raw/main/"
+
+// name of the catalog file the manager looks for in an offline archive directory
+var CatalogFileName = "catalog.json"
+
+type CatalogEntry struct {
+	Language string `json:"language"`
+	Name     string `json:"name"`
+	// absolute URL, or a file name relative to the catalog's location
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+type Catalog struct {
+	Models []CatalogEntry `json:"models"`
+}
+
+// the archives wire-pod's setup page downloads, see localization.DownloadVoskModel.
+// no checksums are known for these, so they are verified only if a catalog provides one.
+var builtinArchives = map[string]string{
+	"en-US": "vosk-model-small-en-us-0.15",
+	"it-IT": "vosk-model-small-it-0.22",
+	"es-ES": "vosk-model-small-es-0.42",
+	"fr-FR": "vosk-model-small-fr-0.22",
+	"de-DE": "vosk-model-small-de-0.15",
+	"pt-BR": "vosk-model-small-pt-0.3",
+	"pl-PL": "vosk-model-small-pl-0.22",
+	"zh-CN": "vosk-model-small-cn-0.22",
+	"tr-TR": "vosk-model-small-tr-0.3",
+	"ru-RU": "vosk-model-small-ru-0.22",
+	"nt-NL": "vosk-model-small-nl-0.22",
+	"uk-UA": "vosk-model-small-uk-v3-small",
+	"vi-VN": "vosk-model-small-vn-0.4",
+}
+
+// BuiltinCatalog lists the default models relative to base (a URL prefix or directory)
+func BuiltinCatalog(base string) Catalog {
+	var cat Catalog
+	for lang, name := range builtinArchives {
+		cat.Models = append(cat.Models, CatalogEntry{
+			Language: lang,
+			Name:     name,
+			URL:      joinLocation(base, name+".zip"),
+		})
+	}
+	return cat
+}
+
+// LoadCatalog reads a catalog from an http(s) URL, a catalog file, or a directory of archives.
+// a directory without catalog.json gets the builtin catalog pointed at it.
+func LoadCatalog(location string) (Catalog, error) {
+	var cat Catalog
+	var data []byte
+	var base string
+	if isURL(location) {
+		if !strings.HasSuffix(location, ".json") {
+			return BuiltinCatalog(location), nil
+		}
+		resp, err := http.Get(location)
+		if err != nil {
+			return cat, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return cat, fmt.Errorf("error getting catalog: %s", resp.Status)
+		}
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return cat, err
+		}
+		base = location[:strings.LastIndex(location, "/")+1]
+	} else {
+		stat, err := os.Stat(location)
+		if err != nil {
+			return cat, err
+		}
+		if stat.IsDir() {
+			base = location
+			data, err = os.ReadFile(filepath.Join(location, CatalogFileName))
+			if err != nil {
+				return BuiltinCatalog(location), nil
+			}
+		} else {
+			base = filepath.Dir(location)
+			data, err = os.ReadFile(location)
+			if err != nil {
+				return cat, err
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &cat); err != nil {
+		return cat, fmt.Errorf("invalid catalog: %s", err)
+	}
+	for i, entry := range cat.Models {
+		if entry.Language == "" || entry.URL == "" {
+			return cat, errors.New("invalid catalog: every model needs a language and url")
+		}
+		if !isURL(entry.URL) && !filepath.IsAbs(entry.URL) {
+			cat.Models[i].URL = joinLocation(base, entry.URL)
+		}
+	}
+	return cat, nil
+}
+
+// Find returns the catalog entry for a language
+func (c Catalog) Find(language string) (CatalogEntry, bool) {
+	for _, entry := range c.Models {
+		if entry.Language == language {
+			return entry, true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func joinLocation(base, name string) string {
+	if isURL(base) {
+		if !strings.HasSuffix(base, "/") {
+			base = base + "/"
+		}
+		return base + name
+	}
+	return filepath.Join(base, name)
+}
@@ -0,0 +1,401 @@
+package voskmodels
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kercre123/WirePod/cross/logs"
+)
+
+var modelsLog = logs.For("models")
+
+// models live at <ModelDir>/<language>/model, the layout wirepod_vosk.Init expects.
+// next to each model the manager keeps a small json file with where it came from.
+
+var metadataName = "model-info.json"
+
+type Manager struct {
+	ModelDir string
+	// http(s) URL or local path, see LoadCatalog
+	CatalogURL string
+	// directory of archives which is checked before going to the network
+	OfflineDir string
+	// language currently in use. it is never deleted.
+	InUse func() string
+}
+
+type Model struct {
+	Language    string    `json:"language"`
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Source      string    `json:"source"`
+	InstalledAt time.Time `json:"installedat"`
+	InUse       bool      `json:"inuse"`
+}
+
+type DiskUsage struct {
+	Models     []Model `json:"models"`
+	TotalBytes int64   `json:"totalbytes"`
+}
+
+func (m *Manager) inUse() string {
+	if m.InUse == nil {
+		return ""
+	}
+	return m.InUse()
+}
+
+// List returns the installed models. directories without a model folder are skipped.
+func (m *Manager) List() ([]Model, error) {
+	entries, err := os.ReadDir(m.ModelDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var models []Model
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		langDir := filepath.Join(m.ModelDir, entry.Name())
+		if _, err := os.Stat(filepath.Join(langDir, "model")); err != nil {
+			continue
+		}
+		var model Model
+		info, err := os.ReadFile(filepath.Join(langDir, metadataName))
+		if err == nil {
+			json.Unmarshal(info, &model)
+		}
+		model.Language = entry.Name()
+		model.Path = filepath.Join(langDir, "model")
+		model.Size = dirSize(langDir)
+		model.InUse = model.Language == m.inUse()
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+func (m *Manager) Usage() (DiskUsage, error) {
+	var usage DiskUsage
+	models, err := m.List()
+	if err != nil {
+		return usage, err
+	}
+	usage.Models = models
+	for _, model := range models {
+		usage.TotalBytes += model.Size
+	}
+	return usage, nil
+}
+
+func (m *Manager) IsInstalled(language string) bool {
+	_, err := os.Stat(filepath.Join(m.ModelDir, language, "model"))
+	return err == nil
+}
+
+// Install gets the catalog model for a language, preferring the offline directory
+func (m *Manager) Install(language string) (Model, error) {
+	var sources []string
+	if m.OfflineDir != "" {
+		if _, err := os.Stat(m.OfflineDir); err == nil {
+			sources = append(sources, m.OfflineDir)
+		}
+	}
+	if m.CatalogURL != "" {
+		sources = append(sources, m.CatalogURL)
+	}
+	var lastErr error = fmt.Errorf("no catalog has a model for %s", language)
+	for _, source := range sources {
+		cat, err := LoadCatalog(source)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		entry, ok := cat.Find(language)
+		if !ok {
+			continue
+		}
+		if !isURL(entry.URL) {
+			if _, err := os.Stat(entry.URL); err != nil {
+				// offline dir doesn't have this one, try the next source
+				lastErr = err
+				continue
+			}
+		}
+		return m.InstallEntry(entry)
+	}
+	return Model{}, lastErr
+}
+
+// InstallEntry fetches, verifies and unpacks one catalog entry
+func (m *Manager) InstallEntry(entry CatalogEntry) (Model, error) {
+	archive := entry.URL
+	if isURL(entry.URL) {
+		modelsLog.Info("Downloading Vosk model " + entry.Name + " from " + entry.URL)
+		tmp, err := download(entry.URL)
+		if err != nil {
+			return Model{}, err
+		}
+		defer os.Remove(tmp)
+		archive = tmp
+	}
+	model, err := m.InstallArchive(archive, entry.Language, entry.SHA256)
+	if err != nil {
+		return model, err
+	}
+	model.Source = entry.URL
+	if entry.Name != "" {
+		model.Name = entry.Name
+	}
+	writeMetadata(filepath.Join(m.ModelDir, entry.Language), model)
+	return model, nil
+}
+
+// InstallArchive unpacks a local zip as the model for language.
+// if expectedSum is set the archive must match it. the old model is only replaced once the new one is fully unpacked.
+func (m *Manager) InstallArchive(archive, language, expectedSum string) (Model, error) {
+	var model Model
+	if language == "" || strings.ContainsAny(language, `/\`) || strings.HasPrefix(language, ".") {
+		return model, fmt.Errorf("invalid language: %q", language)
+	}
+	sum, err := fileSHA256(archive)
+	if err != nil {
+		return model, err
+	}
+	if expectedSum != "" {
+		if !strings.EqualFold(sum, strings.TrimSpace(expectedSum)) {
+			return model, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filepath.Base(archive), expectedSum, sum)
+		}
+	} else {
+		modelsLog.Warn("No checksum known for " + filepath.Base(archive) + ", installing unverified (sha256 " + sum + ")")
+	}
+	if err := os.MkdirAll(m.ModelDir, 0755); err != nil {
+		return model, err
+	}
+	staging, err := os.MkdirTemp(m.ModelDir, ".staging-"+language+"-")
+	if err != nil {
+		return model, err
+	}
+	defer os.RemoveAll(staging)
+	if err := unzip(archive, filepath.Join(staging, "unpacked")); err != nil {
+		return model, err
+	}
+	modelRoot, err := findModelRoot(filepath.Join(staging, "unpacked"))
+	if err != nil {
+		return model, err
+	}
+	langStaging := filepath.Join(staging, language)
+	os.MkdirAll(langStaging, 0755)
+	if err := os.Rename(modelRoot, filepath.Join(langStaging, "model")); err != nil {
+		return model, err
+	}
+	model = Model{
+		Language:    language,
+		Name:        strings.TrimSuffix(filepath.Base(archive), filepath.Ext(archive)),
+		SHA256:      sum,
+		Source:      archive,
+		InstalledAt: time.Now(),
+	}
+	writeMetadata(langStaging, model)
+
+	// swap: move the old one aside, move the new one in, then drop the old one
+	final := filepath.Join(m.ModelDir, language)
+	old := filepath.Join(staging, "old")
+	hadOld := false
+	if _, err := os.Stat(final); err == nil {
+		if err := os.Rename(final, old); err != nil {
+			return model, err
+		}
+		hadOld = true
+	}
+	if err := os.Rename(langStaging, final); err != nil {
+		if hadOld {
+			os.Rename(old, final)
+		}
+		return model, err
+	}
+	model.Path = filepath.Join(final, "model")
+	model.Size = dirSize(final)
+	model.InUse = language == m.inUse()
+	modelsLog.Info("Installed Vosk model for " + language)
+	return model, nil
+}
+
+// Delete removes a model. the model in use can't be deleted.
+func (m *Manager) Delete(language string) error {
+	if language == "" || strings.ContainsAny(language, `/\`) || strings.HasPrefix(language, ".") {
+		return fmt.Errorf("invalid language: %q", language)
+	}
+	if language == m.inUse() {
+		return errors.New("model is in use")
+	}
+	dir := filepath.Join(m.ModelDir, language)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("model for %s is not installed", language)
+	}
+	modelsLog.Info("Deleting Vosk model for " + language)
+	return os.RemoveAll(dir)
+}
+
+// DeleteUnused removes every model except the one in use and returns the freed bytes
+func (m *Manager) DeleteUnused() (int64, error) {
+	models, err := m.List()
+	if err != nil {
+		return 0, err
+	}
+	var freed int64
+	for _, model := range models {
+		if model.InUse {
+			continue
+		}
+		if err := m.Delete(model.Language); err != nil {
+			return freed, err
+		}
+		freed += model.Size
+	}
+	return freed, nil
+}
+
+// Ensure makes sure a model for language is installed, installing it from the catalog if needed
+func (m *Manager) Ensure(language string) error {
+	if language == "" {
+		language = "en-US"
+	}
+	if m.IsInstalled(language) {
+		return nil
+	}
+	modelsLog.Info("No Vosk model installed for " + language + ", installing one")
+	_, err := m.Install(language)
+	return err
+}
+
+func download(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading %s: %s", url, resp.Status)
+	}
+	tmp, err := os.CreateTemp("", "vosk-model-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func unzip(archive, dest string) error {
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("error reading zip file: %s", err)
+	}
+	defer zipReader.Close()
+	for _, f := range zipReader.File {
+		fpath := filepath.Join(dest, f.Name)
+		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(fpath, 0755)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return err
+		}
+		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			outFile.Close()
+			return err
+		}
+		_, err = io.Copy(outFile, rc)
+		outFile.Close()
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archives either contain the model files directly or a single top-level folder with them.
+// a vosk model always has an am/ or conf/ directory.
+func findModelRoot(dir string) (string, error) {
+	if isModelDir(dir) {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && isModelDir(filepath.Join(dir, entry.Name())) {
+			return filepath.Join(dir, entry.Name()), nil
+		}
+	}
+	return "", errors.New("archive does not contain a vosk model")
+}
+
+func isModelDir(dir string) bool {
+	for _, sub := range []string{"am", "conf"} {
+		if stat, err := os.Stat(filepath.Join(dir, sub)); err == nil && stat.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMetadata(langDir string, model Model) {
+	model.Path = ""
+	model.Size = 0
+	model.InUse = false
+	marshalled, _ := json.MarshalIndent(model, "", "  ")
+	os.WriteFile(filepath.Join(langDir, metadataName), marshalled, 0644)
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
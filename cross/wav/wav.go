@@ -0,0 +1,76 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Vector's mic stream, once decoded, is always 16 kHz mono s16le
+
+const (
+	SampleRate    = 16000
+	BitsPerSample = 16
+	Channels      = 1
+)
+
+// Encode wraps raw 16 kHz mono s16le PCM in a RIFF/WAVE header
+func Encode(pcm []byte) []byte {
+	buf := new(bytes.Buffer)
+	byteRate := SampleRate * Channels * BitsPerSample / 8
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(1))
+	binary.Write(buf, binary.LittleEndian, uint16(Channels))
+	binary.Write(buf, binary.LittleEndian, uint32(SampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(byteRate))
+	binary.Write(buf, binary.LittleEndian, uint16(Channels*BitsPerSample/8))
+	binary.Write(buf, binary.LittleEndian, uint16(BitsPerSample))
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// Decode returns the PCM samples of a WAV file. only 16 kHz mono 16-bit PCM is accepted,
+// since that's the only thing the voice pipeline understands.
+func Decode(file []byte) ([]byte, error) {
+	if len(file) < 12 || string(file[0:4]) != "RIFF" || string(file[8:12]) != "WAVE" {
+		return nil, errors.New("not a wav file")
+	}
+	var gotFmt bool
+	pos := 12
+	for pos+8 <= len(file) {
+		id := string(file[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(file[pos+4 : pos+8]))
+		body := file[pos+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("wav fmt chunk is too short")
+			}
+			format := binary.LittleEndian.Uint16(body[0:2])
+			channels := binary.LittleEndian.Uint16(body[2:4])
+			rate := binary.LittleEndian.Uint32(body[4:8])
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if format != 1 || channels != Channels || rate != SampleRate || bits != BitsPerSample {
+				return nil, errors.New("wav must be 16 kHz mono 16-bit PCM")
+			}
+			gotFmt = true
+		case "data":
+			if !gotFmt {
+				return nil, errors.New("wav data chunk before fmt chunk")
+			}
+			return body[:size], nil
+		}
+		// chunks are padded to an even length
+		pos += 8 + size + size%2
+	}
+	return nil, errors.New("wav has no data chunk")
+}
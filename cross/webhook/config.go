@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// webhooks for custom intents, by the intent's name. chipper's custom intents have no room for them, so
+// they are kept beside them, in the directory of customIntents.json.
+
+var ConfigName = "webhooks.json"
+
+// for hooks which leave the timeout at 0
+const DefaultTimeoutSeconds = 5
+
+type Hook struct {
+	URL string `json:"url"`
+	// signs the payload, see Sign. empty sends no signature.
+	Secret string `json:"secret,omitempty"`
+	// each try's. 0 is DefaultTimeoutSeconds.
+	TimeoutSeconds int `json:"timeoutseconds"`
+	// tries after the first, for network errors and 5xx and 429 responses
+	Retries int `json:"retries"`
+	// a field of the JSON response, like reply.text, whose value the robot says. empty says nothing.
+	SayField string `json:"sayfield,omitempty"`
+}
+
+type Config struct {
+	Hooks map[string]Hook `json:"hooks"`
+}
+
+// Redacted is a hook as the API shows it, which says whether there's a secret but not what it is
+type Redacted struct {
+	Hook
+	SecretSet bool `json:"secret_set"`
+}
+
+func (h Hook) Redact() Redacted {
+	r := Redacted{Hook: h, SecretSet: h.Secret != ""}
+	r.Secret = ""
+	return r
+}
+
+// Redact is every hook's Redacted, by the intent's name
+func (c Config) Redact() map[string]Redacted {
+	hooks := map[string]Redacted{}
+	for name, hook := range c.Hooks {
+		hooks[name] = hook.Redact()
+	}
+	return hooks
+}
+
+func ConfigPath(dir string) string {
+	return filepath.Join(dir, ConfigName)
+}
+
+// ReadConfig returns no hooks if the file doesn't exist
+func ReadConfig(dir string) (Config, error) {
+	conf := Config{Hooks: map[string]Hook{}}
+	data, err := os.ReadFile(ConfigPath(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return Config{Hooks: map[string]Hook{}}, err
+	}
+	if conf.Hooks == nil {
+		conf.Hooks = map[string]Hook{}
+	}
+	if err := conf.Validate(); err != nil {
+		return Config{Hooks: map[string]Hook{}}, err
+	}
+	return conf, nil
+}
+
+// the file has the hooks' secrets, so only the pod's user can read it
+func WriteConfig(dir string, conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(conf, "", "  ")
+	return os.WriteFile(ConfigPath(dir), data, 0600)
+}
+
+func (c Config) Validate() error {
+	var names []string
+	for name := range c.Hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := c.Hooks[name].Validate(); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func (h Hook) Validate() error {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("the URL needs to be http:// or https:// and a host")
+	}
+	if h.TimeoutSeconds < 0 || h.TimeoutSeconds > 60 {
+		return errors.New("the timeout can be up to 60 seconds")
+	}
+	if h.Retries < 0 || h.Retries > 10 {
+		return errors.New("there can be up to 10 retries")
+	}
+	return nil
+}
+
+// Hooks are the pod's webhooks, which can change while it runs. a nil Hooks has none.
+type Hooks struct {
+	mu   sync.Mutex
+	conf Config
+}
+
+func (h *Hooks) Set(conf Config) {
+	h.mu.Lock()
+	h.conf = conf
+	h.mu.Unlock()
+}
+
+func (h *Hooks) Config() Config {
+	if h == nil {
+		return Config{Hooks: map[string]Hook{}}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conf := Config{Hooks: map[string]Hook{}}
+	for name, hook := range h.conf.Hooks {
+		conf.Hooks[name] = hook
+	}
+	return conf
+}
+
+// For is the webhook of the custom intent called name
+func (h *Hooks) For(name string) (Hook, bool) {
+	if h == nil {
+		return Hook{}, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hook, ok := h.conf.Hooks[name]
+	return hook, ok
+}
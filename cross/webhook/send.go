@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SignatureHeader has sha256= and the hex HMAC-SHA256 of the body with the hook's secret
+const SignatureHeader = "X-Wire-Pod-Signature"
+
+// responses bigger than this are cut off before the say field is looked for
+const maxResponse = 1 << 20
+
+// the wait before the first retry, doubling after each
+var retryWait = 500 * time.Millisecond
+
+// Payload is what's POSTed
+type Payload struct {
+	Time time.Time `json:"time"`
+	ESN  string    `json:"esn"`
+	// the custom intent's name, and the intent the robot was sent
+	Intent      string            `json:"intent"`
+	RobotIntent string            `json:"robotintent"`
+	Transcript  string            `json:"transcript"`
+	Slots       map[string]string `json:"slots"`
+	Locale      string            `json:"locale"`
+}
+
+// Result is how a call went
+type Result struct {
+	Attempts int `json:"attempts"`
+	// of the last attempt, 0 if there was no response
+	Status int `json:"status"`
+	// the say field's value
+	Say   string `json:"say,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Sign is the signature header's value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header, for receivers
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Send POSTs p to the hook, trying again on network errors and 5xx and 429 responses. when the hook has
+// a say field, a response without it is an error too, but isn't tried again.
+func Send(ctx context.Context, hook Hook, p Payload) Result {
+	if p.Slots == nil {
+		p.Slots = map[string]string{}
+	}
+	body, _ := json.Marshal(p)
+	timeout := time.Duration(hook.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = DefaultTimeoutSeconds * time.Second
+	}
+	var res Result
+	wait := retryWait
+	for {
+		res.Attempts++
+		status, resp, err := post(ctx, hook, body, timeout)
+		res.Status = status
+		retry := false
+		switch {
+		case err != nil:
+			res.Error = err.Error()
+			retry = ctx.Err() == nil
+		case status >= 500 || status == http.StatusTooManyRequests:
+			res.Error = fmt.Sprintf("the hook answered %d", status)
+			retry = true
+		case status >= 300:
+			res.Error = fmt.Sprintf("the hook answered %d", status)
+		default:
+			res.Error = ""
+			if hook.SayField != "" {
+				res.Say, err = sayField(resp, hook.SayField)
+				if err != nil {
+					res.Error = err.Error()
+				}
+			}
+		}
+		if !retry || res.Attempts > hook.Retries {
+			return res
+		}
+		select {
+		case <-ctx.Done():
+			return res
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func post(ctx context.Context, hook Hook, body []byte, timeout time.Duration) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wire-pod")
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// field is dotted, like reply.text. strings are said as they are, numbers and true/false as JSON has them.
+func sayField(data []byte, field string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("the response isn't JSON, so it has no %s", field)
+	}
+	for _, key := range strings.Split(field, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("the response has no %s", field)
+		}
+		if v, ok = obj[key]; !ok {
+			return "", fmt.Errorf("the response has no %s", field)
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("the response's %s isn't text", field)
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryWait = time.Millisecond
+}
+
+func TestSendSigns(t *testing.T) {
+	var signed int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if Verify("s3cret", body, r.Header.Get(SignatureHeader)) {
+			atomic.StoreInt32(&signed, 1)
+		}
+	}))
+	defer srv.Close()
+
+	res := Send(context.Background(), Hook{URL: srv.URL, Secret: "s3cret"}, Payload{Intent: "test"})
+	if res.Error != "" || res.Status != http.StatusOK {
+		t.Fatalf("Send() = %+v", res)
+	}
+	if atomic.LoadInt32(&signed) != 1 {
+		t.Error("the request's signature doesn't verify with the secret")
+	}
+}
+
+func TestSendUnsigned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	if res := Send(context.Background(), Hook{URL: srv.URL}, Payload{}); res.Status != http.StatusOK {
+		t.Errorf("Send() = %+v, a hook without a secret sent a signature", res)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{"ok", []int{200}, 1, 200, false},
+		{"5xx", []int{503, 503, 503}, 3, 503, true},
+		{"429", []int{429, 429, 429}, 3, 429, true},
+		{"5xx then ok", []int{500, 200}, 2, 200, false},
+		{"4xx", []int{404}, 1, 404, true},
+		{"401", []int{401}, 1, 401, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(atomic.AddInt32(&n, 1)) - 1
+				if i >= len(tt.statuses) {
+					t.Errorf("tried %d times, want %d", i+1, len(tt.statuses))
+					return
+				}
+				w.WriteHeader(tt.statuses[i])
+			}))
+			defer srv.Close()
+
+			res := Send(context.Background(), Hook{URL: srv.URL, Retries: 2}, Payload{})
+			if res.Attempts != tt.wantAttempts || res.Status != tt.wantStatus || (res.Error != "") != tt.wantErr {
+				t.Errorf("Send() = %+v, want %d attempts, status %d, error %t", res, tt.wantAttempts, tt.wantStatus, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	start := time.Now()
+	res := Send(context.Background(), Hook{URL: srv.URL, TimeoutSeconds: 1}, Payload{})
+	if res.Attempts != 1 || res.Status != 0 || res.Error == "" {
+		t.Errorf("Send() = %+v, want one attempt which timed out", res)
+	}
+	if took := time.Since(start); took > 3*time.Second {
+		t.Errorf("Send() took %s with a 1 second timeout", took)
+	}
+}
+
+func TestSendSay(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		field    string
+		wantSay  string
+		wantErr  bool
+	}{
+		{"top level", `{"say":" hello "}`, "say", "hello", false},
+		{"nested", `{"reply":{"text":"it's 5 o'clock"}}`, "reply.text", "it's 5 o'clock", false},
+		{"number", `{"count":3}`, "count", "3", false},
+		{"bool", `{"on":true}`, "on", "true", false},
+		{"missing", `{"other":"x"}`, "say", "", true},
+		{"not an object", `{"reply":"x"}`, "reply.text", "", true},
+		{"not text", `{"say":["a"]}`, "say", "", true},
+		{"not json", `hello`, "say", "", true},
+		{"no say field", `hello`, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, tt.response)
+			}))
+			defer srv.Close()
+
+			res := Send(context.Background(), Hook{URL: srv.URL, SayField: tt.field, Retries: 2}, Payload{})
+			if res.Say != tt.wantSay || (res.Error != "") != tt.wantErr {
+				t.Errorf("Send() = %+v, want say %q, error %t", res, tt.wantSay, tt.wantErr)
+			}
+			if res.Attempts != 1 {
+				t.Errorf("Send() tried %d times, a bad response isn't tried again", res.Attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package cross_win
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/kercre123/WirePod/cross/installengine"
+)
+
+// windows implementations of the install engine's interfaces, shared by the installer and uninstaller
+
+func registryKey(store string) (KeyInfo, error) {
+	switch store {
+	case installengine.StoreSoftware:
+		return SoftwareKey, nil
+	case installengine.StoreUninstall:
+		return UninstallKey, nil
+	case installengine.StoreRun:
+		return StartupRunKey, nil
+	}
+	return KeyInfo{}, fmt.Errorf("unknown registry store: %s", store)
+}
+
+type RegistryStore struct{}
+
+// a missing value isn't an error
+func (RegistryStore) Get(store, name string) (string, bool, error) {
+	key, err := registryKey(store)
+	if err != nil {
+		return "", false, err
+	}
+	val, err := GetRegistryValueString(key, name)
+	if err != nil {
+		return "", false, nil
+	}
+	return val, true, nil
+}
+
+func (RegistryStore) Set(store, name, value string) error {
+	key, err := registryKey(store)
+	if err != nil {
+		return err
+	}
+	return UpdateRegistryValueString(key, name, value)
+}
+
+func (RegistryStore) Delete(store, name string) error {
+	key, err := registryKey(store)
+	if err != nil {
+		return err
+	}
+	return DeleteRegistryValue(key, name)
+}
+
+type NetshFirewall struct{}
+
+func (NetshFirewall) AddRule(rule installengine.FirewallRule) error {
+	out, err := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
+		"name="+rule.Name,
+		"dir="+rule.Direction,
+		"action=allow",
+		"profile=any",
+		"program="+rule.Program,
+		"enable=yes").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error adding firewall rule: %s: %s", err, out)
+	}
+	return nil
+}
+
+func (NetshFirewall) RemoveRule(rule installengine.FirewallRule) error {
+	out, err := exec.Command("netsh", "advfirewall", "firewall", "delete", "rule",
+		"name="+rule.Name,
+		"dir="+rule.Direction,
+		"program="+rule.Program).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error removing firewall rule: %s: %s", err, out)
+	}
+	return nil
+}
+
+type OLEShortcuts struct{}
+
+func (OLEShortcuts) Create(path, target string) error {
+	return CreateShortcut(path, target)
+}
+
+func (OLEShortcuts) Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func CreateShortcut(path, target string) error {
+	ole.CoInitialize(0)
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("WScript.Shell")
+	if err != nil {
+		return err
+	}
+	defer unknown.Release()
+
+	wshell, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return err
+	}
+	defer wshell.Release()
+
+	cs, err := oleutil.CallMethod(wshell, "CreateShortcut", path)
+	if err != nil {
+		return err
+	}
+	shortcut := cs.ToIDispatch()
+	defer shortcut.Release()
+
+	oleutil.PutProperty(shortcut, "TargetPath", target)
+	// Set other properties as needed
+	_, err = oleutil.CallMethod(shortcut, "Save")
+	return err
+}
@@ -13,6 +13,9 @@ type Windows struct {
 	all.OSFuncs
 }
 
+// set when ReadConfig had to upgrade the stored config
+var LastMigration all.MigrationReport
+
 func NewWindows() *Windows {
 	var WindowsObj *Windows
 	return WindowsObj
@@ -22,39 +25,63 @@ func (w *Windows) Init() error {
 	return InitReg()
 }
 
+// registry value names for each raw config key. LastRunningPID is a QWORD, everything else is a string.
+var registryNames = map[string]string{
+	"schemaversion": "SchemaVersion",
+	"wsport":        "WebPort",
+	"version":       "PodVersion",
+	"runtimepath":   "InstallPath",
+	"runatstartup":  "RunAtStartup",
+	"needsrestart":  "NeedsRestart",
+	"firststartup":  "FirstStartup",
+	"nopodwarn":     "NoPodWarn",
+	"autoupdate":    "AutoUpdate",
+}
+
 func (w *Windows) ReadConfig() (all.WPConfig, error) {
-	var wp all.WPConfig
-	port, err := GetRegistryValueString(SoftwareKey, "WebPort")
+	// WebPort is written by the installer, so if it's missing wire-pod isn't installed
+	_, err := GetRegistryValueString(SoftwareKey, "WebPort")
+	if err != nil {
+		return all.WPConfig{}, err
+	}
+	raw := all.RawConfig{}
+	for key, name := range registryNames {
+		val, err := GetRegistryValueString(SoftwareKey, name)
+		if err == nil {
+			raw[key] = val
+		}
+	}
+	if pid, err := GetRegistryValueInt(SoftwareKey, "LastRunningPID"); err == nil {
+		raw["lastrunningpid"] = fmt.Sprint(pid)
+	}
+	wp, report, err := all.Migrate(raw)
 	if err != nil {
 		return wp, err
 	}
-	ver, _ := GetRegistryValueString(SoftwareKey, "PodVersion")
-	path, _ := GetRegistryValueString(SoftwareKey, "InstallPath")
-	runatstartup, _ := GetRegistryValueString(SoftwareKey, "RunAtStartup")
-	needsr, _ := GetRegistryValueString(SoftwareKey, "NeedsRestart")
-	pid, _ := GetRegistryValueInt(SoftwareKey, "LastRunningPID")
-	wp.WSPort = port
-	wp.Version = ver
-	wp.InstallPath = path
-	wp.LastRunningPID = pid
-	if runatstartup == "true" {
-		wp.RunAtStartup = true
-	}
-	if needsr == "true" {
-		wp.NeedsRestart = true
+	if report.Changed() {
+		fmt.Printf("Migrated config from schema v%d to v%d\n", report.FromVersion, report.ToVersion)
+		for _, change := range report.Changes {
+			fmt.Println("  " + change)
+		}
+		LastMigration = report
+		w.WriteConfig(wp)
 	}
 	return wp, nil
 }
 
 func (w *Windows) WriteConfig(wp all.WPConfig) error {
+	wp.SchemaVersion = all.CurrentSchemaVersion
 	err := UpdateRegistryValueString(SoftwareKey, "InstallPath", wp.InstallPath)
 	if err != nil {
 		return err
 	}
-	UpdateRegistryValueString(SoftwareKey, "PodVersion", wp.Version)
-	UpdateRegistryValueString(SoftwareKey, "WebPort", wp.WSPort)
-	UpdateRegistryValueString(SoftwareKey, "RunAtStartup", fmt.Sprint(wp.RunAtStartup))
-	UpdateRegistryValueString(SoftwareKey, "NeedsRestart", fmt.Sprint(wp.NeedsRestart))
+	raw := all.ToRaw(wp)
+	for key, name := range registryNames {
+		if key == "runtimepath" {
+			continue
+		}
+		UpdateRegistryValueString(SoftwareKey, name, raw[key])
+	}
 	UpdateRegistryValueInt(SoftwareKey, "LastRunningPID", wp.LastRunningPID)
 	return nil
 }
@@ -103,6 +103,26 @@ func DeleteRegistryKey(keyInfo KeyInfo) error {
 	return nil
 }
 
+// DeleteRegistryKeyIfEmpty leaves the key alone if anything is still stored in it
+func DeleteRegistryKeyIfEmpty(keyInfo KeyInfo) error {
+	if !Inited {
+		return fmt.Errorf(nonInitedError)
+	}
+	k, err := registry.OpenKey(keyInfo.Key, keyInfo.KeyPath, registry.QUERY_VALUE|registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return err
+	}
+	info, err := k.Stat()
+	k.Close()
+	if err != nil {
+		return err
+	}
+	if info.ValueCount > 0 || info.SubKeyCount > 0 {
+		return nil
+	}
+	return registry.DeleteKey(keyInfo.Key, keyInfo.KeyPath)
+}
+
 func DeleteRegistryValue(keyInfo KeyInfo, key string) error {
 	if !Inited {
 		return fmt.Errorf(nonInitedError)
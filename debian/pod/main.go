@@ -8,8 +8,10 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/kercre123/WirePod/cross/profiles"
+	"github.com/kercre123/WirePod/cross/robotsim"
+	"github.com/kercre123/WirePod/cross/voicepause"
 	"github.com/kercre123/wire-pod/chipper/pkg/vars"
-	stt "github.com/kercre123/wire-pod/chipper/pkg/wirepod/stt/vosk"
 	"gopkg.in/ini.v1"
 )
 
@@ -45,6 +47,10 @@ func DoPerfMode(perfMode string) {
 
 func main() {
 	vars.IsPackagedLinux = true
+	usePod()
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		os.Exit(pod.RunBackupCLI(os.Args[2:]))
+	}
 	verb := flag.Bool("verbose", true, "with/without debug logging")
 	justIP := flag.Bool("justip", false, "show just configuration page")
 	flag.Parse()
@@ -96,6 +102,13 @@ func main() {
 			}
 		}
 	}
+	if flag.Arg(0) == "pause" {
+		os.Exit(voicepause.RunCLI("http://127.0.0.1:"+webPort, flag.Args()[1:]))
+	}
+	// runs in a temporary directory, so it goes before the checks for an install
+	if flag.Arg(0) == "selftest" {
+		os.Exit(pod.RunSelfTestCLI(ChipperHTTPApi, flag.Args()[1:]))
+	}
 	if *justIP {
 		ipAddr := vars.GetOutboundIP().String()
 		fmt.Println("\033[1;32mWirePod configuration page: \033[1;36mhttp://" + ipAddr + ":" + webPort + "\033[0m")
@@ -118,8 +131,22 @@ func main() {
 	if *verb {
 		os.Setenv("DEBUG_LOGGING", "true")
 	}
-	os.Setenv("STT_SERVICE", "vosk")
+	if flag.Arg(0) == "replay" {
+		os.Exit(pod.RunReplayCLI(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "intent-test" {
+		os.Exit(pod.RunIntentTestCLI(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "simulate" {
+		confDir, _ := os.UserConfigDir()
+		os.Exit(robotsim.RunCLI("127.0.0.1:"+profiles.ChipperPort(confDir, profiles.Profile{}), flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "mqtt" {
+		os.Exit(pod.RunMQTTCLI(flag.Args()[1:]))
+	}
+	engine := pod.SelectSTTEngine()
+	os.Setenv("STT_SERVICE", engine.Name)
 	os.Chdir("/etc/wire-pod")
 	DoPerfMode(perfMode)
-	StartFromProgramInit(stt.Init, stt.STT, stt.Name)
+	StartFromProgramInit(engine.Init, engine.STT, engine.Name)
 }
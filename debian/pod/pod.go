@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+
+	"github.com/kercre123/WirePod/cross/podkit"
+	"github.com/kercre123/wire-pod/chipper/pkg/logger"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
+)
+
+// the features shared with the desktop app, see podkit. the schedule, stt engine config and the rest
+// live next to config.ini.
+var pod *podkit.Pod
+
+func usePod() {
+	pod = podkit.New("/etc/wire-pod")
+	pod.Restart = RestartServer
+	pod.Start = StartChipper
+	pod.Stop = StopServer
+	pod.InitOffline = initOffline
+}
+
+// readies chipper for use without the server: working directory, config, intents
+func initOffline() error {
+	if err := os.Chdir("/etc/wire-pod"); err != nil {
+		return err
+	}
+	logger.Init()
+	vars.Init()
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kercre123/WirePod/cross/podtest"
+)
+
+// the same checks as "selftest": this package's chipper server in a temporary directory, against a stub STT
+// engine, so it needs neither an install nor a robot
+func TestPod(t *testing.T) {
+	usePod()
+	// the harness makes its own chipper directory, nothing may go to /etc/wire-pod
+	pod.Dir = t.TempDir()
+	podtest.Test(t, pod.SelfTest(ChipperHTTPApi), podtest.Options{})
+}
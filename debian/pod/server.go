@@ -12,8 +12,11 @@ import (
 	"github.com/digital-dream-labs/api/go/jdocspb"
 	"github.com/digital-dream-labs/api/go/tokenpb"
 	"github.com/digital-dream-labs/hugh/log"
+	"github.com/kercre123/WirePod/cross/history"
+	"github.com/kercre123/WirePod/cross/slots"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/WirePod/cross/voicepause"
 	"github.com/kercre123/wire-pod/chipper/pkg/logger"
-	"github.com/kercre123/wire-pod/chipper/pkg/mdnshandler"
 	chipperserver "github.com/kercre123/wire-pod/chipper/pkg/servers/chipper"
 	jdocsserver "github.com/kercre123/wire-pod/chipper/pkg/servers/jdocs"
 	tokenserver "github.com/kercre123/wire-pod/chipper/pkg/servers/token"
@@ -34,7 +37,6 @@ var serverOne cmux.CMux
 var serverTwo cmux.CMux
 var listenerOne net.Listener
 var listenerTwo net.Listener
-var voiceProcessor *wp.Server
 
 // grpcServer *grpc.Servervar
 var chipperServing bool = false
@@ -63,17 +65,18 @@ func grpcServe(l net.Listener, p *wp.Server) error {
 		log.Fatal(err)
 	}
 
+	pp := history.Processor{Next: voicepause.Processor{Server: slots.Processor{Next: p, Slots: pod.Slots}, Pause: pod.Pause}, Recorder: pod.History}
 	s, _ := chipperserver.New(
-		chipperserver.WithIntentProcessor(p),
-		chipperserver.WithKnowledgeGraphProcessor(p),
-		chipperserver.WithIntentGraphProcessor(p),
+		chipperserver.WithIntentProcessor(pp),
+		chipperserver.WithKnowledgeGraphProcessor(pp),
+		chipperserver.WithIntentGraphProcessor(pp),
 	)
 
 	tokenServer := tokenserver.NewTokenServer()
 	jdocsServer := jdocsserver.NewJdocsServer()
 	//jdocsserver.IniToJson()
 
-	chipperpb.RegisterChipperGrpcServer(srv.Transport(), s)
+	chipperpb.RegisterChipperGrpcServer(srv.Transport(), history.ChipperServer{ChipperGrpcServer: s, Recorder: pod.History})
 	jdocspb.RegisterJdocsServer(srv.Transport(), jdocsServer)
 	tokenpb.RegisterTokenServer(srv.Transport(), tokenServer)
 
@@ -82,11 +85,13 @@ func grpcServe(l net.Listener, p *wp.Server) error {
 
 func BeginWirepodSpecific(sttInitFunc func() error, sttHandlerFunc interface{}, voiceProcessorName string) error {
 	logger.Init()
+	pod.InitLogs()
 
 	// begin wirepod stuff
 	vars.Init()
+	pod.Init(voiceProcessorName)
 	var err error
-	voiceProcessor, err = wp.New(sttInitFunc, sttHandlerFunc, voiceProcessorName)
+	pod.VoiceProcessor, err = pod.BuildVoiceProcessor(sttengine.Engine{Name: voiceProcessorName, Init: sttInitFunc, STT: sttHandlerFunc})
 	wpweb.SttInitFunc = sttInitFunc
 	go sdkWeb.BeginServer()
 	http.HandleFunc("/api-chipper/", ChipperHTTPApi)
@@ -117,29 +122,34 @@ func StartFromProgramInit(sttInitFunc func() error, sttHandlerFunc interface{},
 	wpweb.StartWebServer()
 }
 
-func RestartServer() {
-	if chipperServing {
-		serverOne.Close()
+// closes what StartChipper opened. the second listener is only there in escape pod mode.
+func stopChipper() {
+	if !chipperServing {
+		return
+	}
+	chipperServing = false
+	serverOne.Close()
+	listenerOne.Close()
+	if serverTwo != nil {
 		serverTwo.Close()
-		listenerOne.Close()
 		listenerTwo.Close()
+		serverTwo, listenerTwo = nil, nil
 	}
+}
+
+func RestartServer() {
+	stopChipper()
 	go StartChipper()
 }
 
 func StopServer() {
-	if chipperServing {
-		serverOne.Close()
-		serverTwo.Close()
-		listenerOne.Close()
-		listenerTwo.Close()
-	}
+	stopChipper()
 }
 
 func StartChipper() {
 	// load certs
 	if vars.APIConfig.Server.EPConfig && runtime.GOOS != "android" {
-		go mdnshandler.PostmDNS()
+		pod.StartMDNS()
 	}
 	var certPub []byte
 	var certPriv []byte
@@ -193,7 +203,7 @@ func StartChipper() {
 	serverOne = cmux.New(listenerOne)
 	grpcListenerOne := serverOne.Match(cmux.HTTP2())
 	httpListenerOne := serverOne.Match(cmux.HTTP1Fast())
-	go grpcServe(grpcListenerOne, voiceProcessor)
+	go grpcServe(grpcListenerOne, pod.VoiceProcessor)
 	go httpServe(httpListenerOne)
 
 	if vars.APIConfig.Server.EPConfig && os.Getenv("NO8084") != "true" {
@@ -209,23 +219,29 @@ func StartChipper() {
 		serverTwo = cmux.New(listenerTwo)
 		grpcListenerTwo := serverTwo.Match(cmux.HTTP2())
 		httpListenerTwo := serverTwo.Match(cmux.HTTP1Fast())
-		go grpcServe(grpcListenerTwo, voiceProcessor)
+		go grpcServe(grpcListenerTwo, pod.VoiceProcessor)
 		go httpServe(httpListenerTwo)
 	}
 
 	fmt.Println("\033[33m\033[1mwire-pod started successfully!\033[0m")
 
 	chipperServing = true
+	// a restart may have started the next server by the time this one stops
+	serving := serverOne
 	if vars.APIConfig.Server.EPConfig && os.Getenv("NO8084") != "true" {
 		if runtime.GOOS != "android" {
 			go serverOne.Serve()
 		}
 		serverTwo.Serve()
 		logger.Println("Stopping chipper server")
-		chipperServing = false
+		if serverOne == serving {
+			chipperServing = false
+		}
 	} else {
 		serverOne.Serve()
 		logger.Println("Stopping chipper server")
-		chipperServing = false
+		if serverOne == serving {
+			chipperServing = false
+		}
 	}
 }
@@ -52,5 +52,8 @@ func ChipperHTTPApi(w http.ResponseWriter, r *http.Request) {
 		RestartServer()
 		fmt.Fprint(w, "done")
 		return
+	default:
+		// the features shared with the desktop app
+		pod.ServeAPI(w, r)
 	}
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/kercre123/WirePod/cross/installengine"
+	cross_win "github.com/kercre123/WirePod/cross/win"
+)
+
+// the install engine wired to the windows implementations in cross_win
+
+var installJournalPath = filepath.Join(os.TempDir(), "wire-pod-install-journal.json")
+
+func NewInstallEngine() *installengine.Engine {
+	return &installengine.Engine{
+		FS:          installengine.OSFileSystem{},
+		Store:       cross_win.RegistryStore{},
+		Firewall:    cross_win.NetshFirewall{},
+		Shortcuts:   cross_win.OLEShortcuts{},
+		Downloader:  installengine.HTTPDownloader{},
+		JournalPath: installJournalPath,
+		Progress:    UpdateInstallBar,
+		Status:      UpdateInstallStatus,
+	}
+}
+
+func (is InstallSettings) EngineSettings() installengine.Settings {
+	return installengine.Settings{
+		RunAtStartup:    is.RunAtStartup,
+		AutoUpdate:      is.AutoUpdate,
+		Where:           is.Where,
+		WebPort:         is.WebPort,
+		SetHostnameEpod: is.SetHostnameEpod,
+		Version:         GitHubTag,
+		DownloadURL:     amd64podURL,
+		SHA256:          releaseSHA256,
+		DownloadPath:    downloadPath,
+		LocalZip:        localZip,
+	}
+}
+
+func InstallHooks() installengine.Hooks {
+	return installengine.Hooks{
+		StopRunningPod: func() error {
+			StopWirePodIfRunning()
+			return nil
+		},
+		RemovePreviousInstall: func() error {
+			DeleteAnyOtherInstallation()
+			return nil
+		},
+		SetHostname: ChangeHostname,
+	}
+}
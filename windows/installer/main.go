@@ -3,6 +3,7 @@ package main
 import (
 	"embed"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/kercre123/WirePod/cross/installengine"
 	cross_win "github.com/kercre123/WirePod/cross/win"
 	"github.com/ncruces/zenity"
 )
@@ -181,11 +183,10 @@ func DoInstall(myApp fyne.App, is InstallSettings) {
 	if err != nil {
 		fmt.Println("error installing WirePod: " + err.Error())
 		zenity.Error(
-			"Error installing WirePod, will revert installation and quit: "+err.Error(),
+			"Error installing WirePod. The installation has been reverted. Error details: "+err.Error(),
 			zenity.ErrorIcon,
 			zenity.Title("WirePod Installer"),
 		)
-		cross_win.DeleteEverythingFromRegistry()
 		os.Exit(1)
 	}
 	window.Hide()
@@ -197,14 +198,7 @@ func DoInstall(myApp fyne.App, is InstallSettings) {
 }
 
 func ValidateWebPort(port string) bool {
-	i, err := strconv.Atoi(port)
-	if err == nil {
-		if i < 1000 || i > 65353 {
-			return false
-		}
-		return true
-	}
-	return false
+	return installengine.ValidateWebPort(port)
 }
 
 func GetPreferences(myApp fyne.App) {
@@ -221,6 +215,10 @@ func GetPreferences(myApp fyne.App) {
 	launchOnStartup.SetChecked(true)
 	is.RunAtStartup = true
 
+	autoUpdate := widget.NewCheck("Automatically download and install WirePod updates?", func(checked bool) {
+		is.AutoUpdate = checked
+	})
+
 	installDir := widget.NewEntry()
 	installDir.SetText(DefaultInstallationDirectory)
 	installDir.Disable()
@@ -264,6 +262,7 @@ func GetPreferences(myApp fyne.App) {
 		firstCard,
 		widget.NewSeparator(),
 		launchOnStartup,
+		autoUpdate,
 		widget.NewSeparator(),
 		widget.NewRichTextWithText("Installation Directory"),
 		installDir,
@@ -296,43 +295,44 @@ func StopWirePodIfRunning() {
 }
 
 func ValidateInstallDirectory(dir string) bool {
-	var dirWithoutLast string
-	splitDir := strings.Split(dir, "\\")
-	dirWithoutLast = splitDir[0]
-	for in, str := range splitDir {
-		if in == len(splitDir)-1 || in == 0 {
-			continue
-		}
-		dirWithoutLast = dirWithoutLast + "\\" + str
-	}
-	if statted, err := os.Stat(dirWithoutLast); err == nil {
-		if statted.IsDir() {
-			return true
-		}
-	}
-	return false
+	return installengine.ValidateInstallDirectory(dir)
 }
 
 func main() {
+	flags := flag.NewFlagSet("installer", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the install plan without changing anything")
+	answerFile := flags.String("unattended", "", "install silently with the settings in this JSON or INI answer file")
+	logFile := flags.String("log", "", "log file for unattended installs")
+	zip := flags.String("zip", "", "install from this release zip instead of downloading")
+	mirror := flags.String("mirror", "", "base URL of a mirror with manifest.json and "+releaseZipName)
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(ExitBadAnswers)
+	}
+	if *dryRun {
+		os.Exit(DryRun(*answerFile, *zip, *mirror))
+	}
+	if *answerFile != "" {
+		os.Exit(RunUnattended(*answerFile, *logFile, *zip, *mirror))
+	}
 	if !CheckIfElevated() {
 		fmt.Println("installer must be run as administrator")
 		os.Exit(0)
 	}
 	fmt.Println("Initing registry")
 	cross_win.InitReg()
-	fmt.Println("Getting tag from GitHub")
-	tag, err := GetLatestReleaseTag("kercre123", "WirePod")
+	fmt.Println("Finding the latest release")
+	source, err := ResolveRelease(*zip, *mirror)
 	if err != nil {
 		fmt.Println("Error getting: " + err.Error())
 		zenity.Error(
-			"Error getting latest GitHub tag from GitHub, exiting: "+err.Error(),
+			"Error finding the latest WirePod release, exiting: "+err.Error(),
 			zenity.ErrorIcon,
 			zenity.Title("WirePod Installer"),
 		)
 		os.Exit(0)
 	}
-	GitHubTag = tag
-	fmt.Println(tag)
+	source.Apply()
+	fmt.Println(source.Describe())
 	iconBytes, err := iconData.ReadFile("ico/pod.png")
 	if err != nil {
 		fmt.Println(err)
@@ -380,3 +380,45 @@ func GetLatestReleaseTag(owner, repo string) (string, error) {
 
 	return release.TagName, nil
 }
+
+// DryRun prints the plan for the default settings, or the answer file's, without changing anything.
+// the release is only looked up for a local zip, so nothing is downloaded.
+func DryRun(answerFile, zip, mirror string) int {
+	GitHubTag = "latest"
+	is := InstallSettings{
+		RunAtStartup: true,
+		Where:        DefaultInstallationDirectory,
+		WebPort:      "8080",
+	}
+	if answerFile != "" {
+		answers, err := ReadAnswers(answerFile)
+		if err != nil {
+			fmt.Println(err)
+			return ExitBadAnswers
+		}
+		is.FromAnswers(answers)
+		if zip == "" {
+			zip = answers.LocalZip
+		}
+		if mirror == "" {
+			mirror = answers.Mirror
+		}
+	}
+	if zip != "" {
+		source, err := localRelease(zip)
+		if err != nil {
+			fmt.Println(err)
+			return ExitNoRelease
+		}
+		source.Apply()
+	} else if mirror != "" {
+		amd64podURL = strings.TrimSuffix(mirror, "/") + "/" + releaseZipName
+	}
+	plan, err := installengine.BuildPlan(is.EngineSettings(), InstallHooks())
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	installengine.WritePlan(os.Stdout, plan)
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kercre123/WirePod/cross/updater"
+)
+
+// the release is found in this order:
+//  1. a zip given with --zip (or localzip in an answer file), or wire-pod-win-amd64.zip next to the installer.
+//     a manifest.json next to it supplies the version and checksum.
+//  2. manifest.json at the mirror's base URL, which must list a windows-amd64 artifact.
+//  3. GitHub: the latest release's manifest.json, or the release API plus the fixed zip URL for
+//     releases from before manifests were published (installed without a checksum).
+
+var DefaultReleaseBase = "https://github.com/kercre123/WirePod/releases/latest/download/"
+
+const releaseZipName = "wire-pod-win-amd64.zip"
+
+// set from the release manifest, empty means the zip isn't verified
+var releaseSHA256 string
+
+// set for offline installs
+var localZip string
+
+// a fixed name in the temp dir, so a download interrupted by a failed install resumes next time
+var downloadPath = filepath.Join(os.TempDir(), releaseZipName)
+
+type ReleaseSource struct {
+	Version  string
+	URL      string
+	SHA256   string
+	LocalZip string
+}
+
+func (rs ReleaseSource) Describe() string {
+	where := rs.URL
+	if rs.LocalZip != "" {
+		where = rs.LocalZip
+	}
+	if rs.SHA256 == "" {
+		return fmt.Sprintf("%s from %s (no checksum)", rs.Version, where)
+	}
+	return fmt.Sprintf("%s from %s (sha256 %s)", rs.Version, where, rs.SHA256)
+}
+
+// Apply points the install settings at this source
+func (rs ReleaseSource) Apply() {
+	GitHubTag = rs.Version
+	amd64podURL = rs.URL
+	releaseSHA256 = rs.SHA256
+	localZip = rs.LocalZip
+}
+
+func installerDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "."
+	}
+	return filepath.Dir(exe)
+}
+
+// ResolveRelease finds where to install from. zip and mirror may be empty.
+func ResolveRelease(zip, mirror string) (ReleaseSource, error) {
+	if zip == "" {
+		if _, err := os.Stat(filepath.Join(installerDir(), releaseZipName)); err == nil {
+			zip = filepath.Join(installerDir(), releaseZipName)
+		}
+	}
+	if zip != "" {
+		return localRelease(zip)
+	}
+	if mirror != "" {
+		return manifestRelease(strings.TrimSuffix(mirror, "/") + "/")
+	}
+	if rs, err := manifestRelease(DefaultReleaseBase); err == nil {
+		return rs, nil
+	}
+	tag, err := GetLatestReleaseTag("kercre123", "WirePod")
+	if err != nil {
+		return ReleaseSource{}, err
+	}
+	if tag == "" {
+		return ReleaseSource{}, errors.New("no release tag found")
+	}
+	return ReleaseSource{Version: tag, URL: DefaultReleaseBase + releaseZipName}, nil
+}
+
+func manifestRelease(base string) (ReleaseSource, error) {
+	m, err := updater.FetchUnsignedManifest(base + "manifest.json")
+	if err != nil {
+		return ReleaseSource{}, fmt.Errorf("error getting release manifest: %s", err)
+	}
+	a, ok := m.Artifact("windows-amd64")
+	if !ok {
+		return ReleaseSource{}, fmt.Errorf("release manifest %s has no windows-amd64 artifact", m.Version)
+	}
+	if a.SHA256 == "" {
+		return ReleaseSource{}, fmt.Errorf("release manifest %s has no checksum for windows-amd64", m.Version)
+	}
+	return ReleaseSource{Version: m.Version, URL: a.URL, SHA256: a.SHA256}, nil
+}
+
+func localRelease(zip string) (ReleaseSource, error) {
+	abs, err := filepath.Abs(zip)
+	if err != nil {
+		return ReleaseSource{}, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return ReleaseSource{}, fmt.Errorf("local zip: %s", err)
+	}
+	rs := ReleaseSource{Version: "local", LocalZip: abs}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(abs), "manifest.json"))
+	if err != nil {
+		return rs, nil
+	}
+	m, err := updater.ParseManifest(data)
+	if err != nil {
+		return rs, err
+	}
+	rs.Version = m.Version
+	if a, ok := m.Artifact("windows-amd64"); ok {
+		rs.SHA256 = a.SHA256
+	}
+	return rs, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/kercre123/WirePod/cross/installengine"
+	cross_win "github.com/kercre123/WirePod/cross/win"
+)
+
+// exit codes of an unattended install
+const (
+	ExitOK            = 0
+	ExitInstallFailed = 1
+	ExitBadAnswers    = 2
+	ExitNotElevated   = 3
+	ExitNoRelease     = 4
+	// same as msiexec's ERROR_SUCCESS_REBOOT_REQUIRED
+	ExitRebootRequired = 3010
+)
+
+var defaultInstallLog = filepath.Join(os.TempDir(), "wire-pod-install.log")
+
+// ReadAnswers parses and validates an answer file
+func ReadAnswers(path string) (installengine.Answers, error) {
+	defaults := installengine.DefaultAnswers(DefaultInstallationDirectory)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaults, fmt.Errorf("error reading answer file: %s", err)
+	}
+	answers, err := installengine.ParseAnswers(path, data, defaults)
+	if err != nil {
+		return answers, err
+	}
+	return answers, answers.Validate()
+}
+
+func (is *InstallSettings) FromAnswers(a installengine.Answers) {
+	is.RunAtStartup = a.RunAtStartup
+	is.AutoUpdate = a.AutoUpdate
+	is.Where = a.Where
+	is.WebPort = a.WebPort
+	is.SetHostnameEpod = a.SetHostnameEpod
+}
+
+// RunUnattended installs without any windows. progress goes to a log file (and stdout) instead of the progress bar.
+func RunUnattended(answerFile, logFile, zip, mirror string) int {
+	answers, answerErr := ReadAnswers(answerFile)
+	if logFile == "" {
+		logFile = answers.LogFile
+	}
+	if logFile == "" {
+		logFile = defaultInstallLog
+	}
+	var out io.Writer = os.Stdout
+	if f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+		defer f.Close()
+		out = io.MultiWriter(os.Stdout, f)
+	} else {
+		fmt.Println("error opening log file, logging to stdout only: " + err.Error())
+	}
+	logger := log.New(out, "", log.LstdFlags)
+	logger.Println("wire-pod unattended install, answer file: " + answerFile)
+	if answerErr != nil {
+		logger.Println("error: " + answerErr.Error())
+		return ExitBadAnswers
+	}
+	if !CheckIfElevated() {
+		logger.Println("error: installer must be run as administrator")
+		return ExitNotElevated
+	}
+	cross_win.InitReg()
+	if zip == "" {
+		zip = answers.LocalZip
+	}
+	if mirror == "" {
+		mirror = answers.Mirror
+	}
+	source, err := ResolveRelease(zip, mirror)
+	if err != nil {
+		logger.Println("error finding the wire-pod release: " + err.Error())
+		return ExitNoRelease
+	}
+	source.Apply()
+	logger.Println("installing " + source.Describe())
+
+	var is InstallSettings
+	is.FromAnswers(answers)
+	logger.Printf("installing to %s (web port %s, run at startup %t, auto update %t, hostname escapepod %t)",
+		is.Where, is.WebPort, is.RunAtStartup, is.AutoUpdate, is.SetHostnameEpod)
+
+	engine := NewInstallEngine()
+	lastTen := -1
+	engine.Progress = func(percent float64) {
+		if ten := int(percent) / 10; ten != lastTen {
+			lastTen = ten
+			logger.Printf("%d%%", ten*10)
+		}
+	}
+	engine.Status = func(status string) {
+		logger.Println(status)
+	}
+	if err := InstallWirePodWith(engine, is); err != nil {
+		logger.Println("error installing wire-pod, the installation has been reverted: " + err.Error())
+		return ExitInstallFailed
+	}
+	logger.Println("wire-pod has finished installing")
+
+	if is.SetHostnameEpod {
+		if answers.Reboot {
+			logger.Println("rebooting")
+			if err := RebootSystem(); err != nil {
+				logger.Println("error: " + err.Error())
+			} else {
+				return ExitOK
+			}
+		}
+		cross_win.UpdateRegistryValueString(cross_win.SoftwareKey, "NeedsRestart", "true")
+		logger.Println("a reboot is required before wire-pod is started")
+		return ExitRebootRequired
+	}
+	if answers.StartAfterInstall {
+		if err := ExecuteDetached(filepath.Join(is.Where, "chipper/chipper.exe")); err != nil {
+			logger.Println("error starting wire-pod: " + err.Error())
+		}
+	}
+	return ExitOK
+}
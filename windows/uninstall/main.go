@@ -4,10 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/kercre123/WirePod/cross/backup"
+	"github.com/kercre123/WirePod/cross/installengine"
+	"github.com/kercre123/WirePod/cross/sttengine"
+	"github.com/kercre123/WirePod/cross/webhook"
 	cross_win "github.com/kercre123/WirePod/cross/win"
+	"github.com/kercre123/wire-pod/chipper/pkg/vars"
 	"github.com/ncruces/zenity"
 )
 
@@ -59,6 +68,84 @@ func CheckWirePodRunningViaRegistry() {
 	}
 }
 
+func podDir() string {
+	conf, _ := os.UserConfigDir()
+	return filepath.Join(conf, "wire-pod")
+}
+
+// ExportUserData writes a backup bundle the new install (or the backup CLI) can restore
+func ExportUserData() error {
+	home, _ := os.UserHomeDir()
+	path, err := zenity.SelectFileSave(
+		zenity.Title("Save wire-pod backup"),
+		zenity.Filename(filepath.Join(home, "Desktop", "wirepod-export-"+time.Now().Format("20060102-150405")+backup.BundleExt)),
+		zenity.ConfirmOverwrite(),
+		zenity.FileFilter{Name: "wire-pod backup", Patterns: []string{"*" + backup.BundleExt}},
+	)
+	if err != nil {
+		return err
+	}
+	vars.Packaged = true
+	vars.Init()
+	components := append(backup.DefaultComponents(), backup.Component{
+		Name:  "stt-engine",
+		Paths: []string{sttengine.ConfigPath(podDir())},
+	}, backup.Component{
+		Name:  "webhooks",
+		Paths: []string{webhook.ConfigPath(filepath.Dir(vars.CustomIntentsPath))},
+	})
+	_, err = backup.ExportFile(path, components, "")
+	return err
+}
+
+// RemoveUserData deletes saved bot settings and the pod's settings in the registry, after offering a backup
+func RemoveUserData() {
+	err := zenity.Question(
+		"Would you like to export your settings to a backup file first? It can be restored after reinstalling wire-pod.",
+		zenity.QuestionIcon,
+		zenity.Title("wire-pod uninstaller"),
+		zenity.OKLabel("Export"),
+		zenity.CancelLabel("Skip"),
+	)
+	if err == nil {
+		if err := ExportUserData(); err != nil {
+			if errors.Is(err, zenity.ErrCanceled) {
+				return
+			}
+			zenity.Error(
+				"The backup couldn't be saved, so application data was kept: "+err.Error(),
+				zenity.ErrorIcon,
+				zenity.Title("wire-pod uninstaller"),
+			)
+			return
+		}
+	}
+	os.RemoveAll(podDir())
+	cross_win.DeleteRegistryKey(cross_win.SoftwareKey)
+}
+
+// LoadManifest reads the manifest from the install directory. it refuses a manifest made for a different directory,
+// and falls back to a legacy one (no files) for installs from before manifests existed.
+func LoadManifest(where string) (installengine.Manifest, error) {
+	m, err := installengine.ReadManifest(installengine.ManifestPath(where))
+	if errors.Is(err, os.ErrNotExist) {
+		return installengine.LegacyManifest(where, ""), nil
+	} else if err != nil {
+		return m, err
+	}
+	if !strings.EqualFold(filepath.Clean(m.Where), filepath.Clean(where)) {
+		return m, fmt.Errorf("the install manifest is for %s, but wire-pod is registered at %s", m.Where, where)
+	}
+	return m, nil
+}
+
+// the running uninstaller can't delete itself, so a detached cmd does it (and removes the install dir if empty) once we exit
+func RemoveSelfAfterExit(self, where string) {
+	cmd := exec.Command("cmd.exe", "/C", `ping 127.0.0.1 -n 3 > nul & del /f /q "`+self+`" & rmdir "`+where+`"`)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Start()
+}
+
 func main() {
 	cross_win.InitReg()
 	if os.Getenv("RUN_DISCRETE") == "true" {
@@ -76,6 +163,30 @@ func main() {
 		}
 	}
 	StopWirePodIfRunning()
+
+	val, err := cross_win.GetRegistryValueString(cross_win.SoftwareKey, "InstallPath")
+	if err != nil {
+		fmt.Println("error getting installpath from registry: " + err.Error())
+		os.Exit(0)
+	}
+	fmt.Println(val)
+
+	manifest, err := LoadManifest(val)
+	if err == nil {
+		err = installengine.CheckRoot(val)
+	}
+	if err != nil {
+		fmt.Println("refusing to uninstall: " + err.Error())
+		if !discrete {
+			zenity.Error(
+				"wire-pod can't be uninstalled safely, nothing has been removed: "+err.Error(),
+				zenity.ErrorIcon,
+				zenity.Title("wire-pod uninstaller"),
+			)
+		}
+		os.Exit(1)
+	}
+
 	if !discrete {
 		err := zenity.Question(
 			"Would you like to remove application data, including saved bot settings and API preferences?",
@@ -85,34 +196,53 @@ func main() {
 			zenity.Title("wire-pod uninstaller"),
 		)
 		if err == nil {
-			conf, _ := os.UserConfigDir()
-			os.RemoveAll(filepath.Join(conf, "wire-pod"))
+			RemoveUserData()
 		}
 	}
 
-	val, err := cross_win.GetRegistryValueString(cross_win.SoftwareKey, "InstallPath")
-
-	if err != nil {
-		fmt.Println("error getting installpath from registry: " + err.Error())
-		os.Exit(0)
+	self, _ := os.Executable()
+	engine := &installengine.Engine{
+		FS:        installengine.OSFileSystem{},
+		Store:     cross_win.RegistryStore{},
+		Firewall:  cross_win.NetshFirewall{},
+		Shortcuts: cross_win.OLEShortcuts{},
+		Status:    func(status string) { fmt.Println(status) },
 	}
+	report := engine.Uninstall(manifest, self)
+	cross_win.DeleteRegistryKeyIfEmpty(cross_win.UninstallKey)
+	cross_win.DeleteRegistryKeyIfEmpty(cross_win.SoftwareKey)
+	fmt.Printf("Removed %d items\n", len(report.Removed))
+	for _, p := range report.Refused {
+		fmt.Println("refused (not part of the install): " + p)
+	}
+	// the uninstaller and the directory holding it are removed after exit
+	var leftovers []string
+	for _, p := range report.Kept {
+		if p != filepath.Clean(self) && p != filepath.Clean(val) {
+			leftovers = append(leftovers, p)
+			fmt.Println("kept: " + p)
+		}
+	}
+	for _, err := range report.Errors {
+		fmt.Println("error: " + err.Error())
+	}
+	RemoveSelfAfterExit(self, val)
 
-	cross_win.DeleteEverythingFromRegistry()
-	fmt.Println("Deleted wire-pod from registry")
-
-	fmt.Println(val)
-
-	os.RemoveAll(filepath.Join(val, "chipper"))
-	os.RemoveAll(filepath.Join(val, "vector-cloud"))
-	os.Remove("C:\\ProgramData\\Microsoft\\Windows\\Start Menu\\Programs\\wire-pod.lnk")
-	os.Remove("C:\\ProgramData\\Microsoft\\Windows\\Start Menu\\Programs\\WirePod.lnk")
 	if !discrete {
+		msg := "wire-pod has successfully been uninstalled."
+		if manifest.Legacy {
+			msg = "wire-pod has been uninstalled. This installation predates install manifests, so its files in " + val + " were left in place and can be deleted manually."
+		} else if len(report.Errors) > 0 || len(leftovers) > 0 || len(report.Refused) > 0 {
+			msg = "wire-pod has been uninstalled, but some files were left in " + val + " because they weren't installed by wire-pod or couldn't be removed."
+		}
 		zenity.Info(
-			"wire-pod has successfully been uninstalled.",
+			msg,
 			zenity.InfoIcon,
 			zenity.Title("wire-pod uninstaller"),
 		)
 	}
-	os.RemoveAll(val)
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
 	os.Exit(0)
 }